@@ -4,4 +4,15 @@ import "github.com/JohnnyOhms/projectx/model"
 
 func SyncDB() {
 	DB.AutoMigrate(&model.User{}, &model.User_Details{}, &model.Avatar{})
+	DB.AutoMigrate(&model.Leaderboard{}, &model.LeaderboardEntry{}, &model.LeaderboardPeriod{}, &model.Season{}, &model.SeasonStanding{}, &model.Submission{})
+	DB.AutoMigrate(&model.GameKey{}, &model.UsedNonce{}, &model.SubmissionRejection{})
+	DB.AutoMigrate(&model.Notification{}, &model.Achievement{}, &model.AchievementProgress{})
+	DB.AutoMigrate(&model.Team{}, &model.TeamMember{}, &model.TeamRequest{}, &model.TeamBoard{}, &model.TeamEntry{})
+	DB.AutoMigrate(&model.RatingBoard{}, &model.PlayerRating{}, &model.Match{}, &model.MatchParticipant{})
+	DB.AutoMigrate(&model.Tournament{}, &model.TournamentPlayer{}, &model.TournamentMatch{})
+	DB.AutoMigrate(&model.FriendRequest{}, &model.Friend{}, &model.Block{})
+	DB.AutoMigrate(&model.Removal{}, &model.RemovedEntry{}, &model.Ban{}, &model.AuditEntry{})
+	DB.AutoMigrate(&model.IdempotencyKey{})
+	DB.AutoMigrate(&model.RankSnapshot{}, &model.SnapshotRank{})
+	DB.AutoMigrate(&model.RankSet{}, &model.RankItem{})
 }
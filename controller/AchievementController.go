@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AchievementController defines the methods for achievement definitions and player progress.
+type AchievementController interface {
+	CreateAchievement(ctx *gin.Context)
+	ListAchievements(ctx *gin.Context)
+	UserAchievements(ctx *gin.Context)
+}
+
+// achievementcontroller is the implementation of AchievementController.
+type achievementcontroller struct {
+	services services.AchievementService
+}
+
+// NewAchievementController creates a new instance of AchievementController.
+func NewAchievementController(services services.AchievementService) AchievementController {
+	return &achievementcontroller{
+		services: services,
+	}
+}
+
+// CreateAchievement adds an achievement definition.
+func (c *achievementcontroller) CreateAchievement(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.Achievement
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	achievement, err := c.services.CreateAchievement(reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, achievement)
+}
+
+// ListAchievements returns every achievement definition.
+func (c *achievementcontroller) ListAchievements(ctx *gin.Context) {
+	achievements, err := c.services.ListAchievements()
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, achievements)
+}
+
+// UserAchievements returns the progress of the :userId user on every achievement.
+func (c *achievementcontroller) UserAchievements(ctx *gin.Context) {
+	progress, err := c.services.UserAchievements(ctx.Param("userId"))
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, progress)
+}
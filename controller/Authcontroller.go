@@ -25,6 +25,9 @@ type AuthController interface {
 	ReteriveUserDetails(ctx *gin.Context)
 	DiscordAuth(ctx *gin.Context)
 	UploadAvatar(ctx *gin.Context)
+	SetCountry(ctx *gin.Context)
+	SearchUsers(ctx *gin.Context)
+	SetAccountType(ctx *gin.Context)
 }
 
 // controller is the implementation of AuthController.
@@ -106,7 +109,7 @@ func (c *controller) SignUpUser(ctx *gin.Context) {
 	// Create new user
 	user, err := c.services.Create(reqBody)
 	if err != nil {
-		ctx.JSON(400, gin.H{
+		ctx.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -141,7 +144,7 @@ func (c *controller) SetUserDetails(ctx *gin.Context) {
 	// create user details
 	userDetails, err := c.services.CreateDetails(UserBody)
 	if err != nil {
-		ctx.JSON(400, gin.H{
+		ctx.JSON(errorStatus(err), gin.H{
 			"error": err.Error(),
 		})
 		return
@@ -304,6 +307,10 @@ func (c *controller) DiscordAuth(ctx *gin.Context) {
 				})
 				return
 			}
+			// default the details from the discord profile
+			if err := c.services.DiscordDetails(user.UserId, UserInfo); err != nil {
+				fmt.Println("Error saving discord details:", err)
+			}
 			// generate token for authorization
 			token, err := c.services.GenearateToken(user)
 			if err != nil {
@@ -335,6 +342,10 @@ func (c *controller) DiscordAuth(ctx *gin.Context) {
 		})
 		return
 	}
+	// fill a missing country from the discord locale
+	if err := c.services.DiscordDetails(user.UserId, UserInfo); err != nil {
+		fmt.Println("Error saving discord details:", err)
+	}
 
 	// Generate Token and Set Cookie
 	token, err := c.services.GenearateToken(user)
@@ -455,3 +466,62 @@ func extractTextFromImage(filename string) string {
 	// This is just a placeholder
 	return strings.ToUpper(filename) // For demonstration, return the filename in uppercase
 }
+
+// SetCountry changes the country the signed in user is ranked under.
+func (c *controller) SetCountry(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.Country
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	country, err := c.services.SetCountry(ctx.GetString("userId"), reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, country)
+}
+
+// SetAccountType makes the user of the path an admin or a regular user.
+func (c *controller) SetAccountType(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.AccountType
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	user, err := c.services.SetAccountType(ctx.Param("userId"), reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, user)
+}
+
+// SearchUsers returns a page of the users whose username starts with the q query param.
+func (c *controller) SearchUsers(ctx *gin.Context) {
+	query, ok := pageQuery(ctx, 20)
+	if !ok {
+		return
+	}
+	page, err := c.services.SearchUsers(ctx.Query("q"), query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	pageLinks(ctx, &page.Cursors)
+	ctx.JSON(http.StatusOK, page)
+}
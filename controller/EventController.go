@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// heartbeatInterval keeps idle streams open through proxies that cut silent connections
+const heartbeatInterval = 15 * time.Second
+
+// EventController defines the methods for streaming leaderboard rank changes.
+type EventController interface {
+	Stream(ctx *gin.Context)
+	Socket(ctx *gin.Context)
+}
+
+// eventcontroller is the implementation of EventController.
+type eventcontroller struct {
+	services services.EventService
+}
+
+// NewEventController creates a new instance of EventController.
+func NewEventController(services services.EventService) EventController {
+	return &eventcontroller{
+		services: services,
+	}
+}
+
+// Stream sends the rank changes of a leaderboard as Server-Sent Events.
+func (c *eventcontroller) Stream(ctx *gin.Context) {
+	filter, ok := eventFilter(ctx)
+	if !ok {
+		return
+	}
+	sub, err := c.services.Subscribe(filter)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer c.services.Unsubscribe(sub)
+
+	ctx.Header("Content-Type", sse.ContentType)
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			sse.Encode(ctx.Writer, sse.Event{Event: "heartbeat", Data: time.Now().Unix()})
+		case event, open := <-sub.C:
+			if !open {
+				// the client was too slow, it resumes from the last id it got
+				sse.Encode(ctx.Writer, sse.Event{Event: "error", Data: gin.H{"error": sub.Err().Error()}})
+				ctx.Writer.Flush()
+				return
+			}
+			sse.Encode(ctx.Writer, sse.Event{
+				Id:    strconv.FormatUint(event.Id, 10),
+				Event: event.Type,
+				Data:  event,
+			})
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// Socket sends the rank changes of a leaderboard over a WebSocket.
+func (c *eventcontroller) Socket(ctx *gin.Context) {
+	filter, ok := eventFilter(ctx)
+	if !ok {
+		return
+	}
+	sub, err := c.services.Subscribe(filter)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	defer c.services.Unsubscribe(sub)
+
+	// the stream is public board data, so any origin may open it
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		// the client only listens, a read returning means it went away
+		gone := make(chan struct{})
+		go func() {
+			var discard []byte
+			for websocket.Message.Receive(ws, &discard) == nil {
+			}
+			close(gone)
+		}()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			var err error
+			select {
+			case <-gone:
+				return
+			case <-heartbeat.C:
+				err = websocket.JSON.Send(ws, gin.H{"type": "heartbeat", "at": time.Now().Unix()})
+			case event, open := <-sub.C:
+				if !open {
+					websocket.JSON.Send(ws, gin.H{"type": "error", "error": sub.Err().Error()})
+					return
+				}
+				err = websocket.JSON.Send(ws, event)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}}
+	server.ServeHTTP(ctx.Writer, ctx.Request)
+}
+
+// eventFilter reads the board id, the window, top and user_id filters and the last event id of a stream.
+func eventFilter(ctx *gin.Context) (entity.EventFilter, bool) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return entity.EventFilter{}, false
+	}
+	var filter entity.EventFilter
+	if err := ctx.ShouldBindQuery(&filter); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return entity.EventFilter{}, false
+	}
+	filter.LeaderboardId = id
+	// browsers send the last id they saw when they reconnect
+	if lastId, err := strconv.ParseUint(ctx.GetHeader("Last-Event-ID"), 10, 64); err == nil {
+		filter.LastEventId = lastId
+	}
+	return filter, true
+}
@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// FriendController defines the methods for friends, blocks and the friends view of the leaderboards.
+type FriendController interface {
+	SendRequest(ctx *gin.Context)
+	Respond(ctx *gin.Context)
+	ListRequests(ctx *gin.Context)
+	ListFriends(ctx *gin.Context)
+	MutualFriends(ctx *gin.Context)
+	RemoveFriend(ctx *gin.Context)
+	Block(ctx *gin.Context)
+	Unblock(ctx *gin.Context)
+	ListBlocked(ctx *gin.Context)
+	FriendEntries(ctx *gin.Context)
+}
+
+// friendcontroller is the implementation of FriendController.
+type friendcontroller struct {
+	services services.FriendService
+}
+
+// NewFriendController creates a new instance of FriendController.
+func NewFriendController(services services.FriendService) FriendController {
+	return &friendcontroller{
+		services: services,
+	}
+}
+
+// SendRequest sends a friend request from the logged in user.
+func (c *friendcontroller) SendRequest(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.NewFriendRequest
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	req, err := c.services.SendRequest(ctx.GetString("userId"), reqBody.UserId)
+	if err != nil {
+		ctx.JSON(friendErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, req)
+}
+
+// Respond accepts or declines the :requestId friend request.
+func (c *friendcontroller) Respond(ctx *gin.Context) {
+	id, ok := pathId(ctx, "requestId")
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.FriendResponse
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	req, err := c.services.Respond(id, ctx.GetString("userId"), reqBody.Accept)
+	if err != nil {
+		ctx.JSON(friendErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, req)
+}
+
+// ListRequests returns the pending friend requests of the logged in user.
+func (c *friendcontroller) ListRequests(ctx *gin.Context) {
+	requests, err := c.services.ListRequests(ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, requests)
+}
+
+// ListFriends returns the friends of the logged in user.
+func (c *friendcontroller) ListFriends(ctx *gin.Context) {
+	friends, err := c.services.ListFriends(ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, friends)
+}
+
+// MutualFriends returns the friends the logged in user shares with :userId.
+func (c *friendcontroller) MutualFriends(ctx *gin.Context) {
+	friends, err := c.services.MutualFriends(ctx.GetString("userId"), ctx.Param("userId"))
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, friends)
+}
+
+// RemoveFriend ends the friendship of the logged in user with :userId.
+func (c *friendcontroller) RemoveFriend(ctx *gin.Context) {
+	if err := c.services.RemoveFriend(ctx.GetString("userId"), ctx.Param("userId")); err != nil {
+		ctx.JSON(friendErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Friend removed"})
+}
+
+// Block blocks :userId for the logged in user.
+func (c *friendcontroller) Block(ctx *gin.Context) {
+	if err := c.services.Block(ctx.GetString("userId"), ctx.Param("userId")); err != nil {
+		ctx.JSON(friendErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Player blocked"})
+}
+
+// Unblock lifts the block the logged in user put on :userId.
+func (c *friendcontroller) Unblock(ctx *gin.Context) {
+	if err := c.services.Unblock(ctx.GetString("userId"), ctx.Param("userId")); err != nil {
+		ctx.JSON(friendErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Player unblocked"})
+}
+
+// ListBlocked returns the players the logged in user blocked.
+func (c *friendcontroller) ListBlocked(ctx *gin.Context) {
+	blocked, err := c.services.ListBlocked(ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, blocked)
+}
+
+// FriendEntries ranks the logged in user and their friends on the :id leaderboard.
+func (c *friendcontroller) FriendEntries(ctx *gin.Context) {
+	query, ok := leaderboardQuery(ctx)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(ctx, "limit", 50, 1, services.MaxFriends+1)
+	if !ok {
+		return
+	}
+	query.Limit = limit
+
+	entries, err := c.services.FriendEntries(query, ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(friendErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// friendErrorStatus maps the errors of the friend services to a status code.
+func friendErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBlocked):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrAlreadyFriends):
+		return http.StatusConflict
+	}
+	return errorStatus(err)
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// HistoryController defines the methods for reading the submission history of players.
+type HistoryController interface {
+	History(ctx *gin.Context)
+	MyHistory(ctx *gin.Context)
+	PersonalBests(ctx *gin.Context)
+}
+
+// historycontroller is the implementation of HistoryController.
+type historycontroller struct {
+	services services.HistoryService
+}
+
+// NewHistoryController creates a new instance of HistoryController.
+func NewHistoryController(services services.HistoryService) HistoryController {
+	return &historycontroller{
+		services: services,
+	}
+}
+
+// History returns the counted submissions of the :userId user, paged with ?cursor=.
+func (c *historycontroller) History(ctx *gin.Context) {
+	query, ok := historyQuery(ctx)
+	if !ok {
+		return
+	}
+	query.UserId = ctx.Param("userId")
+	c.page(ctx, query)
+}
+
+// MyHistory returns every submission of the logged in user, the flagged and rejected ones included.
+func (c *historycontroller) MyHistory(ctx *gin.Context) {
+	query, ok := historyQuery(ctx)
+	if !ok {
+		return
+	}
+	query.UserId = ctx.GetString("userId")
+	query.All = true
+	c.page(ctx, query)
+}
+
+// PersonalBests returns the personal best progression of the :userId user.
+func (c *historycontroller) PersonalBests(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	bests, err := c.services.PersonalBests(id, ctx.Param("userId"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, bests)
+}
+
+func (c *historycontroller) page(ctx *gin.Context, query entity.HistoryQuery) {
+	page, err := c.services.History(query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	pageLinks(ctx, &page.Cursors)
+	ctx.JSON(http.StatusOK, page)
+}
+
+// historyQuery reads the board id with the cursor and limit query params.
+func historyQuery(ctx *gin.Context) (entity.HistoryQuery, bool) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return entity.HistoryQuery{}, false
+	}
+	var query entity.HistoryQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return entity.HistoryQuery{}, false
+	}
+	limit, ok := queryInt(ctx, "limit", 20, 1, services.MaxPageSize)
+	if !ok {
+		return entity.HistoryQuery{}, false
+	}
+	query.LeaderboardId = id
+	query.Limit = limit
+	return query, true
+}
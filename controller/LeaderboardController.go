@@ -0,0 +1,439 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LeaderboardController defines the methods for handling leaderboard operations.
+type LeaderboardController interface {
+	CreateLeaderboard(ctx *gin.Context)
+	ListLeaderboards(ctx *gin.Context)
+	GetLeaderboard(ctx *gin.Context)
+	UpdateRules(ctx *gin.Context)
+	SubmitScore(ctx *gin.Context)
+	SubmitBatch(ctx *gin.Context)
+	TopEntries(ctx *gin.Context)
+	Export(ctx *gin.Context)
+	Import(ctx *gin.Context)
+	ListPeriods(ctx *gin.Context)
+	AroundMe(ctx *gin.Context)
+	EntryAtRank(ctx *gin.Context)
+	UserRank(ctx *gin.Context)
+}
+
+// leaderboardcontroller is the implementation of LeaderboardController.
+type leaderboardcontroller struct {
+	services services.LeaderboardService
+}
+
+// NewLeaderboardController creates a new instance of LeaderboardController.
+func NewLeaderboardController(services services.LeaderboardService) LeaderboardController {
+	return &leaderboardcontroller{
+		services: services,
+	}
+}
+
+// CreateLeaderboard creates a new leaderboard definition.
+func (c *leaderboardcontroller) CreateLeaderboard(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.Leaderboard
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	board, err := c.services.CreateLeaderboard(reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, board)
+}
+
+// ListLeaderboards returns all the leaderboards.
+func (c *leaderboardcontroller) ListLeaderboards(ctx *gin.Context) {
+	boards, err := c.services.ListLeaderboards()
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, boards)
+}
+
+// GetLeaderboard returns a single leaderboard definition.
+func (c *leaderboardcontroller) GetLeaderboard(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	board, err := c.services.FindLeaderboard(id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, board)
+}
+
+// SubmitScore applies a score for the signed in user.
+func (c *leaderboardcontroller) SubmitScore(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.ScoreSubmission
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	// the board comes from the url and the user from the auth middleware
+	reqBody.LeaderboardId = id
+	reqBody.UserId = ctx.GetString("userId")
+
+	entry, err := c.services.SubmitScore(reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	// a flagged or pending score is kept but waits for a moderator
+	if entry.Status != services.SubmissionAccepted {
+		ctx.JSON(http.StatusAccepted, entry)
+		return
+	}
+	ctx.JSON(http.StatusCreated, entry)
+}
+
+// SubmitBatch records the scores a game server sends for many players and boards at once. An
+// atomic batch that was not applied answers 422 with the report of the scores that failed.
+func (c *leaderboardcontroller) SubmitBatch(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.BatchSubmission
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	report, err := c.services.SubmitBatch(reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if report.Atomic && report.Failed > 0 {
+		ctx.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+	ctx.JSON(http.StatusOK, report)
+}
+
+// UpdateRules replaces the plausibility rules of a leaderboard.
+func (c *leaderboardcontroller) UpdateRules(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.PlausibilityRules
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	board, err := c.services.UpdateRules(id, reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, board)
+}
+
+// TopEntries returns a page of a leaderboard, the next and prev links carry the cursors of the pages around it.
+func (c *leaderboardcontroller) TopEntries(ctx *gin.Context) {
+	query, ok := leaderboardQuery(ctx)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(ctx, "limit", 10, 1, services.MaxPageSize)
+	if !ok {
+		return
+	}
+	query.Limit = limit
+
+	page, err := c.services.TopEntries(query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	pageLinks(ctx, &page.Cursors)
+	ctx.JSON(http.StatusOK, page)
+}
+
+// Export streams a window, period or season of a leaderboard as CSV or NDJSON.
+func (c *leaderboardcontroller) Export(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	var query entity.ExportQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	query.LeaderboardId = id
+
+	export, err := c.services.Export(query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.Header("Content-Type", export.ContentType())
+	ctx.Header("Content-Disposition", `attachment; filename="`+export.Filename()+`"`)
+	ctx.Status(http.StatusOK)
+	// the status is sent with the first rows, a failure past that point can only cut the file short
+	if err := export.Write(ctx.Writer); err != nil {
+		fmt.Println("Error exporting leaderboard:", err)
+	}
+}
+
+// MaxImportSize caps the body of an import.
+const MaxImportSize = 256 << 20
+
+// Import loads the scores in the body, CSV or NDJSON, and reports the rows that failed. With
+// ?dry_run=true nothing is written.
+func (c *leaderboardcontroller) Import(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	var req entity.ImportRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	// the format can come from the content type instead
+	if req.Format == "" && strings.Contains(ctx.ContentType(), "ndjson") {
+		req.Format = services.ExportNDJSON
+	}
+
+	body := http.MaxBytesReader(ctx.Writer, ctx.Request.Body, MaxImportSize)
+	report, err := c.services.Import(id, req, body)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	status := http.StatusOK
+	if !report.DryRun && !report.Committed {
+		status = http.StatusUnprocessableEntity
+	}
+	ctx.JSON(status, report)
+}
+
+// ListPeriods returns the current and archived periods of a time window.
+func (c *leaderboardcontroller) ListPeriods(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	periods, err := c.services.ListPeriods(id, ctx.DefaultQuery("window", "daily"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, periods)
+}
+
+// AroundMe returns the signed in user's entry with the players just above and below.
+func (c *leaderboardcontroller) AroundMe(ctx *gin.Context) {
+	query, ok := leaderboardQuery(ctx)
+	if !ok {
+		return
+	}
+	k, ok := queryInt(ctx, "k", 5, 0, 50)
+	if !ok {
+		return
+	}
+	entries, err := c.services.AroundUser(query, ctx.GetString("userId"), k)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// EntryAtRank returns the entry at the :rank position.
+func (c *leaderboardcontroller) EntryAtRank(ctx *gin.Context) {
+	query, ok := leaderboardQuery(ctx)
+	if !ok {
+		return
+	}
+	rank, err := strconv.Atoi(ctx.Param("rank"))
+	if err != nil || rank < 1 {
+		ctx.JSON(400, gin.H{
+			"error": "Invalid rank",
+		})
+		return
+	}
+	entry, err := c.services.EntryAtRank(query, rank)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entry)
+}
+
+// UserRank returns the rank and percentile of the :userId user.
+func (c *leaderboardcontroller) UserRank(ctx *gin.Context) {
+	query, ok := leaderboardQuery(ctx)
+	if !ok {
+		return
+	}
+	rank, err := c.services.UserRank(query, ctx.Param("userId"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, rank)
+}
+
+// errorStatus maps the errors of the services to a status code: 403 for submissions refused by the
+// checks, 404 for a missing record, 400 for a bad request and 500 for anything else, so a failure is
+// not kept as the answer to a retry.
+func errorStatus(err error) int {
+	var rejection *services.RejectedError
+	var ban *services.BannedError
+	var invalid *services.InvalidError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &rejection) || errors.As(err, &ban):
+		return http.StatusForbidden
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return 404
+	case errors.As(err, &invalid) || errors.As(err, &tooLarge):
+		return 400
+	}
+	return http.StatusInternalServerError
+}
+
+// leaderboardQuery reads the board id with the window and period query params.
+func leaderboardQuery(ctx *gin.Context) (entity.LeaderboardQuery, bool) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return entity.LeaderboardQuery{}, false
+	}
+	var query entity.LeaderboardQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return entity.LeaderboardQuery{}, false
+	}
+	query.LeaderboardId = id
+	return query, true
+}
+
+// leaderboardId reads the :id path param, writing a 400 when it is not a number.
+func leaderboardId(ctx *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"error": "Invalid leaderboard id",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// queryInt reads an optional int query param and checks it is within min and max.
+func queryInt(ctx *gin.Context, name string, def, min, max int) (int, bool) {
+	raw := ctx.Query(name)
+	if raw == "" {
+		return def, true
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < min || value > max {
+		ctx.JSON(400, gin.H{
+			"error": "Invalid '" + name + "' parameter",
+		})
+		return 0, false
+	}
+	return value, true
+}
+
+// pageQuery reads the cursor and limit query params of a paged list.
+func pageQuery(ctx *gin.Context, def int) (entity.PageQuery, bool) {
+	limit, ok := queryInt(ctx, "limit", def, 1, services.MaxPageSize)
+	if !ok {
+		return entity.PageQuery{}, false
+	}
+	return entity.PageQuery{Cursor: ctx.Query("cursor"), Limit: limit}, true
+}
+
+// pageLinks turns the cursors of a page into links to the same list and repeats them in the Link header.
+func pageLinks(ctx *gin.Context, cursors *entity.Cursors) {
+	var header []string
+	for _, link := range []struct {
+		rel   string
+		value *string
+	}{{"next", &cursors.Next}, {"prev", &cursors.Prev}} {
+		if *link.value == "" {
+			continue
+		}
+		u := *ctx.Request.URL
+		query := u.Query()
+		query.Set("cursor", *link.value)
+		u.RawQuery = query.Encode()
+		*link.value = u.RequestURI()
+		header = append(header, "<"+*link.value+">; rel=\""+link.rel+"\"")
+	}
+	if len(header) > 0 {
+		ctx.Header("Link", strings.Join(header, ", "))
+	}
+}
@@ -0,0 +1,288 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ModerationController defines the methods for the review queue of score submissions.
+type ModerationController interface {
+	ListQueue(ctx *gin.Context)
+	Approve(ctx *gin.Context)
+	Reject(ctx *gin.Context)
+	BulkReview(ctx *gin.Context)
+	RemoveEntries(ctx *gin.Context)
+	RestoreEntries(ctx *gin.Context)
+	ListRemovals(ctx *gin.Context)
+	Ban(ctx *gin.Context)
+	LiftBan(ctx *gin.Context)
+	ListBans(ctx *gin.Context)
+	AuditLog(ctx *gin.Context)
+}
+
+// moderationcontroller is the implementation of ModerationController.
+type moderationcontroller struct {
+	services services.ModerationService
+}
+
+// NewModerationController creates a new instance of ModerationController.
+func NewModerationController(services services.ModerationService) ModerationController {
+	return &moderationcontroller{
+		services: services,
+	}
+}
+
+// ListQueue returns the submissions of a leaderboard waiting for review, or those in the status query param.
+func (c *moderationcontroller) ListQueue(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	query, ok := pageQuery(ctx, 50)
+	if !ok {
+		return
+	}
+	page, err := c.services.ListQueue(id, ctx.Query("status"), query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	pageLinks(ctx, &page.Cursors)
+	ctx.JSON(http.StatusOK, page)
+}
+
+// Approve puts the :submissionId score on the board.
+func (c *moderationcontroller) Approve(ctx *gin.Context) {
+	c.review(ctx, true)
+}
+
+// Reject turns the :submissionId score down, a reason is required.
+func (c *moderationcontroller) Reject(ctx *gin.Context) {
+	c.review(ctx, false)
+}
+
+// BulkReview approves or rejects many submissions of a leaderboard at once.
+func (c *moderationcontroller) BulkReview(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.BulkReview
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	results, err := c.services.BulkReview(id, ctx.GetString("userId"), reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, results)
+}
+
+// RemoveEntries takes a player's entries off a leaderboard, or all of them.
+func (c *moderationcontroller) RemoveEntries(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.NewRemoval
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	removal, err := c.services.RemoveEntries(ctx.GetString("userId"), reqBody)
+	if err != nil {
+		ctx.JSON(moderationErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, removal)
+}
+
+// RestoreEntries puts back the entries taken by the :removalId removal.
+func (c *moderationcontroller) RestoreEntries(ctx *gin.Context) {
+	id, ok := pathId(ctx, "removalId")
+	if !ok {
+		return
+	}
+	reason, ok := reversalReason(ctx)
+	if !ok {
+		return
+	}
+	removal, err := c.services.RestoreEntries(id, ctx.GetString("userId"), reason)
+	if err != nil {
+		ctx.JSON(moderationErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, removal)
+}
+
+// ListRemovals returns the removals of the player in the user_id query param.
+func (c *moderationcontroller) ListRemovals(ctx *gin.Context) {
+	userId := ctx.Query("user_id")
+	if userId == "" {
+		ctx.JSON(400, gin.H{
+			"error": "user_id is required",
+		})
+		return
+	}
+	removals, err := c.services.ListRemovals(userId)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, removals)
+}
+
+// Ban stops a player from submitting to a leaderboard, or all of them, optionally as a shadow ban.
+func (c *moderationcontroller) Ban(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.NewBan
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ban, err := c.services.Ban(ctx.GetString("userId"), reqBody)
+	if err != nil {
+		ctx.JSON(moderationErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, ban)
+}
+
+// LiftBan ends the :banId ban.
+func (c *moderationcontroller) LiftBan(ctx *gin.Context) {
+	id, ok := pathId(ctx, "banId")
+	if !ok {
+		return
+	}
+	reason, ok := reversalReason(ctx)
+	if !ok {
+		return
+	}
+	ban, err := c.services.LiftBan(id, ctx.GetString("userId"), reason)
+	if err != nil {
+		ctx.JSON(moderationErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, ban)
+}
+
+// ListBans returns the bans of the player in the user_id query param.
+func (c *moderationcontroller) ListBans(ctx *gin.Context) {
+	userId := ctx.Query("user_id")
+	if userId == "" {
+		ctx.JSON(400, gin.H{
+			"error": "user_id is required",
+		})
+		return
+	}
+	bans, err := c.services.ListBans(userId)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, bans)
+}
+
+// AuditLog returns a page of the moderation actions, only those on the user_id query param when set.
+func (c *moderationcontroller) AuditLog(ctx *gin.Context) {
+	query, ok := pageQuery(ctx, 50)
+	if !ok {
+		return
+	}
+	page, err := c.services.AuditLog(ctx.Query("user_id"), query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	pageLinks(ctx, &page.Cursors)
+	ctx.JSON(http.StatusOK, page)
+}
+
+// reversalReason reads the optional body of a restore or a lifted ban.
+func reversalReason(ctx *gin.Context) (string, bool) {
+	var reqBody entity.Reversal
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.Bind(&reqBody); err != nil {
+			ctx.JSON(400, gin.H{
+				"error": err.Error(),
+			})
+			return "", false
+		}
+	}
+	return reqBody.Reason, true
+}
+
+// moderationErrorStatus maps the errors of the sanctions to a status code.
+func moderationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrAlreadyRestored), errors.Is(err, services.ErrAlreadyLifted):
+		return http.StatusConflict
+	}
+	return errorStatus(err)
+}
+
+func (c *moderationcontroller) review(ctx *gin.Context, approve bool) {
+	id, err := strconv.ParseUint(ctx.Param("submissionId"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"error": "Invalid submission id",
+		})
+		return
+	}
+	// Get the req body, it is optional for approvals
+	var reqBody entity.Review
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.Bind(&reqBody); err != nil {
+			ctx.JSON(400, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	reqBody.Approve = approve
+
+	sub, err := c.services.Review(uint(id), ctx.GetString("userId"), reqBody)
+	if err != nil {
+		status := errorStatus(err)
+		if errors.Is(err, services.ErrAlreadyReviewed) {
+			status = http.StatusConflict
+		}
+		ctx.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, sub)
+}
@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// NotificationController defines the methods for reading a user's notifications.
+type NotificationController interface {
+	List(ctx *gin.Context)
+	MarkRead(ctx *gin.Context)
+}
+
+// notificationcontroller is the implementation of NotificationController.
+type notificationcontroller struct {
+	services services.NotificationService
+}
+
+// NewNotificationController creates a new instance of NotificationController.
+func NewNotificationController(services services.NotificationService) NotificationController {
+	return &notificationcontroller{
+		services: services,
+	}
+}
+
+// List returns the notifications of the logged in user, ?unread=true keeps the unread ones.
+func (c *notificationcontroller) List(ctx *gin.Context) {
+	limit, ok := queryInt(ctx, "limit", 50, 1, 200)
+	if !ok {
+		return
+	}
+	notifications, err := c.services.List(ctx.GetString("userId"), ctx.Query("unread") == "true", limit)
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, notifications)
+}
+
+// MarkRead marks the :notificationId notification of the logged in user as read.
+func (c *notificationcontroller) MarkRead(ctx *gin.Context) {
+	id, err := strconv.ParseUint(ctx.Param("notificationId"), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"error": "Invalid notification id",
+		})
+		return
+	}
+	if err := c.services.MarkRead(ctx.GetString("userId"), uint(id)); err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Notification read"})
+}
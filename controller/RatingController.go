@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RatingController defines the methods for skill rating boards and match results.
+type RatingController interface {
+	CreateRatingBoard(ctx *gin.Context)
+	ListRatingBoards(ctx *gin.Context)
+	RecordMatch(ctx *gin.Context)
+	TopRatings(ctx *gin.Context)
+	UserRating(ctx *gin.Context)
+}
+
+// ratingcontroller is the implementation of RatingController.
+type ratingcontroller struct {
+	services services.RatingService
+}
+
+// NewRatingController creates a new instance of RatingController.
+func NewRatingController(services services.RatingService) RatingController {
+	return &ratingcontroller{
+		services: services,
+	}
+}
+
+// CreateRatingBoard creates a rating board.
+func (c *ratingcontroller) CreateRatingBoard(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.RatingBoard
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	board, err := c.services.CreateRatingBoard(reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, board)
+}
+
+// ListRatingBoards returns every rating board.
+func (c *ratingcontroller) ListRatingBoards(ctx *gin.Context) {
+	boards, err := c.services.ListRatingBoards()
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, boards)
+}
+
+// RecordMatch rates the participants of a finished match on the :ratingBoardId board.
+func (c *ratingcontroller) RecordMatch(ctx *gin.Context) {
+	id, ok := pathId(ctx, "ratingBoardId")
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.MatchResult
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	reqBody.RatingBoardId = id
+	match, err := c.services.RecordMatch(reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, match)
+}
+
+// TopRatings returns the highest rated players of the :ratingBoardId board.
+func (c *ratingcontroller) TopRatings(ctx *gin.Context) {
+	id, ok := pathId(ctx, "ratingBoardId")
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(ctx, "limit", 10, 1, 100)
+	if !ok {
+		return
+	}
+	ratings, err := c.services.TopRatings(id, limit)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, ratings)
+}
+
+// UserRating returns the rating and position of the :userId user on the :ratingBoardId board.
+func (c *ratingcontroller) UserRating(ctx *gin.Context) {
+	id, ok := pathId(ctx, "ratingBoardId")
+	if !ok {
+		return
+	}
+	player, err := c.services.UserRating(id, ctx.Param("userId"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, player)
+}
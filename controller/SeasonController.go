@@ -0,0 +1,144 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SeasonController defines the methods for handling leaderboard seasons.
+type SeasonController interface {
+	OpenSeason(ctx *gin.Context)
+	CloseSeason(ctx *gin.Context)
+	ListSeasons(ctx *gin.Context)
+	Standings(ctx *gin.Context)
+	MyPlacement(ctx *gin.Context)
+}
+
+// seasoncontroller is the implementation of SeasonController.
+type seasoncontroller struct {
+	services services.SeasonService
+}
+
+// NewSeasonController creates a new instance of SeasonController.
+func NewSeasonController(services services.SeasonService) SeasonController {
+	return &seasoncontroller{
+		services: services,
+	}
+}
+
+// OpenSeason starts the next season of a leaderboard.
+func (c *seasoncontroller) OpenSeason(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.OpenSeason
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	season, err := c.services.OpenSeason(id, reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, season)
+}
+
+// CloseSeason closes the running season and freezes its standings.
+func (c *seasoncontroller) CloseSeason(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	season, err := c.services.CloseSeason(id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, season)
+}
+
+// ListSeasons returns the current and past seasons of a leaderboard.
+func (c *seasoncontroller) ListSeasons(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	seasons, err := c.services.ListSeasons(id)
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, seasons)
+}
+
+// Standings returns the top N of a season.
+func (c *seasoncontroller) Standings(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	number, ok := seasonNumber(ctx)
+	if !ok {
+		return
+	}
+	limit, ok := queryInt(ctx, "limit", 10, 1, 100)
+	if !ok {
+		return
+	}
+	standings, err := c.services.Standings(id, number, limit)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, standings)
+}
+
+// MyPlacement returns where the signed in user placed in a season.
+func (c *seasoncontroller) MyPlacement(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	number, ok := seasonNumber(ctx)
+	if !ok {
+		return
+	}
+	placement, err := c.services.Placement(id, number, ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, placement)
+}
+
+// seasonNumber reads the :number path param, writing a 400 when it is not a number.
+func seasonNumber(ctx *gin.Context) (int, bool) {
+	number, err := strconv.Atoi(ctx.Param("number"))
+	if err != nil || number < 1 {
+		ctx.JSON(400, gin.H{
+			"error": "Invalid season number",
+		})
+		return 0, false
+	}
+	return number, true
+}
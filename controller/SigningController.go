@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SigningController defines the methods for managing score signing keys.
+type SigningController interface {
+	RotateKey(ctx *gin.Context)
+	ListKeys(ctx *gin.Context)
+	RevokeKey(ctx *gin.Context)
+	ListRejections(ctx *gin.Context)
+}
+
+// signingcontroller is the implementation of SigningController.
+type signingcontroller struct {
+	services services.SigningService
+}
+
+// NewSigningController creates a new instance of SigningController.
+func NewSigningController(services services.SigningService) SigningController {
+	return &signingcontroller{
+		services: services,
+	}
+}
+
+// RotateKey creates a new signing key for the :game, the secret is only returned here.
+func (c *signingcontroller) RotateKey(ctx *gin.Context) {
+	// Get the req body, it is optional
+	var reqBody entity.RotateKey
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.Bind(&reqBody); err != nil {
+			ctx.JSON(400, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	key, err := c.services.RotateKey(ctx.Param("game"), reqBody)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, key)
+}
+
+// ListKeys returns the signing keys of the :game.
+func (c *signingcontroller) ListKeys(ctx *gin.Context) {
+	keys, err := c.services.ListKeys(ctx.Param("game"))
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, keys)
+}
+
+// RevokeKey disables a signing key immediately.
+func (c *signingcontroller) RevokeKey(ctx *gin.Context) {
+	if err := c.services.RevokeKey(ctx.Param("game"), ctx.Param("keyId")); err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Key revoked"})
+}
+
+// ListRejections returns the latest rejected submissions of a leaderboard.
+func (c *signingcontroller) ListRejections(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	query, ok := pageQuery(ctx, 50)
+	if !ok {
+		return
+	}
+	page, err := c.services.ListRejections(id, query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	pageLinks(ctx, &page.Cursors)
+	ctx.JSON(http.StatusOK, page)
+}
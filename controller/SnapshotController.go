@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// SnapshotController defines the methods for rank snapshots and the deltas counted from them.
+type SnapshotController interface {
+	TakeSnapshot(ctx *gin.Context)
+	ListSnapshots(ctx *gin.Context)
+	Deltas(ctx *gin.Context)
+	Timeline(ctx *gin.Context)
+}
+
+// snapshotcontroller is the implementation of SnapshotController.
+type snapshotcontroller struct {
+	services services.SnapshotService
+}
+
+// NewSnapshotController creates a new instance of SnapshotController.
+func NewSnapshotController(services services.SnapshotService) SnapshotController {
+	return &snapshotcontroller{
+		services: services,
+	}
+}
+
+// TakeSnapshot snapshots the ranks of every current window of a leaderboard without waiting for the schedule.
+func (c *snapshotcontroller) TakeSnapshot(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	snapshots, err := c.services.Snapshot(id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, snapshots)
+}
+
+// ListSnapshots returns the latest snapshots of the ?window= window.
+func (c *snapshotcontroller) ListSnapshots(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	snapshots, err := c.services.ListSnapshots(id, ctx.Query("window"))
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, snapshots)
+}
+
+// Deltas returns a page of a leaderboard with the places each player moved since the latest snapshot,
+// or since the one in place at ?since=.
+func (c *snapshotcontroller) Deltas(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	var query entity.DeltaQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	limit, ok := queryInt(ctx, "limit", 10, 1, services.MaxPageSize)
+	if !ok {
+		return
+	}
+	query.LeaderboardId = id
+	query.Limit = limit
+
+	page, err := c.services.Deltas(query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	pageLinks(ctx, &page.Cursors)
+	ctx.JSON(http.StatusOK, page)
+}
+
+// Timeline returns the rank of the :userId user in each snapshot between ?from= and ?to=, ready to chart.
+func (c *snapshotcontroller) Timeline(ctx *gin.Context) {
+	id, ok := leaderboardId(ctx)
+	if !ok {
+		return
+	}
+	var query entity.TimelineQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	query.LeaderboardId = id
+	query.UserId = ctx.Param("userId")
+
+	timeline, err := c.services.Timeline(query)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, timeline)
+}
@@ -0,0 +1,280 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// TeamController defines the methods for teams and team leaderboards.
+type TeamController interface {
+	CreateTeam(ctx *gin.Context)
+	GetTeam(ctx *gin.Context)
+	Invite(ctx *gin.Context)
+	RequestJoin(ctx *gin.Context)
+	ListRequests(ctx *gin.Context)
+	MyInvites(ctx *gin.Context)
+	Respond(ctx *gin.Context)
+	SetRole(ctx *gin.Context)
+	RemoveMember(ctx *gin.Context)
+	CreateTeamBoard(ctx *gin.Context)
+	TeamEntries(ctx *gin.Context)
+}
+
+// teamcontroller is the implementation of TeamController.
+type teamcontroller struct {
+	services services.TeamService
+}
+
+// NewTeamController creates a new instance of TeamController.
+func NewTeamController(services services.TeamService) TeamController {
+	return &teamcontroller{
+		services: services,
+	}
+}
+
+// CreateTeam creates a team owned by the logged in user.
+func (c *teamcontroller) CreateTeam(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.Team
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	team, err := c.services.CreateTeam(ctx.GetString("userId"), reqBody)
+	if err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, team)
+}
+
+// GetTeam returns the :teamId team with its members.
+func (c *teamcontroller) GetTeam(ctx *gin.Context) {
+	id, ok := pathId(ctx, "teamId")
+	if !ok {
+		return
+	}
+	team, err := c.services.FindTeam(id)
+	if err != nil {
+		ctx.JSON(errorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, team)
+}
+
+// Invite invites a player to the :teamId team.
+func (c *teamcontroller) Invite(ctx *gin.Context) {
+	id, ok := pathId(ctx, "teamId")
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.TeamInvite
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	req, err := c.services.Invite(id, ctx.GetString("userId"), reqBody.UserId)
+	if err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, req)
+}
+
+// RequestJoin asks the :teamId team to let the logged in user in.
+func (c *teamcontroller) RequestJoin(ctx *gin.Context) {
+	id, ok := pathId(ctx, "teamId")
+	if !ok {
+		return
+	}
+	req, err := c.services.RequestJoin(id, ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, req)
+}
+
+// ListRequests returns the pending invites and join requests of the :teamId team.
+func (c *teamcontroller) ListRequests(ctx *gin.Context) {
+	id, ok := pathId(ctx, "teamId")
+	if !ok {
+		return
+	}
+	requests, err := c.services.ListRequests(id, ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, requests)
+}
+
+// MyInvites returns the pending team invites of the logged in user.
+func (c *teamcontroller) MyInvites(ctx *gin.Context) {
+	invites, err := c.services.MyInvites(ctx.GetString("userId"))
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, invites)
+}
+
+// Respond accepts or declines the :requestId invite or join request.
+func (c *teamcontroller) Respond(ctx *gin.Context) {
+	id, ok := pathId(ctx, "requestId")
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.TeamResponse
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	req, err := c.services.Respond(id, ctx.GetString("userId"), reqBody.Accept)
+	if err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, req)
+}
+
+// SetRole changes the role of the :userId member of the :teamId team.
+func (c *teamcontroller) SetRole(ctx *gin.Context) {
+	id, ok := pathId(ctx, "teamId")
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.TeamRole
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := c.services.SetRole(id, ctx.GetString("userId"), ctx.Param("userId"), reqBody.Role); err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// RemoveMember takes the :userId member out of the :teamId team, members can remove themselves.
+func (c *teamcontroller) RemoveMember(ctx *gin.Context) {
+	id, ok := pathId(ctx, "teamId")
+	if !ok {
+		return
+	}
+	if err := c.services.RemoveMember(id, ctx.GetString("userId"), ctx.Param("userId")); err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// CreateTeamBoard creates a leaderboard ranking teams on a player leaderboard.
+func (c *teamcontroller) CreateTeamBoard(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.TeamBoard
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	board, err := c.services.CreateTeamBoard(reqBody)
+	if err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, board)
+}
+
+// TeamEntries returns the top teams of the :teamBoardId team board.
+func (c *teamcontroller) TeamEntries(ctx *gin.Context) {
+	id, ok := pathId(ctx, "teamBoardId")
+	if !ok {
+		return
+	}
+	var query entity.LeaderboardQuery
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	limit, ok := queryInt(ctx, "limit", 10, 1, 100)
+	if !ok {
+		return
+	}
+	query.Limit = limit
+	entries, err := c.services.TeamEntries(id, query)
+	if err != nil {
+		ctx.JSON(teamErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, entries)
+}
+
+// pathId reads a numeric id from the named path param.
+func pathId(ctx *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(ctx.Param(name), 10, 64)
+	if err != nil {
+		ctx.JSON(400, gin.H{
+			"error": "Invalid '" + name + "' parameter",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// teamErrorStatus maps the errors of the team services to a status code.
+func teamErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrNotTeamOfficer):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrAlreadyInTeam):
+		return http.StatusConflict
+	}
+	return errorStatus(err)
+}
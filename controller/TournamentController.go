@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// TournamentController defines the methods for tournaments and their brackets.
+type TournamentController interface {
+	CreateTournament(ctx *gin.Context)
+	ListTournaments(ctx *gin.Context)
+	GetTournament(ctx *gin.Context)
+	ReportResult(ctx *gin.Context)
+	Standings(ctx *gin.Context)
+}
+
+// tournamentcontroller is the implementation of TournamentController.
+type tournamentcontroller struct {
+	services services.TournamentService
+}
+
+// NewTournamentController creates a new instance of TournamentController.
+func NewTournamentController(services services.TournamentService) TournamentController {
+	return &tournamentcontroller{
+		services: services,
+	}
+}
+
+// CreateTournament seeds a tournament and draws its first round.
+func (c *tournamentcontroller) CreateTournament(ctx *gin.Context) {
+	// Get the req body
+	var reqBody entity.Tournament
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	bracket, err := c.services.CreateTournament(reqBody)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusCreated, bracket)
+}
+
+// ListTournaments returns every tournament.
+func (c *tournamentcontroller) ListTournaments(ctx *gin.Context) {
+	tournaments, err := c.services.ListTournaments()
+	if err != nil {
+		ctx.JSON(500, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, tournaments)
+}
+
+// GetTournament returns the bracket state of the :tournamentId tournament.
+func (c *tournamentcontroller) GetTournament(ctx *gin.Context) {
+	id, ok := pathId(ctx, "tournamentId")
+	if !ok {
+		return
+	}
+	bracket, err := c.services.FindTournament(id)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, bracket)
+}
+
+// ReportResult records the result of the :matchId match and advances the bracket.
+func (c *tournamentcontroller) ReportResult(ctx *gin.Context) {
+	id, ok := pathId(ctx, "tournamentId")
+	if !ok {
+		return
+	}
+	matchId, ok := pathId(ctx, "matchId")
+	if !ok {
+		return
+	}
+	// Get the req body
+	var reqBody entity.MatchReport
+	// Parse the req body
+	if err := ctx.Bind(&reqBody); err != nil {
+		ctx.JSON(400, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	bracket, err := c.services.ReportResult(id, matchId, reqBody)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, bracket)
+}
+
+// Standings returns the placements of the :tournamentId tournament.
+func (c *tournamentcontroller) Standings(ctx *gin.Context) {
+	id, ok := pathId(ctx, "tournamentId")
+	if !ok {
+		return
+	}
+	standings, err := c.services.Standings(id)
+	if err != nil {
+		ctx.JSON(tournamentErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	ctx.JSON(http.StatusOK, standings)
+}
+
+// tournamentErrorStatus maps the errors of the tournament services to a status code.
+func tournamentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrTournamentFinished), errors.Is(err, services.ErrMatchNotReady):
+		return http.StatusConflict
+	}
+	return errorStatus(err)
+}
@@ -0,0 +1,28 @@
+package entity
+
+import "time"
+
+type Achievement struct {
+	ID            uint   `json:"id"`
+	Code          string `json:"code" binding:"required"`
+	Name          string `json:"name" binding:"required"`
+	Description   string `json:"description"`
+	LeaderboardId *uint  `json:"leaderboard_id"`
+	Kind          string `json:"kind" binding:"required"`
+	Window        string `json:"window"`
+	Rank          int    `json:"rank"`
+	Target        int    `json:"target"`
+}
+
+type AchievementProgress struct {
+	Achievement Achievement `json:"achievement"`
+	Progress    int         `json:"progress"`
+	AwardedAt   *time.Time  `json:"awarded_at,omitempty"`
+}
+
+type Badge struct {
+	Code        string    `json:"code"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	AwardedAt   time.Time `json:"awarded_at"`
+}
@@ -14,7 +14,7 @@ type LoginUser struct {
 }
 
 type UserId struct {
-	userId string
+	UserId string `json:"user_id" binding:"required"`
 }
 
 type User_Details struct {
@@ -24,6 +24,28 @@ type User_Details struct {
 	Twitter  string `json:"twitter"`
 	Discord  string `json:"discord"`
 	Google   string `json:"google"`
+	Country  string `json:"country"`
+	// Badges are read from the achievements, they are not a column
+	Badges []Badge `json:"badges,omitempty" gorm:"-"`
+}
+
+type UserSummary struct {
+	UserId   string `json:"user_id"`
+	Username string `json:"username"`
+	Country  string `json:"country,omitempty"`
+}
+
+type UserPage struct {
+	Users []UserSummary `json:"users"`
+	Cursors
+}
+
+type AccountType struct {
+	AccountType string `json:"account_type" binding:"required"`
+}
+
+type Country struct {
+	Country string `json:"country" binding:"required"`
 }
 
 type Avatar struct {
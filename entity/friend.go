@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+type FriendRequest struct {
+	ID        uint      `json:"id"`
+	FromId    string    `json:"from_id"`
+	ToId      string    `json:"to_id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type NewFriendRequest struct {
+	UserId string `json:"user_id" binding:"required"`
+}
+
+type FriendResponse struct {
+	Accept bool `json:"accept"`
+}
+
+type Friend struct {
+	UserId string    `json:"user_id"`
+	Since  time.Time `json:"since"`
+}
+
+type Blocked struct {
+	UserId    string    `json:"user_id"`
+	BlockedAt time.Time `json:"blocked_at"`
+}
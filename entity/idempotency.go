@@ -0,0 +1,8 @@
+package entity
+
+// StoredResponse is the response kept under an Idempotency-Key and replayed to retries
+type StoredResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
@@ -0,0 +1,230 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Leaderboard struct {
+	ID               uint              `json:"id"`
+	Name             string            `json:"name" binding:"required"`
+	Game             string            `json:"game" binding:"required"`
+	SortOrder        string            `json:"sort_order"`
+	Aggregation      string            `json:"aggregation"`
+	Format           string            `json:"format"`
+	Precision        int               `json:"precision"`
+	Timezone         string            `json:"timezone"`
+	WeekStart        string            `json:"week_start"`
+	TieBreak         string            `json:"tie_break"`
+	MetricOrder      string            `json:"metric_order"`
+	HistoryDays      int               `json:"history_days"`
+	RequiresApproval bool              `json:"requires_approval"`
+	Rules            PlausibilityRules `json:"rules"`
+}
+
+type PlausibilityRules struct {
+	MinScore       *float64 `json:"min_score"`
+	MaxScore       *float64 `json:"max_score"`
+	MaxImprovement *float64 `json:"max_improvement"`
+	MaxPerMinute   int      `json:"max_per_minute"`
+	MaxZScore      float64  `json:"max_z_score"`
+}
+
+type ScoreSubmission struct {
+	LeaderboardId uint            `json:"leaderboard_id"`
+	UserId        string          `json:"user_id"`
+	Score         float64         `json:"score"`
+	Metric        float64         `json:"metric"`
+	Timestamp     int64           `json:"timestamp"`
+	Nonce         string          `json:"nonce"`
+	KeyId         string          `json:"key_id"`
+	Signature     string          `json:"signature"`
+	EvidenceUrl   string          `json:"evidence_url"`
+	Metadata      json.RawMessage `json:"metadata"`
+}
+
+type LeaderboardEntry struct {
+	Rank           int       `json:"rank"`
+	Window         string    `json:"window"`
+	PeriodStart    time.Time `json:"period_start"`
+	UserId         string    `json:"user_id"`
+	Country        string    `json:"country,omitempty"`
+	Score          float64   `json:"score"`
+	FormattedScore string    `json:"formatted_score"`
+	Metric         float64   `json:"metric,omitempty"`
+	Submissions    int       `json:"submissions"`
+	SubmittedAt    time.Time `json:"submitted_at"`
+}
+
+type SubmissionResult struct {
+	SubmissionId  uint                        `json:"submission_id"`
+	LeaderboardId uint                        `json:"leaderboard_id"`
+	Status        string                      `json:"status"`
+	Flags         []string                    `json:"flags,omitempty"`
+	PersonalBest  bool                        `json:"personal_best"`
+	PreviousBest  *float64                    `json:"previous_best,omitempty"`
+	Entries       map[string]LeaderboardEntry `json:"entries"`
+}
+
+type Submission struct {
+	ID            uint            `json:"id"`
+	LeaderboardId uint            `json:"leaderboard_id"`
+	UserId        string          `json:"user_id"`
+	Score         float64         `json:"score"`
+	Metric        float64         `json:"metric,omitempty"`
+	Status        string          `json:"status"`
+	Flags         []string        `json:"flags"`
+	EvidenceUrl   string          `json:"evidence_url,omitempty"`
+	Metadata      json.RawMessage `json:"metadata,omitempty"`
+	PersonalBest  bool            `json:"personal_best"`
+	TeamId        *uint           `json:"team_id,omitempty"`
+	ReviewedBy    string          `json:"reviewed_by,omitempty"`
+	Reason        string          `json:"reason,omitempty"`
+	ReviewedAt    *time.Time      `json:"reviewed_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+type HistoryQuery struct {
+	LeaderboardId uint   `form:"-"`
+	UserId        string `form:"-"`
+	Cursor        string `form:"cursor"`
+	Limit         int    `form:"limit"`
+	// All includes the submissions that did not count, only the user may see those
+	All bool `form:"-"`
+}
+
+type SubmissionPage struct {
+	Submissions []Submission `json:"submissions"`
+	Cursors
+}
+
+type Review struct {
+	Approve bool   `json:"-"`
+	Reason  string `json:"reason"`
+}
+
+type BulkReview struct {
+	Ids     []uint `json:"ids" binding:"required"`
+	Approve bool   `json:"approve"`
+	Reason  string `json:"reason"`
+}
+
+type ReviewResult struct {
+	Id         uint        `json:"id"`
+	Submission *Submission `json:"submission,omitempty"`
+	Error      string      `json:"error,omitempty"`
+}
+
+type LeaderboardQuery struct {
+	LeaderboardId uint      `form:"-"`
+	Window        string    `form:"window"`
+	Period        time.Time `form:"period" time_format:"2006-01-02"`
+	Country       string    `form:"country"`
+	Cursor        string    `form:"cursor"`
+	Limit         int       `form:"limit"`
+}
+
+type ExportQuery struct {
+	LeaderboardQuery
+	Format string `form:"format"`
+	// Columns is a comma separated list of the columns to write
+	Columns string `form:"columns"`
+	// Season exports a season by number, the frozen standings once it closed
+	Season int `form:"season"`
+}
+
+type ImportRequest struct {
+	Format string `form:"format"`
+	DryRun bool   `form:"dry_run"`
+}
+
+type ImportError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun    bool `json:"dry_run"`
+	Committed bool `json:"committed"`
+	Rows      int  `json:"rows"`
+	Imported  int  `json:"imported"`
+	Failed    int  `json:"failed"`
+	// Errors lists the first failed rows, Failed counts them all
+	Errors []ImportError `json:"errors"`
+}
+
+type EntryPage struct {
+	Entries []LeaderboardEntry `json:"entries"`
+	Cursors
+}
+
+type LeaderboardPeriod struct {
+	Window      string            `json:"window"`
+	PeriodStart time.Time         `json:"period_start"`
+	PeriodEnd   time.Time         `json:"period_end"`
+	Archived    bool              `json:"archived"`
+	Winner      *LeaderboardEntry `json:"winner"`
+}
+
+type Season struct {
+	ID            uint       `json:"id"`
+	LeaderboardId uint       `json:"leaderboard_id"`
+	Number        int        `json:"number"`
+	Name          string     `json:"name"`
+	Status        string     `json:"status"`
+	CarryOver     float64    `json:"carry_over"`
+	StartedAt     time.Time  `json:"started_at"`
+	EndedAt       *time.Time `json:"ended_at"`
+}
+
+type OpenSeason struct {
+	Name string `json:"name"`
+	// CarryOver is the percentage of the last final scores the season starts from, sum boards only
+	CarryOver float64 `json:"carry_over"`
+}
+
+type UserRank struct {
+	Entry      LeaderboardEntry `json:"entry"`
+	Total      int              `json:"total"`
+	Percentile float64          `json:"percentile"`
+}
+
+type RankEvent struct {
+	Id             uint64    `json:"id"`
+	Type           string    `json:"type"`
+	LeaderboardId  uint      `json:"leaderboard_id"`
+	Window         string    `json:"window"`
+	UserId         string    `json:"user_id"`
+	Score          float64   `json:"score"`
+	FormattedScore string    `json:"formatted_score"`
+	Rank           int       `json:"rank"`
+	PreviousRank   int       `json:"previous_rank"`
+	At             time.Time `json:"at"`
+}
+
+type EventFilter struct {
+	LeaderboardId uint     `form:"-"`
+	Window        string   `form:"window"`
+	Top           int      `form:"top"`
+	UserIds       []string `form:"user_id"`
+	LastEventId   uint64   `form:"last_event_id"`
+}
+
+type BatchSubmission struct {
+	Scores []ScoreSubmission `json:"scores" binding:"required"`
+	// Atomic applies every score or none of them
+	Atomic bool `json:"atomic"`
+}
+
+type BatchResult struct {
+	Index  int               `json:"index"`
+	Result *SubmissionResult `json:"result,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+type BatchReport struct {
+	Atomic   bool          `json:"atomic"`
+	Accepted int           `json:"accepted"`
+	Failed   int           `json:"failed"`
+	Results  []BatchResult `json:"results"`
+}
@@ -0,0 +1,67 @@
+package entity
+
+import "time"
+
+type NewRemoval struct {
+	UserId string `json:"user_id" binding:"required"`
+	// LeaderboardId is 0 to remove the player's entries from every board
+	LeaderboardId uint   `json:"leaderboard_id"`
+	Reason        string `json:"reason" binding:"required"`
+}
+
+type Removal struct {
+	ID            uint       `json:"id"`
+	LeaderboardId *uint      `json:"leaderboard_id"`
+	UserId        string     `json:"user_id"`
+	Reason        string     `json:"reason"`
+	Entries       int        `json:"entries"`
+	RemovedBy     string     `json:"removed_by"`
+	RestoredBy    string     `json:"restored_by,omitempty"`
+	RestoredAt    *time.Time `json:"restored_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type NewBan struct {
+	UserId string `json:"user_id" binding:"required"`
+	// LeaderboardId is 0 to ban the player from every board
+	LeaderboardId uint   `json:"leaderboard_id"`
+	Reason        string `json:"reason" binding:"required"`
+	Shadow        bool   `json:"shadow"`
+	// ExpiresAt is empty for a ban that lasts until it is lifted
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type Ban struct {
+	ID            uint       `json:"id"`
+	LeaderboardId *uint      `json:"leaderboard_id"`
+	UserId        string     `json:"user_id"`
+	Reason        string     `json:"reason"`
+	Shadow        bool       `json:"shadow"`
+	Active        bool       `json:"active"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+	BannedBy      string     `json:"banned_by"`
+	LiftedBy      string     `json:"lifted_by,omitempty"`
+	LiftedAt      *time.Time `json:"lifted_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// Reversal is the optional body of a restore or a lifted ban
+type Reversal struct {
+	Reason string `json:"reason"`
+}
+
+type AuditEntry struct {
+	ID            uint      `json:"id"`
+	ModeratorId   string    `json:"moderator_id"`
+	Action        string    `json:"action"`
+	UserId        string    `json:"user_id"`
+	LeaderboardId *uint     `json:"leaderboard_id"`
+	TargetId      uint      `json:"target_id"`
+	Reason        string    `json:"reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Cursors
+}
@@ -0,0 +1,12 @@
+package entity
+
+import "time"
+
+type Notification struct {
+	ID           uint      `json:"id"`
+	Kind         string    `json:"kind"`
+	SubmissionId uint      `json:"submission_id,omitempty"`
+	Message      string    `json:"message"`
+	Read         bool      `json:"read"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package entity
+
+// Cursors hold the signed positions of the pages around a page, the controllers hand them out as links
+type Cursors struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+type PageQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+}
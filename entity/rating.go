@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+type RatingBoard struct {
+	ID          uint    `json:"id"`
+	Name        string  `json:"name" binding:"required"`
+	Game        string  `json:"game" binding:"required"`
+	Algorithm   string  `json:"algorithm"`
+	KFactor     float64 `json:"k_factor"`
+	Tau         float64 `json:"tau"`
+	PeriodHours int     `json:"period_hours"`
+}
+
+type PlayerRating struct {
+	Position     int        `json:"rank,omitempty"`
+	UserId       string     `json:"user_id"`
+	Rating       float64    `json:"rating"`
+	Deviation    float64    `json:"deviation"`
+	Volatility   float64    `json:"volatility"`
+	Matches      int        `json:"matches"`
+	Wins         int        `json:"wins"`
+	Losses       int        `json:"losses"`
+	Draws        int        `json:"draws"`
+	LastPlayedAt *time.Time `json:"last_played_at,omitempty"`
+}
+
+// MatchResult is a finished match, each participant has a placement or a win, loss or draw result
+type MatchResult struct {
+	RatingBoardId uint               `json:"-"`
+	Participants  []MatchParticipant `json:"participants" binding:"required"`
+}
+
+type MatchParticipant struct {
+	UserId          string  `json:"user_id" binding:"required"`
+	Team            string  `json:"team,omitempty"`
+	Placement       int     `json:"placement,omitempty"`
+	Result          string  `json:"result,omitempty"`
+	RatingBefore    float64 `json:"rating_before"`
+	RatingAfter     float64 `json:"rating_after"`
+	DeviationBefore float64 `json:"deviation_before"`
+	DeviationAfter  float64 `json:"deviation_after"`
+}
+
+type Match struct {
+	ID            uint               `json:"id"`
+	RatingBoardId uint               `json:"rating_board_id"`
+	Participants  []MatchParticipant `json:"participants"`
+	CreatedAt     time.Time          `json:"created_at"`
+}
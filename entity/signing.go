@@ -0,0 +1,31 @@
+package entity
+
+import "time"
+
+type GameKey struct {
+	Game      string     `json:"game"`
+	KeyId     string     `json:"key_id"`
+	Secret    string     `json:"secret,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RetiresAt *time.Time `json:"retires_at"`
+}
+
+type RotateKey struct {
+	GraceHours int `json:"grace_hours"`
+}
+
+type SubmissionRejection struct {
+	LeaderboardId uint      `json:"leaderboard_id"`
+	UserId        string    `json:"user_id"`
+	Reason        string    `json:"reason"`
+	Score         float64   `json:"score"`
+	KeyId         string    `json:"key_id"`
+	Nonce         string    `json:"nonce"`
+	Timestamp     int64     `json:"timestamp"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type RejectionPage struct {
+	Rejections []SubmissionRejection `json:"rejections"`
+	Cursors
+}
@@ -0,0 +1,59 @@
+package entity
+
+import "time"
+
+type RankSnapshot struct {
+	ID            uint      `json:"id"`
+	LeaderboardId uint      `json:"leaderboard_id"`
+	Window        string    `json:"window"`
+	PeriodStart   time.Time `json:"period_start"`
+	TakenAt       time.Time `json:"taken_at"`
+	Entries       int       `json:"entries"`
+}
+
+type DeltaQuery struct {
+	LeaderboardQuery
+	// Since compares against the last snapshot taken at that time instead of the latest one
+	Since time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type RankDelta struct {
+	LeaderboardEntry
+	// PreviousRank is empty when the player was not ranked in the snapshot
+	PreviousRank *int `json:"previous_rank"`
+	// Change is the number of places gained since the snapshot, negative for places lost
+	Change int  `json:"change"`
+	New    bool `json:"new"`
+}
+
+type DeltaPage struct {
+	// Snapshot is the one the deltas are counted against, the last one of the period before when this
+	// period has none that ranked anybody yet, and empty when there is neither
+	Snapshot *RankSnapshot `json:"snapshot"`
+	Entries  []RankDelta   `json:"entries"`
+	Cursors
+}
+
+type TimelineQuery struct {
+	LeaderboardId uint      `form:"-"`
+	UserId        string    `form:"-"`
+	Window        string    `form:"window"`
+	From          time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To            time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+}
+
+type TimelinePoint struct {
+	At          time.Time `json:"at"`
+	PeriodStart time.Time `json:"period_start"`
+	// Rank and Score are empty for the snapshots the player was not ranked in
+	Rank  *int     `json:"rank"`
+	Score *float64 `json:"score"`
+	Total int      `json:"total"`
+}
+
+type RankTimeline struct {
+	LeaderboardId uint            `json:"leaderboard_id"`
+	UserId        string          `json:"user_id"`
+	Window        string          `json:"window"`
+	Points        []TimelinePoint `json:"points"`
+}
@@ -0,0 +1,58 @@
+package entity
+
+import "time"
+
+type Team struct {
+	ID      uint         `json:"id"`
+	Name    string       `json:"name" binding:"required"`
+	Tag     string       `json:"tag"`
+	OwnerId string       `json:"owner_id"`
+	Members []TeamMember `json:"members,omitempty"`
+}
+
+type TeamMember struct {
+	UserId   string    `json:"user_id"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type TeamRequest struct {
+	ID        uint      `json:"id"`
+	TeamId    uint      `json:"team_id"`
+	UserId    string    `json:"user_id"`
+	Kind      string    `json:"kind"`
+	Status    string    `json:"status"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type TeamInvite struct {
+	UserId string `json:"user_id" binding:"required"`
+}
+
+type TeamRole struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type TeamResponse struct {
+	Accept bool `json:"accept"`
+}
+
+type TeamBoard struct {
+	ID            uint   `json:"id"`
+	LeaderboardId uint   `json:"leaderboard_id" binding:"required"`
+	Name          string `json:"name" binding:"required"`
+	Rule          string `json:"rule"`
+	TopK          int    `json:"top_k"`
+}
+
+type TeamEntry struct {
+	Rank           int       `json:"rank"`
+	TeamId         uint      `json:"team_id"`
+	TeamName       string    `json:"team_name"`
+	Window         string    `json:"window"`
+	PeriodStart    time.Time `json:"period_start"`
+	Score          float64   `json:"score"`
+	FormattedScore string    `json:"formatted_score"`
+	Members        int       `json:"members"`
+}
@@ -0,0 +1,54 @@
+package entity
+
+import "time"
+
+// Tournament is seeded from the top Size players of a leaderboard window or from Players in seed order
+type Tournament struct {
+	ID                  uint      `json:"id"`
+	Name                string    `json:"name" binding:"required"`
+	Format              string    `json:"format" binding:"required"`
+	LeaderboardId       *uint     `json:"leaderboard_id,omitempty"`
+	Window              string    `json:"window,omitempty"`
+	Size                int       `json:"size,omitempty"`
+	Players             []string  `json:"players,omitempty"`
+	Rounds              int       `json:"rounds"`
+	Round               int       `json:"round"`
+	Status              string    `json:"status"`
+	PointsLeaderboardId *uint     `json:"points_leaderboard_id,omitempty"`
+	Points              []float64 `json:"points,omitempty"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+type TournamentPlayer struct {
+	UserId    string  `json:"user_id"`
+	Seed      int     `json:"seed"`
+	Placement int     `json:"placement,omitempty"`
+	Wins      int     `json:"wins"`
+	Losses    int     `json:"losses"`
+	Draws     int     `json:"draws"`
+	Points    float64 `json:"points"`
+}
+
+type TournamentMatch struct {
+	ID       uint   `json:"id"`
+	Bracket  string `json:"bracket"`
+	Round    int    `json:"round"`
+	Number   int    `json:"number"`
+	PlayerA  string `json:"player_a"`
+	PlayerB  string `json:"player_b"`
+	WinnerId string `json:"winner_id,omitempty"`
+	Draw     bool   `json:"draw,omitempty"`
+	Status   string `json:"status"`
+}
+
+type TournamentBracket struct {
+	Tournament Tournament         `json:"tournament"`
+	Players    []TournamentPlayer `json:"players"`
+	Matches    []TournamentMatch  `json:"matches"`
+}
+
+// MatchReport is the result of a tournament match, a draw is only allowed in Swiss
+type MatchReport struct {
+	WinnerId string `json:"winner_id"`
+	Draw     bool   `json:"draw"`
+}
@@ -3,9 +3,11 @@ package main
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/JohnnyOhms/projectx/config"
 	"github.com/JohnnyOhms/projectx/controller"
+	"github.com/JohnnyOhms/projectx/middleware"
 	"github.com/JohnnyOhms/projectx/services"
 	"github.com/gin-gonic/gin"
 )
@@ -13,23 +15,187 @@ import (
 var (
 	AuthService    services.AuthService      = services.New()
 	AuthController controller.AuthController = controller.New(AuthService)
+
+	LeaderboardService     services.LeaderboardService       = services.NewLeaderboardService()
+	LeaderboardController  controller.LeaderboardController  = controller.NewLeaderboardController(LeaderboardService)
+	SeasonService          services.SeasonService            = services.NewSeasonService()
+	SeasonController       controller.SeasonController       = controller.NewSeasonController(SeasonService)
+	EventService           services.EventService             = services.NewEventService()
+	EventController        controller.EventController        = controller.NewEventController(EventService)
+	SigningService         services.SigningService           = services.NewSigningService()
+	SigningController      controller.SigningController      = controller.NewSigningController(SigningService)
+	ModerationService      services.ModerationService        = services.NewModerationService()
+	ModerationController   controller.ModerationController   = controller.NewModerationController(ModerationService)
+	NotificationService    services.NotificationService      = services.NewNotificationService()
+	NotificationController controller.NotificationController = controller.NewNotificationController(NotificationService)
+	HistoryService         services.HistoryService           = services.NewHistoryService()
+	HistoryController      controller.HistoryController      = controller.NewHistoryController(HistoryService)
+	TeamService            services.TeamService              = services.NewTeamService()
+	TeamController         controller.TeamController         = controller.NewTeamController(TeamService)
+	AchievementService     services.AchievementService       = services.NewAchievementService()
+	AchievementController  controller.AchievementController  = controller.NewAchievementController(AchievementService)
+	RatingService          services.RatingService            = services.NewRatingService()
+	RatingController       controller.RatingController       = controller.NewRatingController(RatingService)
+	TournamentService      services.TournamentService        = services.NewTournamentService()
+	TournamentController   controller.TournamentController   = controller.NewTournamentController(TournamentService)
+	FriendService          services.FriendService            = services.NewFriendService()
+	FriendController       controller.FriendController       = controller.NewFriendController(FriendService)
+	SnapshotService        services.SnapshotService          = services.NewSnapshotService()
+	SnapshotController     controller.SnapshotController     = controller.NewSnapshotController(SnapshotService)
+	IdempotencyService     services.IdempotencyService       = services.NewIdempotencyService()
 )
 
 func init() {
 	config.ConnectToDB()
 	config.SyncDB()
+	if err := services.SeedAdmins(); err != nil {
+		panic("failed to seed the admins: " + err.Error())
+	}
+	if err := services.LoadRankIndex(); err != nil {
+		panic("failed to load the rank index: " + err.Error())
+	}
 }
 
 func main() {
 	r := gin.Default()
 
+	// Archive the daily, weekly and monthly periods once they end, forget stale nonces and idempotency
+	// keys, lift expired bans and snapshot the ranks every SNAPSHOT_INTERVAL
+	go func() {
+		for range time.Tick(time.Minute) {
+			if err := LeaderboardService.ArchiveExpiredPeriods(); err != nil {
+				fmt.Println("Error archiving leaderboard periods:", err)
+			}
+			if err := SigningService.PruneNonces(); err != nil {
+				fmt.Println("Error pruning submission nonces:", err)
+			}
+			if err := ModerationService.LiftExpiredBans(); err != nil {
+				fmt.Println("Error lifting expired bans:", err)
+			}
+			if err := IdempotencyService.PruneKeys(); err != nil {
+				fmt.Println("Error pruning idempotency keys:", err)
+			}
+			if err := SnapshotService.TakeSnapshots(); err != nil {
+				fmt.Println("Error taking rank snapshots:", err)
+			}
+		}
+	}()
+
+	// Drop the submission history and rank snapshots past each board's retention and count the days held for rank streaks
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := HistoryService.PruneHistory(); err != nil {
+				fmt.Println("Error pruning submission history:", err)
+			}
+			if err := SnapshotService.PruneSnapshots(); err != nil {
+				fmt.Println("Error pruning rank snapshots:", err)
+			}
+			if err := AchievementService.EvaluateStreaks(); err != nil {
+				fmt.Println("Error evaluating achievement streaks:", err)
+			}
+		}
+	}()
+
+	// Retries of the mutating requests sent with an Idempotency-Key get the first response back
+	idempotent := middleware.Idempotent(IdempotencyService)
+
 	r.POST("/api/auth/register", AuthController.SignUpUser)
 	r.POST("/api/auth/login", AuthController.LoginUser)
 	r.POST("/api/auth/setdetails", AuthController.SetUserDetails)
 	r.POST("/api/auth/getdetails", AuthController.ReteriveUserDetails)
 	r.GET("/api/auth/discord/redirect", AuthController.DiscordAuth)
+	r.PUT("/api/auth/country", middleware.RequireAuth, idempotent, AuthController.SetCountry)
+	r.GET("/api/users", AuthController.SearchUsers)
+	r.PUT("/api/users/:userId/account-type", middleware.RequireAuth, middleware.RequireAdmin, idempotent, AuthController.SetAccountType)
 	r.POST("/api/upload", AuthController.UploadAvatar)
 
+	r.GET("/api/leaderboards", LeaderboardController.ListLeaderboards)
+	r.POST("/api/leaderboards", middleware.RequireAuth, middleware.RequireAdmin, idempotent, LeaderboardController.CreateLeaderboard)
+	r.GET("/api/leaderboards/:id", LeaderboardController.GetLeaderboard)
+	r.GET("/api/leaderboards/:id/entries", LeaderboardController.TopEntries)
+	r.GET("/api/leaderboards/:id/export", middleware.RequireAuth, LeaderboardController.Export)
+	r.POST("/api/leaderboards/:id/import", middleware.RequireAuth, middleware.RequireAdmin, middleware.IdempotentUpTo(IdempotencyService, controller.MaxImportSize), LeaderboardController.Import)
+	r.GET("/api/leaderboards/:id/periods", LeaderboardController.ListPeriods)
+	r.GET("/api/leaderboards/:id/around/me", middleware.RequireAuth, LeaderboardController.AroundMe)
+	r.GET("/api/leaderboards/:id/ranks/:rank", LeaderboardController.EntryAtRank)
+	r.GET("/api/leaderboards/:id/users/:userId/rank", LeaderboardController.UserRank)
+	r.GET("/api/leaderboards/:id/users/:userId/history", HistoryController.History)
+	r.GET("/api/leaderboards/:id/users/:userId/bests", HistoryController.PersonalBests)
+	r.GET("/api/leaderboards/:id/history/me", middleware.RequireAuth, HistoryController.MyHistory)
+	r.GET("/api/leaderboards/:id/deltas", SnapshotController.Deltas)
+	r.GET("/api/leaderboards/:id/users/:userId/timeline", SnapshotController.Timeline)
+	r.GET("/api/leaderboards/:id/snapshots", SnapshotController.ListSnapshots)
+	r.POST("/api/leaderboards/:id/snapshots", middleware.RequireAuth, middleware.RequireAdmin, idempotent, SnapshotController.TakeSnapshot)
+	r.GET("/api/leaderboards/:id/events", EventController.Stream)
+	r.GET("/api/leaderboards/:id/ws", EventController.Socket)
+	r.GET("/api/leaderboards/:id/rejections", middleware.RequireAuth, middleware.RequireAdmin, SigningController.ListRejections)
+	r.PUT("/api/leaderboards/:id/rules", middleware.RequireAuth, middleware.RequireAdmin, idempotent, LeaderboardController.UpdateRules)
+	r.GET("/api/leaderboards/:id/queue", middleware.RequireAuth, middleware.RequireAdmin, ModerationController.ListQueue)
+	r.POST("/api/leaderboards/:id/queue/bulk", middleware.RequireAuth, middleware.RequireAdmin, idempotent, ModerationController.BulkReview)
+	r.POST("/api/submissions/:submissionId/approve", middleware.RequireAuth, middleware.RequireAdmin, idempotent, ModerationController.Approve)
+	r.POST("/api/submissions/:submissionId/reject", middleware.RequireAuth, middleware.RequireAdmin, idempotent, ModerationController.Reject)
+	r.POST("/api/moderation/removals", middleware.RequireAuth, middleware.RequireAdmin, idempotent, ModerationController.RemoveEntries)
+	r.GET("/api/moderation/removals", middleware.RequireAuth, middleware.RequireAdmin, ModerationController.ListRemovals)
+	r.POST("/api/moderation/removals/:removalId/restore", middleware.RequireAuth, middleware.RequireAdmin, idempotent, ModerationController.RestoreEntries)
+	r.POST("/api/moderation/bans", middleware.RequireAuth, middleware.RequireAdmin, idempotent, ModerationController.Ban)
+	r.GET("/api/moderation/bans", middleware.RequireAuth, middleware.RequireAdmin, ModerationController.ListBans)
+	r.POST("/api/moderation/bans/:banId/lift", middleware.RequireAuth, middleware.RequireAdmin, idempotent, ModerationController.LiftBan)
+	r.GET("/api/moderation/audit", middleware.RequireAuth, middleware.RequireAdmin, ModerationController.AuditLog)
+
+	r.POST("/api/teams", middleware.RequireAuth, idempotent, TeamController.CreateTeam)
+	r.GET("/api/teams/:teamId", TeamController.GetTeam)
+	r.POST("/api/teams/:teamId/invites", middleware.RequireAuth, idempotent, TeamController.Invite)
+	r.POST("/api/teams/:teamId/requests", middleware.RequireAuth, idempotent, TeamController.RequestJoin)
+	r.GET("/api/teams/:teamId/requests", middleware.RequireAuth, TeamController.ListRequests)
+	r.PUT("/api/teams/:teamId/members/:userId/role", middleware.RequireAuth, idempotent, TeamController.SetRole)
+	r.DELETE("/api/teams/:teamId/members/:userId", middleware.RequireAuth, idempotent, TeamController.RemoveMember)
+	r.GET("/api/team-requests/me", middleware.RequireAuth, TeamController.MyInvites)
+	r.POST("/api/team-requests/:requestId/respond", middleware.RequireAuth, idempotent, TeamController.Respond)
+	r.POST("/api/team-boards", middleware.RequireAuth, middleware.RequireAdmin, idempotent, TeamController.CreateTeamBoard)
+	r.GET("/api/team-boards/:teamBoardId/entries", TeamController.TeamEntries)
+
+	r.GET("/api/friends", middleware.RequireAuth, FriendController.ListFriends)
+	r.GET("/api/friends/:userId/mutual", middleware.RequireAuth, FriendController.MutualFriends)
+	r.DELETE("/api/friends/:userId", middleware.RequireAuth, idempotent, FriendController.RemoveFriend)
+	r.POST("/api/friend-requests", middleware.RequireAuth, idempotent, FriendController.SendRequest)
+	r.GET("/api/friend-requests/me", middleware.RequireAuth, FriendController.ListRequests)
+	r.POST("/api/friend-requests/:requestId/respond", middleware.RequireAuth, idempotent, FriendController.Respond)
+	r.GET("/api/blocks", middleware.RequireAuth, FriendController.ListBlocked)
+	r.PUT("/api/blocks/:userId", middleware.RequireAuth, idempotent, FriendController.Block)
+	r.DELETE("/api/blocks/:userId", middleware.RequireAuth, idempotent, FriendController.Unblock)
+	r.GET("/api/leaderboards/:id/friends", middleware.RequireAuth, FriendController.FriendEntries)
+
+	r.GET("/api/rating-boards", RatingController.ListRatingBoards)
+	r.POST("/api/rating-boards", middleware.RequireAuth, middleware.RequireAdmin, idempotent, RatingController.CreateRatingBoard)
+	r.POST("/api/rating-boards/:ratingBoardId/matches", middleware.RequireAuth, middleware.RequireAdmin, idempotent, RatingController.RecordMatch)
+	r.GET("/api/rating-boards/:ratingBoardId/ratings", RatingController.TopRatings)
+	r.GET("/api/rating-boards/:ratingBoardId/users/:userId", RatingController.UserRating)
+
+	r.GET("/api/tournaments", TournamentController.ListTournaments)
+	r.POST("/api/tournaments", middleware.RequireAuth, middleware.RequireAdmin, idempotent, TournamentController.CreateTournament)
+	r.GET("/api/tournaments/:tournamentId", TournamentController.GetTournament)
+	r.GET("/api/tournaments/:tournamentId/standings", TournamentController.Standings)
+	r.POST("/api/tournaments/:tournamentId/matches/:matchId/result", middleware.RequireAuth, middleware.RequireAdmin, idempotent, TournamentController.ReportResult)
+
+	r.POST("/api/achievements", middleware.RequireAuth, middleware.RequireAdmin, idempotent, AchievementController.CreateAchievement)
+	r.GET("/api/achievements", AchievementController.ListAchievements)
+	r.GET("/api/users/:userId/achievements", AchievementController.UserAchievements)
+
+	r.GET("/api/notifications", middleware.RequireAuth, NotificationController.List)
+	r.POST("/api/notifications/:notificationId/read", middleware.RequireAuth, idempotent, NotificationController.MarkRead)
+
+	r.GET("/api/games/:game/keys", middleware.RequireAuth, middleware.RequireAdmin, SigningController.ListKeys)
+	r.POST("/api/games/:game/keys", middleware.RequireAuth, middleware.RequireAdmin, idempotent, SigningController.RotateKey)
+	r.DELETE("/api/games/:game/keys/:keyId", middleware.RequireAuth, middleware.RequireAdmin, idempotent, SigningController.RevokeKey)
+	r.POST("/api/leaderboards/:id/scores", middleware.RequireAuth, idempotent, LeaderboardController.SubmitScore)
+	r.POST("/api/scores/batch", middleware.RequireAuth, middleware.RequireAdmin, idempotent, LeaderboardController.SubmitBatch)
+
+	r.GET("/api/leaderboards/:id/seasons", SeasonController.ListSeasons)
+	r.POST("/api/leaderboards/:id/seasons", middleware.RequireAuth, middleware.RequireAdmin, idempotent, SeasonController.OpenSeason)
+	r.POST("/api/leaderboards/:id/seasons/close", middleware.RequireAuth, middleware.RequireAdmin, idempotent, SeasonController.CloseSeason)
+	r.GET("/api/leaderboards/:id/seasons/:number/standings", SeasonController.Standings)
+	r.GET("/api/leaderboards/:id/seasons/:number/me", middleware.RequireAuth, SeasonController.MyPlacement)
+
 	// Create the "avatar" directory if it doesn't exist
 	if err := os.MkdirAll("avatar", os.ModePerm); err != nil {
 		fmt.Println("Error creating 'avatar' directory:", err)
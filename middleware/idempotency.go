@@ -0,0 +1,169 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxIdempotentBody is the largest request body sent with an Idempotency-Key that is kept in memory,
+// and the largest one Idempotent takes
+const maxIdempotentBody = 1 << 20
+
+// errBodyTooLarge is returned for a body above the size an idempotent route takes
+var errBodyTooLarge = errors.New("request body too large")
+
+// Idempotent answers the retries of a request sent with an Idempotency-Key header with the response
+// to the first one. Keys are kept per user, so it must run after RequireAuth, a request without a
+// user is handled as if it had no key. Responses with a server error are not kept and the retry runs
+// again.
+func Idempotent(service services.IdempotencyService) gin.HandlerFunc {
+	return IdempotentUpTo(service, maxIdempotentBody)
+}
+
+// IdempotentUpTo is Idempotent for routes whose bodies can be up to maxBody bytes, the bodies above
+// maxIdempotentBody are kept in a temp file while the request runs
+func IdempotentUpTo(service services.IdempotencyService, maxBody int64) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader("Idempotency-Key")
+		userId := ctx.GetString("userId")
+		if key == "" || userId == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > 255 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+
+		hash := sha256.New()
+		hash.Write([]byte(ctx.Request.Method + " " + ctx.Request.URL.RequestURI() + "\n"))
+		cleanup, err := spoolBody(ctx, hash, maxBody)
+		if errors.Is(err, errBodyTooLarge) {
+			ctx.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+				"error": fmt.Sprintf("a request sent with an Idempotency-Key can be at most %d bytes", maxBody),
+			})
+			return
+		}
+		if err != nil {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		defer cleanup()
+
+		stored, err := service.Begin(userId, key, hex.EncodeToString(hash.Sum(nil)))
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, services.ErrKeyReused) {
+				status = http.StatusUnprocessableEntity
+			} else if errors.Is(err, services.ErrKeyInFlight) {
+				status = http.StatusConflict
+			}
+			ctx.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if stored != nil {
+			ctx.Header("Idempotent-Replayed", "true")
+			ctx.Data(stored.Status, stored.ContentType, stored.Body)
+			ctx.Abort()
+			return
+		}
+
+		// Release the key unless the response is kept, a panic included
+		kept := false
+		defer func() {
+			if !kept {
+				if err := service.Release(userId, key); err != nil {
+					fmt.Println("Error releasing idempotency key:", err)
+				}
+			}
+		}()
+		writer := &recordingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		ctx.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			return
+		}
+		err = service.Complete(userId, key, entity.StoredResponse{
+			Status:      writer.Status(),
+			ContentType: writer.Header().Get("Content-Type"),
+			Body:        writer.body.Bytes(),
+		})
+		if err != nil {
+			fmt.Println("Error storing idempotent response:", err)
+			return
+		}
+		kept = true
+	}
+}
+
+// spoolBody reads the request body into hash and puts a copy back for the handler, in memory up to
+// maxIdempotentBody and in a temp file above it. cleanup removes the temp file.
+func spoolBody(ctx *gin.Context, hash io.Writer, maxBody int64) (cleanup func(), err error) {
+	var buf bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(hash, &buf), io.LimitReader(ctx.Request.Body, maxIdempotentBody+1))
+	if err != nil {
+		return nil, err
+	}
+	if n > maxBody {
+		return nil, errBodyTooLarge
+	}
+	if n <= maxIdempotentBody {
+		ctx.Request.Body = io.NopCloser(&buf)
+		return func() {}, nil
+	}
+
+	f, err := os.CreateTemp("", "idempotent-body-*")
+	if err != nil {
+		return nil, err
+	}
+	cleanup = func() {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		cleanup()
+		return nil, err
+	}
+	rest, err := io.Copy(io.MultiWriter(hash, f), io.LimitReader(ctx.Request.Body, maxBody-n+1))
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if n+rest > maxBody {
+		cleanup()
+		return nil, errBodyTooLarge
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		cleanup()
+		return nil, err
+	}
+	ctx.Request.Body = io.NopCloser(f)
+	return cleanup, nil
+}
+
+// recordingWriter keeps a copy of the response body it writes
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,98 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/middleware"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+)
+
+// idempotentRouter serves a route that counts the requests it handles, the user comes from the User
+// header and a body of "fail" answers 500
+func idempotentRouter(t *testing.T) (*gin.Engine, *int) {
+	t.Helper()
+	testDB(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	handled := 0
+	auth := func(ctx *gin.Context) {
+		ctx.Set("userId", ctx.GetHeader("User"))
+	}
+	r.POST("/scores", auth, middleware.Idempotent(services.NewIdempotencyService()), func(ctx *gin.Context) {
+		body, _ := ctx.GetRawData()
+		if string(body) == "fail" {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed"})
+			return
+		}
+		handled++
+		ctx.JSON(http.StatusCreated, gin.H{"handled": handled})
+	})
+	return r, &handled
+}
+
+func post(r *gin.Engine, user, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/scores", strings.NewReader(body))
+	req.Header.Set("User", user)
+	req.Header.Set("Idempotency-Key", key)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotentReplay(t *testing.T) {
+	r, handled := idempotentRouter(t)
+
+	first := post(r, "a", "key", `{"score":10}`)
+	retry := post(r, "a", "key", `{"score":10}`)
+	if *handled != 1 {
+		t.Errorf("the retry was handled again, %d requests handled", *handled)
+	}
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry answered %d %s, want %d %s", retry.Code, retry.Body, first.Code, first.Body)
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("the replayed response is not marked Idempotent-Replayed")
+	}
+
+	// the same key with another body is refused
+	if w := post(r, "a", "key", `{"score":20}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused with another body: got %d, want 422", w.Code)
+	}
+	// keys are kept per user
+	if w := post(r, "b", "key", `{"score":10}`); w.Code != http.StatusCreated || *handled != 2 {
+		t.Errorf("another user's key: got %d %s, want a new response", w.Code, w.Body)
+	}
+}
+
+func TestIdempotentServerErrorRunsAgain(t *testing.T) {
+	r, _ := idempotentRouter(t)
+	if w := post(r, "a", "key", "fail"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("got %d, want 500", w.Code)
+	}
+	w := post(r, "a", "key", "fail")
+	if w.Code != http.StatusInternalServerError || w.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("a retry after a server error was replayed: %d %s", w.Code, w.Body)
+	}
+}
+
+func TestIdempotentKeyExpires(t *testing.T) {
+	r, handled := idempotentRouter(t)
+	post(r, "a", "key", `{"score":10}`)
+
+	// age the key past its lifetime
+	old := time.Now().Add(-25 * time.Hour)
+	if err := config.DB.Model(&model.IdempotencyKey{}).Where("1 = 1").Update("created_at", old).Error; err != nil {
+		t.Fatal(err)
+	}
+	w := post(r, "a", "key", `{"score":20}`)
+	if w.Code != http.StatusCreated || w.Body.String() != `{"handled":2}` || *handled != 2 {
+		t.Errorf("an expired key: got %d %s, want the request handled again", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RequireAuth checks the Authorization cookie and loads the signed in user
+func RequireAuth(ctx *gin.Context) {
+	// Get the cookie set by login
+	tokenString, err := ctx.Cookie("Authorization")
+	if err != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Decode and validate the token
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(os.Getenv("SECRET")), nil
+	})
+	if err != nil || !token.Valid {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	// Check the expiry
+	if exp, ok := claims["exp"].(float64); !ok || float64(time.Now().Unix()) > exp {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Token expired"})
+		return
+	}
+
+	// Find the user with the token sub
+	var user model.User
+	result := config.DB.Where("user_id = ?", claims["sub"]).First(&user)
+	if result.Error != nil {
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	// Attach to the request
+	ctx.Set("user", user)
+	ctx.Set("userId", user.UserId)
+	ctx.Next()
+}
+
+// RequireAdmin only lets admin accounts through, it must run after RequireAuth
+func RequireAdmin(ctx *gin.Context) {
+	user, ok := ctx.Get("user")
+	if !ok || user.(model.User).Account_Type != "admin" {
+		ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+	ctx.Next()
+}
@@ -0,0 +1,112 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/controller"
+	"github.com/JohnnyOhms/projectx/middleware"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB points config.DB at a fresh in-memory DB with every table migrated
+func testDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to file::memory: is a database of its own
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	config.DB = db
+	config.SyncDB()
+}
+
+// authRouter serves register and an admin only route on an in-memory DB
+func authRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	t.Setenv("SECRET", "test-secret")
+	testDB(t)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	auth := controller.New(services.New())
+	r.POST("/register", auth.SignUpUser)
+	r.GET("/admin", middleware.RequireAuth, middleware.RequireAdmin, func(ctx *gin.Context) {
+		ctx.Status(http.StatusNoContent)
+	})
+	return r
+}
+
+// register signs a user up with body and returns their Authorization cookie
+func register(t *testing.T, r *gin.Engine, body string) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register answered %d: %s", w.Code, w.Body)
+	}
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == "Authorization" {
+			return cookie
+		}
+	}
+	t.Fatal("register set no Authorization cookie")
+	return nil
+}
+
+func getAdmin(r *gin.Engine, cookie *http.Cookie) int {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.AddCookie(cookie)
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRequireAdminRejectsSelfRegisteredAdmin(t *testing.T) {
+	r := authRouter(t)
+	cookie := register(t, r, `{"email":"mallory@example.com","password":"secret1","account_type":"admin"}`)
+
+	if code := getAdmin(r, cookie); code != http.StatusForbidden {
+		t.Fatalf("a self registered admin got %d, want 403", code)
+	}
+	var user model.User
+	if err := config.DB.Where("email = ?", "mallory@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	if user.Account_Type != services.AccountUser {
+		t.Errorf("account type = %q, want %q", user.Account_Type, services.AccountUser)
+	}
+}
+
+func TestRequireAdminAllowsSeededAdmin(t *testing.T) {
+	r := authRouter(t)
+	cookie := register(t, r, `{"email":"admin@example.com","password":"secret1"}`)
+	var user model.User
+	if err := config.DB.Where("email = ?", "admin@example.com").First(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("ADMIN_USER_IDS", "someone-else, "+user.UserId)
+	if err := services.SeedAdmins(); err != nil {
+		t.Fatal(err)
+	}
+	if code := getAdmin(r, cookie); code != http.StatusNoContent {
+		t.Fatalf("a seeded admin got %d, want 204", code)
+	}
+}
@@ -0,0 +1,30 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Achievement is a milestone definition, a nil LeaderboardId matches every board
+type Achievement struct {
+	gorm.Model
+	Code          string `gorm:"unique;size:50;not null"`
+	Name          string `gorm:"size:100;not null"`
+	Description   string `gorm:"size:255"`
+	LeaderboardId *uint  `gorm:"index"`
+	Kind          string `gorm:"size:15;not null"`
+	Window        string `gorm:"column:time_window;size:10;not null;default:all"`
+	Rank          int    `gorm:"not null"`
+	Target        int    `gorm:"not null"`
+}
+
+// AchievementProgress counts a player toward an achievement, AwardedAt is set once when the target is reached
+type AchievementProgress struct {
+	gorm.Model
+	AchievementId uint   `gorm:"uniqueIndex:idx_progress_user;not null"`
+	UserId        string `gorm:"uniqueIndex:idx_progress_user;index;size:64;not null"`
+	Progress      int    `gorm:"not null"`
+	LastDay       string `gorm:"size:10"`
+	AwardedAt     *time.Time
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type FriendRequest struct {
+	gorm.Model
+	FromId string `gorm:"index;size:64;not null"`
+	ToId   string `gorm:"index;size:64;not null"`
+	Status string `gorm:"size:10;not null"`
+}
+
+// Friend rows come in pairs, one for each side, so the friends of a player are a single lookup
+type Friend struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserId    string `gorm:"uniqueIndex:idx_friend;size:64;not null"`
+	FriendId  string `gorm:"uniqueIndex:idx_friend;size:64;not null"`
+}
+
+type Block struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UserId    string `gorm:"uniqueIndex:idx_block;size:64;not null"`
+	BlockedId string `gorm:"uniqueIndex:idx_block;size:64;not null"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// IdempotencyKey keeps the response to a request sent with an Idempotency-Key, so a retry of it gets
+// the same answer instead of running again
+type IdempotencyKey struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index;not null"`
+	UserId    string    `gorm:"uniqueIndex:idx_idempotency;size:64;not null"`
+	// key is a reserved word in MySQL, so the column gets a longer name
+	Key         string `gorm:"column:idempotency_key;uniqueIndex:idx_idempotency;size:255;not null"`
+	RequestHash string `gorm:"size:64;not null"`
+	// Status is 0 while the first request is still being handled
+	Status      int    `gorm:"not null"`
+	ContentType string `gorm:"size:100"`
+	Body        []byte `gorm:"type:mediumblob"`
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Leaderboard struct {
+	gorm.Model
+	Name        string `gorm:"unique;size:100;not null"`
+	Game        string `gorm:"size:100;not null"`
+	SortOrder   string `gorm:"size:4;not null"`
+	Aggregation string `gorm:"size:10;not null"`
+	Format      string `gorm:"size:15;not null"`
+	Precision   int    `gorm:"not null"`
+	Timezone    string `gorm:"size:64;not null;default:UTC"`
+	WeekStart   string `gorm:"size:10;not null;default:monday"`
+	TieBreak    string `gorm:"size:12;not null;default:earliest"`
+	MetricOrder string `gorm:"size:4;not null;default:asc"`
+	HistoryDays int    `gorm:"not null;default:90"`
+	// every submission waits for a moderator when set
+	RequiresApproval bool `gorm:"not null;default:false"`
+	// plausibility rules, a submission breaking one is flagged for review
+	MinScore       *float64
+	MaxScore       *float64
+	MaxImprovement *float64
+	MaxPerMinute   int     `gorm:"not null"`
+	MaxZScore      float64 `gorm:"not null"`
+}
+
+type LeaderboardEntry struct {
+	gorm.Model
+	LeaderboardId uint `gorm:"uniqueIndex:idx_entry_user;index:idx_entry_rank,priority:1;index:idx_entry_country,priority:1;not null"`
+	// window is a reserved word in MySQL 8, so the column gets a longer name
+	Window      string    `gorm:"column:time_window;uniqueIndex:idx_entry_user;index:idx_entry_rank,priority:2;index:idx_entry_country,priority:2;size:10;not null;default:all"`
+	PeriodStart time.Time `gorm:"uniqueIndex:idx_entry_user;index:idx_entry_rank,priority:3;index:idx_entry_country,priority:3;not null"`
+	UserId      string    `gorm:"uniqueIndex:idx_entry_user;size:64;not null"`
+	// Country is copied from the player's details so country boards rank with one index
+	Country     string    `gorm:"index:idx_entry_country,priority:4;size:2;not null;default:''"`
+	Score       float64   `gorm:"index:idx_entry_rank,priority:4;index:idx_entry_country,priority:5;not null"`
+	Metric      float64   `gorm:"index:idx_entry_rank,priority:5;index:idx_entry_country,priority:6;not null;default:0"`
+	Submissions int       `gorm:"not null"`
+	SubmittedAt time.Time `gorm:"not null"`
+	// Hidden entries belong to a shadow banned player, they only rank for that player
+	Hidden bool `gorm:"not null;default:false"`
+}
+
+// LeaderboardPeriod records each reset cycle of a time window, past ones stay archived
+type LeaderboardPeriod struct {
+	gorm.Model
+	LeaderboardId uint      `gorm:"uniqueIndex:idx_period;not null"`
+	Window        string    `gorm:"column:time_window;uniqueIndex:idx_period;size:10;not null"`
+	PeriodStart   time.Time `gorm:"uniqueIndex:idx_period;not null"`
+	PeriodEnd     time.Time `gorm:"index;not null"`
+	Archived      bool      `gorm:"not null"`
+}
+
+type Season struct {
+	gorm.Model
+	LeaderboardId uint      `gorm:"uniqueIndex:idx_season_number;not null"`
+	Number        int       `gorm:"uniqueIndex:idx_season_number;not null"`
+	Name          string    `gorm:"size:100"`
+	Status        string    `gorm:"size:10;index;not null"`
+	CarryOver     float64   `gorm:"not null"`
+	StartedAt     time.Time `gorm:"not null"`
+	EndedAt       *time.Time
+}
+
+// SeasonStanding is a row of the final standings written when a season closes, only moderator
+// removals change them afterwards
+type SeasonStanding struct {
+	ID          uint      `gorm:"primarykey"`
+	CreatedAt   time.Time `gorm:"not null"`
+	SeasonId    uint      `gorm:"uniqueIndex:idx_standing_user;index:idx_standing_rank,priority:1;not null"`
+	Position    int       `gorm:"index:idx_standing_rank,priority:2;not null"`
+	UserId      string    `gorm:"uniqueIndex:idx_standing_user;size:64;not null"`
+	Score       float64   `gorm:"not null"`
+	SubmittedAt time.Time `gorm:"not null"`
+}
+
+// Submission is every score sent to a board, flagged ones only reach the entries once approved and TeamId
+// is the team it counted toward at the time
+type Submission struct {
+	gorm.Model
+	LeaderboardId uint    `gorm:"index:idx_submission_user,priority:1;index:idx_submission_status,priority:1;not null"`
+	UserId        string  `gorm:"index:idx_submission_user,priority:2;size:64;not null"`
+	Score         float64 `gorm:"not null"`
+	Metric        float64 `gorm:"not null;default:0"`
+	Status        string  `gorm:"index:idx_submission_status,priority:2;size:10;not null"`
+	Flags         string  `gorm:"size:255"`
+	EvidenceUrl   string  `gorm:"size:500"`
+	Metadata      string  `gorm:"type:text"`
+	PersonalBest  bool    `gorm:"not null;default:false"`
+	TeamId        *uint
+	ReviewedBy    string `gorm:"size:64"`
+	Reason        string `gorm:"size:255"`
+	ReviewedAt    *time.Time
+}
@@ -0,0 +1,61 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Removal takes a player's entries off one board, or every board when LeaderboardId is nil
+type Removal struct {
+	gorm.Model
+	LeaderboardId *uint  `gorm:"index"`
+	UserId        string `gorm:"index;size:64;not null"`
+	Reason        string `gorm:"size:255;not null"`
+	RemovedBy     string `gorm:"size:64;not null"`
+	RestoredBy    string `gorm:"size:64"`
+	RestoredAt    *time.Time
+}
+
+// RemovedEntry keeps an entry or closed season standing a removal deleted so it can be put back
+type RemovedEntry struct {
+	ID            uint      `gorm:"primarykey"`
+	RemovalId     uint      `gorm:"index;not null"`
+	LeaderboardId uint      `gorm:"not null"`
+	Window        string    `gorm:"column:time_window;size:10;not null"`
+	PeriodStart   time.Time `gorm:"not null"`
+	// SeasonId is set for a row of the standings of a closed season
+	SeasonId    *uint
+	Position    int
+	Score       float64   `gorm:"not null"`
+	Metric      float64   `gorm:"not null"`
+	Submissions int       `gorm:"not null"`
+	SubmittedAt time.Time `gorm:"not null"`
+}
+
+// Ban stops a player from submitting to one board, or every board when LeaderboardId is nil.
+// A shadow ban keeps taking the player's scores but hides them from everyone else.
+type Ban struct {
+	gorm.Model
+	LeaderboardId *uint  `gorm:"index"`
+	UserId        string `gorm:"index;size:64;not null"`
+	Reason        string `gorm:"size:255;not null"`
+	Shadow        bool   `gorm:"not null"`
+	ExpiresAt     *time.Time
+	BannedBy      string     `gorm:"size:64;not null"`
+	LiftedBy      string     `gorm:"size:64"`
+	LiftedAt      *time.Time `gorm:"index"`
+}
+
+// AuditEntry records a moderation action, it is never updated
+type AuditEntry struct {
+	ID            uint      `gorm:"primarykey"`
+	CreatedAt     time.Time `gorm:"index"`
+	ModeratorId   string    `gorm:"size:64;not null"`
+	Action        string    `gorm:"size:20;not null"`
+	UserId        string    `gorm:"index;size:64;not null"`
+	LeaderboardId *uint
+	// TargetId is the removal or ban the action was taken on
+	TargetId uint   `gorm:"not null"`
+	Reason   string `gorm:"size:255"`
+}
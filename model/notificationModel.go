@@ -0,0 +1,16 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Notification struct {
+	gorm.Model
+	UserId       string `gorm:"index;size:64;not null"`
+	Kind         string `gorm:"size:30;not null"`
+	SubmissionId uint
+	Message      string `gorm:"size:500;not null"`
+	ReadAt       *time.Time
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// RankSet is a ranked set of the SQL ranking store with the order its items rank by
+type RankSet struct {
+	// key is a reserved word in MySQL, so the column gets a longer name
+	Key        string `gorm:"column:set_key;primarykey;size:191"`
+	Desc       bool   `gorm:"not null"`
+	MetricDesc bool   `gorm:"not null"`
+}
+
+// RankItem is an item of a ranked set of the SQL ranking store
+type RankItem struct {
+	ID          uint      `gorm:"primarykey"`
+	SetKey      string    `gorm:"uniqueIndex:idx_rank_item,priority:1;index:idx_rank_order,priority:1;size:191;not null"`
+	ItemId      uint      `gorm:"uniqueIndex:idx_rank_item,priority:2;not null"`
+	Score       float64   `gorm:"index:idx_rank_order,priority:2;not null"`
+	Metric      float64   `gorm:"index:idx_rank_order,priority:3;not null"`
+	SubmittedAt time.Time `gorm:"index:idx_rank_order,priority:4;not null"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RatingBoard ranks players by a skill rating computed from match results instead of a score
+type RatingBoard struct {
+	gorm.Model
+	Name        string  `gorm:"unique;size:100;not null"`
+	Game        string  `gorm:"size:50;not null"`
+	Algorithm   string  `gorm:"size:10;not null;default:glicko2"`
+	KFactor     float64 `gorm:"not null;default:32"`
+	Tau         float64 `gorm:"not null;default:0.5"`
+	PeriodHours int     `gorm:"not null;default:24"`
+}
+
+// PlayerRating is a player's rating on a board, Deviation is as of LastPlayedAt and grows while inactive
+type PlayerRating struct {
+	gorm.Model
+	RatingBoardId uint    `gorm:"uniqueIndex:idx_rating_user;index:idx_rating_order,priority:1;not null"`
+	UserId        string  `gorm:"uniqueIndex:idx_rating_user;size:64;not null"`
+	Rating        float64 `gorm:"index:idx_rating_order,priority:2;not null"`
+	Deviation     float64 `gorm:"not null"`
+	Volatility    float64 `gorm:"not null"`
+	Matches       int     `gorm:"not null"`
+	Wins          int     `gorm:"not null"`
+	Losses        int     `gorm:"not null"`
+	Draws         int     `gorm:"not null"`
+	LastPlayedAt  *time.Time
+}
+
+type Match struct {
+	gorm.Model
+	RatingBoardId uint `gorm:"index;not null"`
+	Participants  []MatchParticipant
+}
+
+// MatchParticipant keeps a player's placement and the rating change the match caused
+type MatchParticipant struct {
+	gorm.Model
+	MatchId         uint    `gorm:"index;not null"`
+	UserId          string  `gorm:"index;size:64;not null"`
+	Team            string  `gorm:"size:50"`
+	Placement       int     `gorm:"not null"`
+	RatingBefore    float64 `gorm:"not null"`
+	RatingAfter     float64 `gorm:"not null"`
+	DeviationBefore float64 `gorm:"not null"`
+	DeviationAfter  float64 `gorm:"not null"`
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// GameKey is a secret a game's clients sign score submissions with
+type GameKey struct {
+	gorm.Model
+	Game      string `gorm:"index;size:100;not null"`
+	KeyId     string `gorm:"unique;size:32;not null"`
+	Secret    string `gorm:"size:64;not null"`
+	RetiresAt *time.Time
+}
+
+// UsedNonce remembers the nonces of accepted submissions until their timestamp goes stale
+type UsedNonce struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index;not null"`
+	Game      string    `gorm:"uniqueIndex:idx_nonce;size:100;not null"`
+	Nonce     string    `gorm:"uniqueIndex:idx_nonce;size:64;not null"`
+}
+
+// SubmissionRejection records a score submission that failed the signature checks
+type SubmissionRejection struct {
+	ID            uint      `gorm:"primarykey"`
+	CreatedAt     time.Time `gorm:"index;not null"`
+	LeaderboardId uint      `gorm:"index;not null"`
+	UserId        string    `gorm:"index;size:64;not null"`
+	Reason        string    `gorm:"size:30;not null"`
+	Score         float64
+	KeyId         string `gorm:"size:32"`
+	Nonce         string `gorm:"size:64"`
+	Timestamp     int64
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// RankSnapshot records the ranks of one board window at a point in time, rank deltas are counted
+// against it
+type RankSnapshot struct {
+	ID            uint `gorm:"primarykey"`
+	LeaderboardId uint `gorm:"index:idx_snapshot,priority:1;not null"`
+	// window is a reserved word in MySQL 8, so the column gets a longer name
+	Window      string    `gorm:"column:time_window;index:idx_snapshot,priority:2;size:10;not null"`
+	PeriodStart time.Time `gorm:"index:idx_snapshot,priority:3;not null"`
+	TakenAt     time.Time `gorm:"index:idx_snapshot,priority:4;index;not null"`
+	// Entries is how many players were ranked
+	Entries int `gorm:"not null"`
+}
+
+// SnapshotRank is the rank of one player in a snapshot
+type SnapshotRank struct {
+	ID         uint    `gorm:"primarykey"`
+	SnapshotId uint    `gorm:"uniqueIndex:idx_snapshot_user,priority:1;not null"`
+	UserId     string  `gorm:"uniqueIndex:idx_snapshot_user,priority:2;index;size:64;not null"`
+	Rank       int     `gorm:"not null"`
+	Score      float64 `gorm:"not null"`
+}
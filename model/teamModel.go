@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Team struct {
+	gorm.Model
+	Name    string `gorm:"unique;size:50;not null"`
+	Tag     string `gorm:"size:10"`
+	OwnerId string `gorm:"size:64;not null"`
+}
+
+// TeamMember rows are deleted for good when a player leaves, a player is in one team at a time
+type TeamMember struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	TeamId    uint   `gorm:"index;not null"`
+	UserId    string `gorm:"unique;size:64;not null"`
+	Role      string `gorm:"size:10;not null"`
+}
+
+// TeamRequest is an invite sent by a team or a request to join sent by a player
+type TeamRequest struct {
+	gorm.Model
+	TeamId    uint   `gorm:"index;not null"`
+	UserId    string `gorm:"index;size:64;not null"`
+	Kind      string `gorm:"size:10;not null"`
+	Status    string `gorm:"size:10;not null"`
+	CreatedBy string `gorm:"size:64;not null"`
+}
+
+// TeamBoard ranks teams by the entries their members hold on a player leaderboard
+type TeamBoard struct {
+	gorm.Model
+	LeaderboardId uint   `gorm:"index;not null"`
+	Name          string `gorm:"unique;size:100;not null"`
+	Rule          string `gorm:"size:10;not null"`
+	TopK          int    `gorm:"not null"`
+}
+
+type TeamEntry struct {
+	gorm.Model
+	TeamBoardId uint      `gorm:"uniqueIndex:idx_team_entry;index:idx_team_rank,priority:1;not null"`
+	Window      string    `gorm:"column:time_window;uniqueIndex:idx_team_entry;index:idx_team_rank,priority:2;size:10;not null"`
+	PeriodStart time.Time `gorm:"uniqueIndex:idx_team_entry;index:idx_team_rank,priority:3;not null"`
+	TeamId      uint      `gorm:"uniqueIndex:idx_team_entry;not null"`
+	Score       float64   `gorm:"index:idx_team_rank,priority:4;not null"`
+	Members     int       `gorm:"not null"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"gorm.io/gorm"
+)
+
+// Tournament is a bracket or Swiss event, Points are the leaderboard points of each placement separated by commas
+type Tournament struct {
+	gorm.Model
+	Name                string `gorm:"size:100;not null"`
+	Format              string `gorm:"size:10;not null"`
+	Status              string `gorm:"size:10;not null"`
+	Rounds              int    `gorm:"not null"`
+	Round               int    `gorm:"not null"`
+	PointsLeaderboardId *uint
+	Points              string `gorm:"size:500"`
+}
+
+type TournamentPlayer struct {
+	gorm.Model
+	TournamentId uint    `gorm:"uniqueIndex:idx_tournament_player;not null"`
+	UserId       string  `gorm:"uniqueIndex:idx_tournament_player;size:64;not null"`
+	Seed         int     `gorm:"not null"`
+	Placement    int     `gorm:"not null"`
+	Wins         int     `gorm:"not null"`
+	Losses       int     `gorm:"not null"`
+	Draws        int     `gorm:"not null"`
+	Points       float64 `gorm:"not null"`
+}
+
+// TournamentMatch is one game of a tournament. An elimination match waits for PendingFeeds earlier matches
+// to send their winner or loser to slot a or b, a match left with one player is a bye.
+// Matches with a Stage eliminate their loser, a later stage places higher. The final of a double elimination
+// is reset when the player from the losers bracket wins it, the reset has no stage and places its loser second.
+type TournamentMatch struct {
+	gorm.Model
+	TournamentId uint   `gorm:"index;not null"`
+	Bracket      string `gorm:"size:10;not null"`
+	Round        int    `gorm:"not null"`
+	Number       int    `gorm:"not null"`
+	Stage        int    `gorm:"not null"`
+	PlayerA      string `gorm:"size:64"`
+	PlayerB      string `gorm:"size:64"`
+	WinnerId     string `gorm:"size:64"`
+	Draw         bool
+	Status       string `gorm:"size:10;not null"`
+	PendingFeeds int    `gorm:"not null"`
+	NextMatchId  *uint
+	NextSlot     string `gorm:"size:1"`
+	LoserMatchId *uint
+	LoserSlot    string `gorm:"size:1"`
+}
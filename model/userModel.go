@@ -20,6 +20,8 @@ type User_Details struct {
 	Twitter  string
 	Discord  string
 	Google   string
+	// Country is an ISO 3166-1 alpha-2 code
+	Country string `gorm:"size:2"`
 }
 
 type Avatar struct {
@@ -0,0 +1,233 @@
+package ranking
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// gormBatch is how many items are written per insert
+const gormBatch = 500
+
+// GormStore is a Store keeping the sets in the rank_sets and rank_items tables, every instance
+// sharing the DB sees the same sets
+type GormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns a Store on the DB, the RankSet and RankItem tables must be migrated
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) Add(key string, order Order, items ...Item) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := createSet(tx, key, order); err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		rows := make([]model.RankItem, 0, len(items))
+		for _, item := range items {
+			rows = append(rows, toRankRow(key, item))
+		}
+		return tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "set_key"}, {Name: "item_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"score", "metric", "submitted_at"}),
+		}).CreateInBatches(&rows, gormBatch).Error
+	})
+}
+
+func (s *GormStore) Incr(key string, order Order, id uint, by float64) (Item, error) {
+	var item Item
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := createSet(tx, key, order); err != nil {
+			return err
+		}
+		var row model.RankItem
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("set_key = ? AND item_id = ?", key, id).
+			Limit(1).
+			Find(&row)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			row = toRankRow(key, Item{ID: id, SubmittedAt: time.Now().UTC().Truncate(time.Millisecond)})
+		}
+		row.Score += by
+		item = toItem(row)
+		return tx.Save(&row).Error
+	})
+	return item, err
+}
+
+func (s *GormStore) Remove(key string, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Where("set_key = ? AND item_id IN ?", key, ids).Delete(&model.RankItem{}).Error
+}
+
+func (s *GormStore) Rank(key string, id uint) (int, error) {
+	set, ok, err := s.set(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	var row model.RankItem
+	result := s.db.Where("set_key = ? AND item_id = ?", key, id).Limit(1).Find(&row)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return 0, result.Error
+	}
+	var above int64
+	cond, args := aboveRow(set, row)
+	if err := s.db.Model(&model.RankItem{}).Where("set_key = ?", key).Where(cond, args...).Count(&above).Error; err != nil {
+		return 0, err
+	}
+	return int(above) + 1, nil
+}
+
+func (s *GormStore) Better(key string, score float64) (int, error) {
+	set, ok, err := s.set(key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	var count int64
+	result := s.db.Model(&model.RankItem{}).
+		Where("set_key = ? AND score "+betterOp(set.Desc)+" ?", key, score).
+		Count(&count)
+	return int(count), result.Error
+}
+
+func (s *GormStore) RangeByRank(key string, start, count int) ([]Item, error) {
+	if start < 1 {
+		count += start - 1
+		start = 1
+	}
+	set, ok, err := s.set(key)
+	if err != nil || !ok || count <= 0 {
+		return nil, err
+	}
+	var rows []model.RankItem
+	result := s.db.Where("set_key = ?", key).Order(rowOrder(set)).Offset(start - 1).Limit(count).Find(&rows)
+	return toItems(rows), result.Error
+}
+
+func (s *GormStore) RangeByScore(key string, min, max float64, limit int) ([]Item, error) {
+	set, ok, err := s.set(key)
+	if err != nil || !ok || min > max || limit <= 0 {
+		return nil, err
+	}
+	var rows []model.RankItem
+	result := s.db.Where("set_key = ? AND score BETWEEN ? AND ?", key, min, max).
+		Order(rowOrder(set)).
+		Limit(limit).
+		Find(&rows)
+	return toItems(rows), result.Error
+}
+
+func (s *GormStore) Count(key string) (int, error) {
+	var count int64
+	result := s.db.Model(&model.RankItem{}).Where("set_key = ?", key).Count(&count)
+	return int(count), result.Error
+}
+
+func (s *GormStore) Exists(key string) (bool, error) {
+	_, ok, err := s.set(key)
+	return ok, err
+}
+
+func (s *GormStore) Keys(prefix string) ([]string, error) {
+	var keys []string
+	result := s.db.Model(&model.RankSet{}).
+		Where("set_key LIKE ? ESCAPE '!'", likePrefix(prefix)).
+		Order("set_key").
+		Pluck("set_key", &keys)
+	return keys, result.Error
+}
+
+func (s *GormStore) Delete(key string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("set_key = ?", key).Delete(&model.RankItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("set_key = ?", key).Delete(&model.RankSet{}).Error
+	})
+}
+
+// set reads the order of the set under key, ok is false when there is no such set
+func (s *GormStore) set(key string) (model.RankSet, bool, error) {
+	var set model.RankSet
+	err := s.db.Where("set_key = ?", key).First(&set).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.RankSet{}, false, nil
+	}
+	return set, err == nil, err
+}
+
+// createSet records the set under key with the order unless it exists, then checks it ranks by that order
+func createSet(tx *gorm.DB, key string, order Order) error {
+	set := model.RankSet{Key: key, Desc: order.Desc, MetricDesc: order.MetricDesc}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&set).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("set_key = ?", key).First(&set).Error; err != nil {
+		return err
+	}
+	if set.Desc != order.Desc || set.MetricDesc != order.MetricDesc {
+		return ErrOrderMismatch
+	}
+	return nil
+}
+
+// likePrefix is the LIKE pattern of the keys starting with prefix, escaped with !
+func likePrefix(prefix string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(prefix) + "%"
+}
+
+// rowOrder sorts the items of a set the way SkipList does
+func rowOrder(set model.RankSet) string {
+	return "score " + direction(set.Desc) + ", metric " + direction(set.MetricDesc) + ", submitted_at, item_id"
+}
+
+// aboveRow is the condition matching the items ranked above the given one, it follows rowOrder
+func aboveRow(set model.RankSet, row model.RankItem) (string, []interface{}) {
+	return "score " + betterOp(set.Desc) + " ? OR (score = ? AND (metric " + betterOp(set.MetricDesc) +
+			" ? OR (metric = ? AND (submitted_at < ? OR (submitted_at = ? AND item_id < ?)))))",
+		[]interface{}{row.Score, row.Score, row.Metric, row.Metric, row.SubmittedAt, row.SubmittedAt, row.ItemId}
+}
+
+func direction(desc bool) string {
+	if desc {
+		return "desc"
+	}
+	return "asc"
+}
+
+func betterOp(desc bool) string {
+	if desc {
+		return ">"
+	}
+	return "<"
+}
+
+func toRankRow(key string, item Item) model.RankItem {
+	return model.RankItem{SetKey: key, ItemId: item.ID, Score: item.Score, Metric: item.Metric, SubmittedAt: item.SubmittedAt}
+}
+
+func toItem(row model.RankItem) Item {
+	return Item{ID: row.ItemId, Score: row.Score, Metric: row.Metric, SubmittedAt: row.SubmittedAt}
+}
+
+func toItems(rows []model.RankItem) []Item {
+	items := make([]Item, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, toItem(row))
+	}
+	return items
+}
@@ -0,0 +1,23 @@
+package ranking_test
+
+import (
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/ranking"
+	"github.com/JohnnyOhms/projectx/ranking/rankingtest"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestGormStore(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&model.RankSet{}, &model.RankItem{}); err != nil {
+		t.Fatal(err)
+	}
+	rankingtest.Run(t, ranking.NewGormStore(db))
+}
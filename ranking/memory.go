@@ -0,0 +1,178 @@
+package ranking
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store keeping a skip list per set in the process, every instance has its own
+type MemoryStore struct {
+	mu   sync.RWMutex
+	sets map[string]*memorySet
+}
+
+type memorySet struct {
+	mu    sync.RWMutex
+	order Order
+	list  Index
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sets: make(map[string]*memorySet)}
+}
+
+func (s *MemoryStore) Add(key string, order Order, items ...Item) error {
+	set, err := s.create(key, order)
+	if err != nil {
+		return err
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	for _, item := range items {
+		set.list.Upsert(item)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Incr(key string, order Order, id uint, by float64) (Item, error) {
+	set, err := s.create(key, order)
+	if err != nil {
+		return Item{}, err
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	item := Item{ID: id, SubmittedAt: time.Now().UTC()}
+	if rank := set.list.Rank(id); rank > 0 {
+		item, _ = set.list.At(rank)
+	}
+	item.Score += by
+	set.list.Upsert(item)
+	return item, nil
+}
+
+func (s *MemoryStore) Remove(key string, ids ...uint) error {
+	set := s.set(key)
+	if set == nil {
+		return nil
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	for _, id := range ids {
+		set.list.Remove(id)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Rank(key string, id uint) (int, error) {
+	set := s.set(key)
+	if set == nil {
+		return 0, nil
+	}
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return set.list.Rank(id), nil
+}
+
+func (s *MemoryStore) Better(key string, score float64) (int, error) {
+	set := s.set(key)
+	if set == nil {
+		return 0, nil
+	}
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return set.list.Better(score), nil
+}
+
+func (s *MemoryStore) RangeByRank(key string, start, count int) ([]Item, error) {
+	set := s.set(key)
+	if set == nil {
+		return nil, nil
+	}
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return set.list.Range(start, count), nil
+}
+
+func (s *MemoryStore) RangeByScore(key string, min, max float64, limit int) ([]Item, error) {
+	set := s.set(key)
+	if set == nil || min > max || limit <= 0 {
+		return nil, nil
+	}
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+
+	// the range starts past the items scored better than its best end
+	best, worst := max, min
+	if !set.order.Desc {
+		best, worst = min, max
+	}
+	items := set.list.Range(set.list.Better(best)+1, limit)
+	for i, item := range items {
+		past := item.Score < worst
+		if !set.order.Desc {
+			past = item.Score > worst
+		}
+		if past {
+			return items[:i], nil
+		}
+	}
+	return items, nil
+}
+
+func (s *MemoryStore) Count(key string) (int, error) {
+	set := s.set(key)
+	if set == nil {
+		return 0, nil
+	}
+	set.mu.RLock()
+	defer set.mu.RUnlock()
+	return set.list.Len(), nil
+}
+
+func (s *MemoryStore) Exists(key string) (bool, error) {
+	return s.set(key) != nil, nil
+}
+
+func (s *MemoryStore) Keys(prefix string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []string
+	for key := range s.sets {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+func (s *MemoryStore) Delete(key string) error {
+	s.mu.Lock()
+	delete(s.sets, key)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) set(key string) *memorySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sets[key]
+}
+
+// create returns the set under key, making it with the order when it does not exist yet
+func (s *MemoryStore) create(key string, order Order) (*memorySet, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set, ok := s.sets[key]
+	if !ok {
+		set = &memorySet{order: order, list: New(order.Desc, order.MetricDesc)}
+		s.sets[key] = set
+	}
+	if set.order != order {
+		return nil, ErrOrderMismatch
+	}
+	return set, nil
+}
@@ -0,0 +1,12 @@
+package ranking_test
+
+import (
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/ranking"
+	"github.com/JohnnyOhms/projectx/ranking/rankingtest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	rankingtest.Run(t, ranking.NewMemoryStore())
+}
@@ -0,0 +1,21 @@
+// Package ranking keeps leaderboard entries ordered so ranks can be read fast, in a memory index or in a
+// Store shared by every instance.
+package ranking
+
+// Index is an ordered set of ranked items
+type Index interface {
+	Upsert(item Item)
+	Remove(id uint) bool
+	Rank(id uint) int
+	// Better counts the items with a strictly better score
+	Better(score float64) int
+	At(rank int) (Item, bool)
+	Range(start, count int) []Item
+	Len() int
+}
+
+// New returns an empty Index, desc puts the highest scores first and metricDesc the highest metrics
+// among equal scores
+func New(desc, metricDesc bool) Index {
+	return NewSkipList(desc, metricDesc)
+}
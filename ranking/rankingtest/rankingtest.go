@@ -0,0 +1,491 @@
+// Package rankingtest is the conformance suite every ranking.Store implementation must pass, each
+// store's tests call Run with it.
+package rankingtest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/ranking"
+)
+
+// TB is the part of testing.TB the suite reports through
+type TB interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// Case is one conformance check
+type Case struct {
+	Name  string
+	Check func(t TB, store ranking.Store)
+}
+
+// Cases lists every check of the suite
+var Cases = []Case{
+	{"EmptySet", emptySet},
+	{"RankDescending", rankDescending},
+	{"RankAscending", rankAscending},
+	{"TieBreaks", tieBreaks},
+	{"AddMovesItem", addMovesItem},
+	{"Incr", incr},
+	{"Remove", remove},
+	{"Better", better},
+	{"RangeByRank", rangeByRank},
+	{"RangeByScore", rangeByScore},
+	{"Delete", deleteSet},
+	{"Keys", keys},
+	{"OrderMismatch", orderMismatch},
+	{"SeparateSets", separateSets},
+	{"RandomOperations", randomOperations},
+}
+
+// Run runs every case against the store, each in a subtest of its own
+func Run(t *testing.T, store ranking.Store) {
+	for _, c := range Cases {
+		c := c
+		t.Run(c.Name, func(t *testing.T) {
+			runCase(t, c, store)
+		})
+	}
+}
+
+// runCase runs one case. Its sets are named under a prefix of their own and deleted afterwards, so the
+// store may be shared with other data.
+func runCase(t TB, c Case, store ranking.Store) {
+	scoped := &prefixed{store: store, prefix: fmt.Sprintf("rankingtest/%s/%d/", c.Name, time.Now().UnixNano())}
+	defer scoped.cleanup()
+	c.Check(t, scoped)
+}
+
+var (
+	desc = ranking.Order{Desc: true}
+	asc  = ranking.Order{}
+	base = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func item(id uint, score float64) ranking.Item {
+	return ranking.Item{ID: id, Score: score, SubmittedAt: base.Add(time.Duration(id) * time.Second)}
+}
+
+func emptySet(t TB, s ranking.Store) {
+	t.Helper()
+	count, err := s.Count("missing")
+	check(t, err)
+	expect(t, "count", count, 0)
+	rank, err := s.Rank("missing", 1)
+	check(t, err)
+	expect(t, "rank", rank, 0)
+	n, err := s.Better("missing", 10)
+	check(t, err)
+	expect(t, "better", n, 0)
+	items, err := s.RangeByRank("missing", 1, 10)
+	check(t, err)
+	expectIds(t, "range by rank", items)
+	items, err = s.RangeByScore("missing", 0, 100, 10)
+	check(t, err)
+	expectIds(t, "range by score", items)
+	check(t, s.Remove("missing", 1))
+	check(t, s.Delete("missing"))
+}
+
+func rankDescending(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("set", desc, item(1, 10), item(2, 30), item(3, 20)))
+	expectRanks(t, s, "set", map[uint]int{2: 1, 3: 2, 1: 3, 4: 0})
+	count, err := s.Count("set")
+	check(t, err)
+	expect(t, "count", count, 3)
+}
+
+func rankAscending(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("set", asc, item(1, 10), item(2, 30), item(3, 20)))
+	expectRanks(t, s, "set", map[uint]int{1: 1, 3: 2, 2: 3})
+}
+
+func tieBreaks(t TB, s ranking.Store) {
+	t.Helper()
+	// equal scores rank by metric, then by earliest submission, then by id
+	check(t, s.Add("metric", ranking.Order{Desc: true, MetricDesc: true},
+		ranking.Item{ID: 1, Score: 5, Metric: 1, SubmittedAt: base},
+		ranking.Item{ID: 2, Score: 5, Metric: 3, SubmittedAt: base},
+		ranking.Item{ID: 3, Score: 5, Metric: 2, SubmittedAt: base}))
+	expectRanks(t, s, "metric", map[uint]int{2: 1, 3: 2, 1: 3})
+
+	check(t, s.Add("time", desc,
+		ranking.Item{ID: 1, Score: 5, SubmittedAt: base.Add(time.Minute)},
+		ranking.Item{ID: 2, Score: 5, SubmittedAt: base},
+		ranking.Item{ID: 3, Score: 5, SubmittedAt: base.Add(time.Minute)}))
+	expectRanks(t, s, "time", map[uint]int{2: 1, 1: 2, 3: 3})
+}
+
+func addMovesItem(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("set", desc, item(1, 10), item(2, 20)))
+	check(t, s.Add("set", desc, item(1, 30)))
+	expectRanks(t, s, "set", map[uint]int{1: 1, 2: 2})
+	count, err := s.Count("set")
+	check(t, err)
+	expect(t, "count", count, 2)
+}
+
+func incr(t TB, s ranking.Store) {
+	t.Helper()
+	got, err := s.Incr("set", desc, 1, 5)
+	check(t, err)
+	expect(t, "created score", got.Score, 5.0)
+	check(t, s.Add("set", desc, item(2, 7)))
+	got, err = s.Incr("set", desc, 1, 4)
+	check(t, err)
+	expect(t, "incremented score", got.Score, 9.0)
+	expectRanks(t, s, "set", map[uint]int{1: 1, 2: 2})
+	got, err = s.Incr("set", desc, 1, -6)
+	check(t, err)
+	expect(t, "decremented score", got.Score, 3.0)
+	expectRanks(t, s, "set", map[uint]int{2: 1, 1: 2})
+}
+
+func remove(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("set", desc, item(1, 10), item(2, 20), item(3, 30)))
+	check(t, s.Remove("set", 2, 9))
+	expectRanks(t, s, "set", map[uint]int{3: 1, 1: 2, 2: 0})
+	count, err := s.Count("set")
+	check(t, err)
+	expect(t, "count", count, 2)
+}
+
+func better(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("desc", desc, item(1, 10), item(2, 20), item(3, 20), item(4, 30)))
+	for score, want := range map[float64]int{35: 0, 30: 0, 20: 1, 15: 3, 5: 4} {
+		n, err := s.Better("desc", score)
+		check(t, err)
+		expect(t, fmt.Sprintf("better than %v", score), n, want)
+	}
+	check(t, s.Add("asc", asc, item(1, 10), item(2, 20), item(3, 20), item(4, 30)))
+	for score, want := range map[float64]int{5: 0, 10: 0, 20: 1, 25: 3, 35: 4} {
+		n, err := s.Better("asc", score)
+		check(t, err)
+		expect(t, fmt.Sprintf("better than %v ascending", score), n, want)
+	}
+}
+
+func rangeByRank(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("set", desc, item(1, 10), item(2, 20), item(3, 30), item(4, 40), item(5, 50)))
+	items, err := s.RangeByRank("set", 2, 2)
+	check(t, err)
+	expectIds(t, "middle", items, 4, 3)
+	items, err = s.RangeByRank("set", 4, 10)
+	check(t, err)
+	expectIds(t, "past the end", items, 2, 1)
+	items, err = s.RangeByRank("set", 6, 3)
+	check(t, err)
+	expectIds(t, "beyond the end", items)
+	items, err = s.RangeByRank("set", -1, 3)
+	check(t, err)
+	expectIds(t, "before the start", items, 5)
+	items, err = s.RangeByRank("set", 1, 0)
+	check(t, err)
+	expectIds(t, "no count", items)
+}
+
+func rangeByScore(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("desc", desc, item(1, 10), item(2, 20), item(3, 30), item(4, 40), item(5, 20)))
+	items, err := s.RangeByScore("desc", 20, 30, 10)
+	check(t, err)
+	expectIds(t, "bounds included", items, 3, 2, 5)
+	items, err = s.RangeByScore("desc", 0, 100, 2)
+	check(t, err)
+	expectIds(t, "limited", items, 4, 3)
+	items, err = s.RangeByScore("desc", 31, 39, 10)
+	check(t, err)
+	expectIds(t, "empty range", items)
+	items, err = s.RangeByScore("desc", 30, 20, 10)
+	check(t, err)
+	expectIds(t, "min above max", items)
+
+	check(t, s.Add("asc", asc, item(1, 10), item(2, 20), item(3, 30), item(4, 40), item(5, 20)))
+	items, err = s.RangeByScore("asc", 15, 40, 3)
+	check(t, err)
+	expectIds(t, "ascending", items, 2, 5, 3)
+}
+
+func deleteSet(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("set", desc, item(1, 10), item(2, 20)))
+	check(t, s.Delete("set"))
+	count, err := s.Count("set")
+	check(t, err)
+	expect(t, "count after delete", count, 0)
+	expectRanks(t, s, "set", map[uint]int{1: 0})
+	// a deleted set can come back with another order
+	check(t, s.Add("set", asc, item(1, 10), item(2, 20)))
+	expectRanks(t, s, "set", map[uint]int{1: 1, 2: 2})
+}
+
+func keys(t TB, s ranking.Store) {
+	t.Helper()
+	exists, err := s.Exists("a")
+	check(t, err)
+	expect(t, "missing set exists", exists, false)
+	check(t, s.Add("a", desc))
+	check(t, s.Add("a/1", desc, item(1, 10)))
+	check(t, s.Add("a/2", asc, item(1, 10)))
+	check(t, s.Add("ab", desc, item(1, 10)))
+	exists, err = s.Exists("a")
+	check(t, err)
+	expect(t, "empty set exists", exists, true)
+
+	got, err := s.Keys("a/")
+	check(t, err)
+	expect(t, "keys", fmt.Sprint(got), "[a/1 a/2]")
+	got, err = s.Keys("a")
+	check(t, err)
+	expect(t, "keys", fmt.Sprint(got), "[a a/1 a/2 ab]")
+	// the prefix is matched as it is written
+	got, err = s.Keys("a_")
+	check(t, err)
+	expect(t, "keys", fmt.Sprint(got), "[]")
+	check(t, s.Delete("a/1"))
+	got, err = s.Keys("a/")
+	check(t, err)
+	expect(t, "keys after delete", fmt.Sprint(got), "[a/2]")
+}
+
+func orderMismatch(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("set", desc, item(1, 10)))
+	if err := s.Add("set", asc, item(2, 20)); !errors.Is(err, ranking.ErrOrderMismatch) {
+		t.Errorf("add with another order: got %v, want ErrOrderMismatch", err)
+	}
+	if _, err := s.Incr("set", ranking.Order{Desc: true, MetricDesc: true}, 1, 1); !errors.Is(err, ranking.ErrOrderMismatch) {
+		t.Errorf("incr with another order: got %v, want ErrOrderMismatch", err)
+	}
+	count, err := s.Count("set")
+	check(t, err)
+	expect(t, "count", count, 1)
+}
+
+func separateSets(t TB, s ranking.Store) {
+	t.Helper()
+	check(t, s.Add("a", desc, item(1, 10), item(2, 20)))
+	check(t, s.Add("b", asc, item(1, 10), item(2, 20)))
+	expectRanks(t, s, "a", map[uint]int{2: 1, 1: 2})
+	expectRanks(t, s, "b", map[uint]int{1: 1, 2: 2})
+	check(t, s.Remove("a", 1))
+	count, err := s.Count("b")
+	check(t, err)
+	expect(t, "other set count", count, 2)
+}
+
+// randomOperations replays random writes on the store and on a sorted slice and compares the reads
+func randomOperations(t TB, s ranking.Store) {
+	t.Helper()
+	order := ranking.Order{Desc: true, MetricDesc: false}
+	rnd := rand.New(rand.NewSource(1))
+	model := map[uint]ranking.Item{}
+	for step := 1; step <= 300; step++ {
+		id := uint(rnd.Intn(60) + 1)
+		switch rnd.Intn(6) {
+		case 0:
+			check(t, s.Remove("set", id))
+			delete(model, id)
+		case 1:
+			by := float64(rnd.Intn(5))
+			got, err := s.Incr("set", order, id, by)
+			check(t, err)
+			want, ok := model[id]
+			if !ok {
+				want = ranking.Item{ID: id, SubmittedAt: got.SubmittedAt}
+			}
+			want.Score += by
+			expect(t, "incr score", got.Score, want.Score)
+			model[id] = got
+		default:
+			next := ranking.Item{
+				ID:          id,
+				Score:       float64(rnd.Intn(20)),
+				Metric:      float64(rnd.Intn(3)),
+				SubmittedAt: base.Add(time.Duration(rnd.Intn(10)) * time.Millisecond),
+			}
+			check(t, s.Add("set", order, next))
+			model[id] = next
+		}
+		if step%25 == 0 {
+			compare(t, s, order, model)
+		}
+	}
+}
+
+// compare checks every read of the set against the items it should hold
+func compare(t TB, s ranking.Store, order ranking.Order, model map[uint]ranking.Item) {
+	t.Helper()
+	sorted := make([]ranking.Item, 0, len(model))
+	for _, item := range model {
+		sorted = append(sorted, item)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return before(order, sorted[i], sorted[j]) })
+
+	count, err := s.Count("set")
+	check(t, err)
+	expect(t, "count", count, len(sorted))
+	items, err := s.RangeByRank("set", 1, len(sorted)+1)
+	check(t, err)
+	expectIds(t, "full range", items, ids(sorted)...)
+	for i, item := range sorted {
+		rank, err := s.Rank("set", item.ID)
+		check(t, err)
+		expect(t, fmt.Sprintf("rank of %d", item.ID), rank, i+1)
+	}
+	for score := -1.0; score <= 25; score += 3 {
+		n, err := s.Better("set", score)
+		check(t, err)
+		want := 0
+		for _, item := range sorted {
+			if item.Score > score {
+				want++
+			}
+		}
+		expect(t, fmt.Sprintf("better than %v", score), n, want)
+	}
+	items, err = s.RangeByScore("set", 5, 12, 10)
+	check(t, err)
+	var inRange []ranking.Item
+	for _, item := range sorted {
+		if item.Score >= 5 && item.Score <= 12 && len(inRange) < 10 {
+			inRange = append(inRange, item)
+		}
+	}
+	expectIds(t, "range by score", items, ids(inRange)...)
+}
+
+// before is the order of ranking.SkipList
+func before(order ranking.Order, a, b ranking.Item) bool {
+	if a.Score != b.Score {
+		return (a.Score > b.Score) == order.Desc
+	}
+	if a.Metric != b.Metric {
+		return (a.Metric > b.Metric) == order.MetricDesc
+	}
+	if !a.SubmittedAt.Equal(b.SubmittedAt) {
+		return a.SubmittedAt.Before(b.SubmittedAt)
+	}
+	return a.ID < b.ID
+}
+
+func ids(items []ranking.Item) []uint {
+	list := make([]uint, len(items))
+	for i, item := range items {
+		list[i] = item.ID
+	}
+	return list
+}
+
+func check(t TB, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func expect[V comparable](t TB, what string, got, want V) {
+	t.Helper()
+	if got != want {
+		t.Errorf("%s: got %v, want %v", what, got, want)
+	}
+}
+
+func expectRanks(t TB, s ranking.Store, key string, want map[uint]int) {
+	t.Helper()
+	for id, rank := range want {
+		got, err := s.Rank(key, id)
+		check(t, err)
+		expect(t, fmt.Sprintf("rank of %d in %s", id, key), got, rank)
+	}
+}
+
+func expectIds(t TB, what string, items []ranking.Item, want ...uint) {
+	t.Helper()
+	if got := ids(items); !slices.Equal(got, want) && (len(got) > 0 || len(want) > 0) {
+		t.Errorf("%s: got ids %v, want %v", what, got, want)
+	}
+}
+
+// prefixed keeps the sets of a case apart from any other set of the store and deletes them at the end
+type prefixed struct {
+	store  ranking.Store
+	prefix string
+	keys   []string
+}
+
+func (p *prefixed) key(key string) string {
+	if !slices.Contains(p.keys, key) {
+		p.keys = append(p.keys, key)
+	}
+	return p.prefix + key
+}
+
+func (p *prefixed) cleanup() {
+	for _, key := range p.keys {
+		p.store.Delete(p.prefix + key)
+	}
+}
+
+func (p *prefixed) Add(key string, order ranking.Order, items ...ranking.Item) error {
+	return p.store.Add(p.key(key), order, items...)
+}
+
+func (p *prefixed) Incr(key string, order ranking.Order, id uint, by float64) (ranking.Item, error) {
+	return p.store.Incr(p.key(key), order, id, by)
+}
+
+func (p *prefixed) Remove(key string, ids ...uint) error {
+	return p.store.Remove(p.key(key), ids...)
+}
+
+func (p *prefixed) Rank(key string, id uint) (int, error) {
+	return p.store.Rank(p.key(key), id)
+}
+
+func (p *prefixed) Better(key string, score float64) (int, error) {
+	return p.store.Better(p.key(key), score)
+}
+
+func (p *prefixed) RangeByRank(key string, start, count int) ([]ranking.Item, error) {
+	return p.store.RangeByRank(p.key(key), start, count)
+}
+
+func (p *prefixed) RangeByScore(key string, min, max float64, limit int) ([]ranking.Item, error) {
+	return p.store.RangeByScore(p.key(key), min, max, limit)
+}
+
+func (p *prefixed) Count(key string) (int, error) {
+	return p.store.Count(p.key(key))
+}
+
+func (p *prefixed) Exists(key string) (bool, error) {
+	return p.store.Exists(p.key(key))
+}
+
+func (p *prefixed) Keys(prefix string) ([]string, error) {
+	keys, err := p.store.Keys(p.prefix + prefix)
+	for i, key := range keys {
+		keys[i] = strings.TrimPrefix(key, p.prefix)
+	}
+	return keys, err
+}
+
+func (p *prefixed) Delete(key string) error {
+	return p.store.Delete(p.key(key))
+}
@@ -0,0 +1,261 @@
+package ranking
+
+import (
+	"math/rand"
+	"time"
+)
+
+const (
+	maxLevel    = 32
+	probability = 0.25
+)
+
+// Item is one ranked member of an index, Metric breaks ties between equal scores before SubmittedAt does
+type Item struct {
+	ID          uint
+	Score       float64
+	Metric      float64
+	SubmittedAt time.Time
+}
+
+// level is a forward link of a node, span counts the nodes it jumps over
+type level struct {
+	forward *node
+	span    int
+}
+
+type node struct {
+	item     Item
+	backward *node
+	levels   []level
+}
+
+// SkipList is an indexable skip list, every link keeps its span so ranks are found in O(log n).
+// It is not safe for concurrent use.
+type SkipList struct {
+	head       *node
+	tail       *node
+	level      int
+	length     int
+	desc       bool
+	metricDesc bool
+	byId       map[uint]*node
+	rnd        *rand.Rand
+}
+
+// NewSkipList creates an empty list, desc puts the highest scores first and metricDesc the highest metrics
+func NewSkipList(desc, metricDesc bool) *SkipList {
+	return &SkipList{
+		head:       &node{levels: make([]level, maxLevel)},
+		level:      1,
+		desc:       desc,
+		metricDesc: metricDesc,
+		byId:       make(map[uint]*node),
+		rnd:        rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Len returns the number of items
+func (l *SkipList) Len() int {
+	return l.length
+}
+
+// Upsert inserts the item or moves it to its new position when the id is already ranked
+func (l *SkipList) Upsert(item Item) {
+	if _, ok := l.byId[item.ID]; ok {
+		l.Remove(item.ID)
+	}
+
+	var update [maxLevel]*node
+	var rank [maxLevel]int
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		if i < l.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.levels[i].forward != nil && l.before(x.levels[i].forward.item, item) {
+			rank[i] += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	lvl := l.randomLevel()
+	if lvl > l.level {
+		for i := l.level; i < lvl; i++ {
+			rank[i] = 0
+			update[i] = l.head
+			update[i].levels[i].span = l.length
+		}
+		l.level = lvl
+	}
+
+	x = &node{item: item, levels: make([]level, lvl)}
+	for i := 0; i < lvl; i++ {
+		x.levels[i].forward = update[i].levels[i].forward
+		update[i].levels[i].forward = x
+		x.levels[i].span = update[i].levels[i].span - (rank[0] - rank[i])
+		update[i].levels[i].span = rank[0] - rank[i] + 1
+	}
+	for i := lvl; i < l.level; i++ {
+		update[i].levels[i].span++
+	}
+
+	if update[0] != l.head {
+		x.backward = update[0]
+	}
+	if x.levels[0].forward != nil {
+		x.levels[0].forward.backward = x
+	} else {
+		l.tail = x
+	}
+	l.length++
+	l.byId[item.ID] = x
+}
+
+// Remove drops the item with the id, it reports false when the id is not ranked
+func (l *SkipList) Remove(id uint) bool {
+	target, ok := l.byId[id]
+	if !ok {
+		return false
+	}
+
+	var update [maxLevel]*node
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && x.levels[i].forward != target && l.before(x.levels[i].forward.item, target.item) {
+			x = x.levels[i].forward
+		}
+		update[i] = x
+	}
+
+	for i := 0; i < l.level; i++ {
+		if update[i].levels[i].forward == target {
+			update[i].levels[i].span += target.levels[i].span - 1
+			update[i].levels[i].forward = target.levels[i].forward
+		} else {
+			update[i].levels[i].span--
+		}
+	}
+	if target.levels[0].forward != nil {
+		target.levels[0].forward.backward = target.backward
+	} else {
+		l.tail = target.backward
+	}
+	for l.level > 1 && l.head.levels[l.level-1].forward == nil {
+		l.level--
+	}
+	l.length--
+	delete(l.byId, id)
+	return true
+}
+
+// Rank returns the 1 based rank of the id, 0 when it is not ranked
+func (l *SkipList) Rank(id uint) int {
+	target, ok := l.byId[id]
+	if !ok {
+		return 0
+	}
+
+	rank := 0
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && (x.levels[i].forward == target || l.before(x.levels[i].forward.item, target.item)) {
+			rank += x.levels[i].span
+			x = x.levels[i].forward
+			if x == target {
+				return rank
+			}
+		}
+	}
+	return 0
+}
+
+// Better counts the items with a better score than the given one
+func (l *SkipList) Better(score float64) int {
+	count := 0
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && l.scoreBefore(x.levels[i].forward.item.Score, score) {
+			count += x.levels[i].span
+			x = x.levels[i].forward
+		}
+	}
+	return count
+}
+
+// At returns the item holding the 1 based rank
+func (l *SkipList) At(rank int) (Item, bool) {
+	x := l.nodeAt(rank)
+	if x == nil {
+		return Item{}, false
+	}
+	return x.item, true
+}
+
+// Range returns up to count items starting at the 1 based rank
+func (l *SkipList) Range(start, count int) []Item {
+	if start < 1 {
+		count += start - 1
+		start = 1
+	}
+	if count <= 0 {
+		return nil
+	}
+	items := make([]Item, 0, min(count, l.length))
+	for x := l.nodeAt(start); x != nil && len(items) < count; x = x.levels[0].forward {
+		items = append(items, x.item)
+	}
+	return items
+}
+
+// nodeAt walks the spans down to the node at the rank
+func (l *SkipList) nodeAt(rank int) *node {
+	if rank < 1 || rank > l.length {
+		return nil
+	}
+	traversed := 0
+	x := l.head
+	for i := l.level - 1; i >= 0; i-- {
+		for x.levels[i].forward != nil && traversed+x.levels[i].span <= rank {
+			traversed += x.levels[i].span
+			x = x.levels[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// before orders items by score, then by metric, then by earliest submission, then by id
+func (l *SkipList) before(a, b Item) bool {
+	if a.Score != b.Score {
+		return l.scoreBefore(a.Score, b.Score)
+	}
+	if a.Metric != b.Metric {
+		if l.metricDesc {
+			return a.Metric > b.Metric
+		}
+		return a.Metric < b.Metric
+	}
+	if !a.SubmittedAt.Equal(b.SubmittedAt) {
+		return a.SubmittedAt.Before(b.SubmittedAt)
+	}
+	return a.ID < b.ID
+}
+
+func (l *SkipList) scoreBefore(a, b float64) bool {
+	if l.desc {
+		return a > b
+	}
+	return a < b
+}
+
+func (l *SkipList) randomLevel() int {
+	lvl := 1
+	for lvl < maxLevel && l.rnd.Float64() < probability {
+		lvl++
+	}
+	return lvl
+}
@@ -0,0 +1,205 @@
+package ranking
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ids lists the ids of the items in order
+func ids(items []Item) []uint {
+	out := make([]uint, len(items))
+	for i, item := range items {
+		out[i] = item.ID
+	}
+	return out
+}
+
+func equalIds(t *testing.T, got, want []uint) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestSkipListRank(t *testing.T) {
+	l := NewSkipList(true, false)
+	for i, score := range []float64{10, 40, 20, 30} {
+		l.Upsert(Item{ID: uint(i + 1), Score: score, SubmittedAt: base})
+	}
+
+	for id, want := range map[uint]int{2: 1, 4: 2, 3: 3, 1: 4, 9: 0} {
+		if got := l.Rank(id); got != want {
+			t.Errorf("Rank(%d) = %d, want %d", id, got, want)
+		}
+	}
+	if got := l.Better(20); got != 2 {
+		t.Errorf("Better(20) = %d, want 2", got)
+	}
+
+	// moving an item re-ranks it
+	l.Upsert(Item{ID: 1, Score: 50, SubmittedAt: base})
+	if got := l.Rank(1); got != 1 {
+		t.Errorf("Rank(1) after raising its score = %d, want 1", got)
+	}
+	if l.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", l.Len())
+	}
+}
+
+func TestSkipListAscending(t *testing.T) {
+	l := NewSkipList(false, false)
+	for i, score := range []float64{30, 10, 20} {
+		l.Upsert(Item{ID: uint(i + 1), Score: score, SubmittedAt: base})
+	}
+	equalIds(t, ids(l.Range(1, 10)), []uint{2, 3, 1})
+	if got := l.Better(30); got != 2 {
+		t.Errorf("Better(30) = %d, want 2", got)
+	}
+}
+
+func TestSkipListAt(t *testing.T) {
+	l := NewSkipList(true, false)
+	for i := 1; i <= 100; i++ {
+		l.Upsert(Item{ID: uint(i), Score: float64(i), SubmittedAt: base})
+	}
+
+	for _, rank := range []int{1, 2, 50, 99, 100} {
+		item, ok := l.At(rank)
+		if !ok || item.ID != uint(101-rank) {
+			t.Errorf("At(%d) = %d %v, want %d", rank, item.ID, ok, 101-rank)
+		}
+	}
+	for _, rank := range []int{0, -1, 101} {
+		if _, ok := l.At(rank); ok {
+			t.Errorf("At(%d) found an item", rank)
+		}
+	}
+	equalIds(t, ids(l.Range(99, 5)), []uint{2, 1})
+	equalIds(t, ids(l.Range(-1, 4)), []uint{100, 99})
+}
+
+func TestSkipListRemove(t *testing.T) {
+	l := NewSkipList(true, false)
+	for i := 1; i <= 5; i++ {
+		l.Upsert(Item{ID: uint(i), Score: float64(i), SubmittedAt: base})
+	}
+
+	if !l.Remove(3) {
+		t.Fatal("Remove(3) found nothing")
+	}
+	if l.Remove(3) {
+		t.Error("Remove(3) removed it twice")
+	}
+	if l.Rank(3) != 0 || l.Len() != 4 {
+		t.Errorf("after Remove(3) Rank = %d and Len = %d", l.Rank(3), l.Len())
+	}
+	equalIds(t, ids(l.Range(1, 10)), []uint{5, 4, 2, 1})
+	if got := l.Rank(2); got != 3 {
+		t.Errorf("Rank(2) = %d, want 3", got)
+	}
+}
+
+func TestSkipListDuplicateScores(t *testing.T) {
+	// equal scores rank by metric, then by the earliest submission, then by id
+	l := NewSkipList(true, true)
+	l.Upsert(Item{ID: 1, Score: 10, Metric: 1, SubmittedAt: base.Add(time.Second)})
+	l.Upsert(Item{ID: 2, Score: 10, Metric: 1, SubmittedAt: base})
+	l.Upsert(Item{ID: 3, Score: 10, Metric: 2, SubmittedAt: base.Add(time.Hour)})
+	l.Upsert(Item{ID: 5, Score: 10, Metric: 1, SubmittedAt: base})
+	l.Upsert(Item{ID: 4, Score: 20, Metric: 0, SubmittedAt: base.Add(time.Hour)})
+
+	equalIds(t, ids(l.Range(1, 10)), []uint{4, 3, 2, 5, 1})
+	for rank, id := range []uint{4, 3, 2, 5, 1} {
+		if got := l.Rank(id); got != rank+1 {
+			t.Errorf("Rank(%d) = %d, want %d", id, got, rank+1)
+		}
+	}
+	if got := l.Better(10); got != 1 {
+		t.Errorf("Better(10) = %d, want 1", got)
+	}
+}
+
+func TestSkipListRandom(t *testing.T) {
+	l := NewSkipList(true, false)
+	want := make(map[uint]Item)
+	rnd := rand.New(rand.NewSource(1))
+	for step := 0; step < 5000; step++ {
+		id := uint(rnd.Intn(300))
+		if rnd.Intn(4) == 0 {
+			l.Remove(id)
+			delete(want, id)
+			continue
+		}
+		item := Item{ID: id, Score: float64(rnd.Intn(40)), SubmittedAt: base.Add(time.Duration(rnd.Intn(5)) * time.Second)}
+		l.Upsert(item)
+		want[id] = item
+	}
+
+	sorted := make([]Item, 0, len(want))
+	for _, item := range want {
+		sorted = append(sorted, item)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return l.before(sorted[i], sorted[j]) })
+	equalIds(t, ids(l.Range(1, l.Len())), ids(sorted))
+	for i, item := range sorted {
+		if got := l.Rank(item.ID); got != i+1 {
+			t.Fatalf("Rank(%d) = %d, want %d", item.ID, got, i+1)
+		}
+	}
+}
+
+// benchList is a list of n items with scores spread over 10n values
+func benchList(n int) *SkipList {
+	l := NewSkipList(true, false)
+	for i := 0; i < n; i++ {
+		l.Upsert(Item{ID: uint(i + 1), Score: float64(rand.Intn(n * 10)), SubmittedAt: base})
+	}
+	return l
+}
+
+const benchSize = 100000
+
+func BenchmarkSkipListInsert(b *testing.B) {
+	l := benchList(benchSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Upsert(Item{ID: uint(rand.Intn(benchSize) + 1), Score: float64(rand.Intn(benchSize * 10)), SubmittedAt: base})
+	}
+}
+
+func BenchmarkSkipListRemove(b *testing.B) {
+	l := benchList(benchSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		id := uint(rand.Intn(benchSize) + 1)
+		l.Remove(id)
+		b.StopTimer()
+		l.Upsert(Item{ID: id, Score: float64(rand.Intn(benchSize * 10)), SubmittedAt: base})
+		b.StartTimer()
+	}
+}
+
+func BenchmarkSkipListRank(b *testing.B) {
+	l := benchList(benchSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.Rank(uint(rand.Intn(benchSize) + 1))
+	}
+}
+
+func BenchmarkSkipListAt(b *testing.B) {
+	l := benchList(benchSize)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		l.At(rand.Intn(benchSize) + 1)
+	}
+}
@@ -0,0 +1,39 @@
+package ranking
+
+import "errors"
+
+// ErrOrderMismatch is returned when items are added to a set with an order other than the one it was created with
+var ErrOrderMismatch = errors.New("the ranked set was created with another order")
+
+// Order is how a set ranks its items, Desc puts the highest scores first and MetricDesc the highest
+// metrics among equal scores
+type Order struct {
+	Desc       bool
+	MetricDesc bool
+}
+
+// Store keeps named ranked sets the way a sorted set server does, so the rank index can live in the
+// process or in a backend shared by every instance. Reads of a missing set see an empty one.
+type Store interface {
+	// Add inserts the items into the set under key or moves the ones already in it, the set is
+	// created with the order on the first Add
+	Add(key string, order Order, items ...Item) error
+	// Incr adds by to the score of an item and returns it, a missing item is added with the score by
+	Incr(key string, order Order, id uint, by float64) (Item, error)
+	Remove(key string, ids ...uint) error
+	// Rank is the 1 based rank of the id, 0 when it is not in the set
+	Rank(key string, id uint) (int, error)
+	// Better counts the items with a strictly better score
+	Better(key string, score float64) (int, error)
+	// RangeByRank returns up to count items starting at the 1 based rank
+	RangeByRank(key string, start, count int) ([]Item, error)
+	// RangeByScore returns up to limit items scored between min and max, both included, in rank order
+	RangeByScore(key string, min, max float64, limit int) ([]Item, error)
+	Count(key string) (int, error)
+	// Exists reports if there is a set under key, even an empty one
+	Exists(key string) (bool, error)
+	// Keys lists the keys of the sets whose key starts with prefix, sorted
+	Keys(prefix string) ([]string, error)
+	// Delete drops the set under key
+	Delete(key string) error
+}
@@ -0,0 +1,95 @@
+package rating
+
+import "math"
+
+const (
+	// scale converts between the Glicko and Glicko-2 scales
+	scale = 173.7178
+	// base is the rating every scale is centred on
+	base = 1500
+	// epsilon is the convergence tolerance of the volatility iteration
+	epsilon = 0.000001
+)
+
+// Glicko2 rates a player on the results of one rating period, tau constrains how much the volatility
+// may change. See http://www.glicko.net/glicko/glicko2.pdf for the steps.
+func Glicko2(player Rating, results []Result, tau float64) Rating {
+	if len(results) == 0 {
+		return Decay(player, 1, math.Inf(1))
+	}
+	mu := (player.Rating - base) / scale
+	phi := player.Deviation / scale
+
+	// estimated variance of the rating from the game outcomes and the improvement they suggest
+	var invV, sum float64
+	for _, res := range results {
+		muJ := (res.Opponent.Rating - base) / scale
+		g := gphi(res.Opponent.Deviation / scale)
+		e := 1 / (1 + math.Exp(-g*(mu-muJ)))
+		invV += g * g * e * (1 - e)
+		sum += g * (res.Score - e)
+	}
+	v := 1 / invV
+	delta := v * sum
+
+	sigma := volatility(phi, player.Volatility, v, delta, tau)
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	phi = 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	mu += phi * phi * sum
+
+	return Rating{
+		Rating:     mu*scale + base,
+		Deviation:  phi * scale,
+		Volatility: sigma,
+	}
+}
+
+// Decay grows the deviation of a player who sat out periods rating periods, it never goes past limit
+func Decay(player Rating, periods int, limit float64) Rating {
+	if periods <= 0 {
+		return player
+	}
+	phi := player.Deviation / scale
+	phi = math.Sqrt(phi*phi + float64(periods)*player.Volatility*player.Volatility)
+	player.Deviation = math.Min(phi*scale, limit)
+	return player
+}
+
+// gphi weighs an opponent's result down by how uncertain their rating is
+func gphi(phi float64) float64 {
+	return 1 / math.Sqrt(1+3*phi*phi/(math.Pi*math.Pi))
+}
+
+// volatility finds the new volatility with the Illinois algorithm
+func volatility(phi, sigma, v, delta, tau float64) float64 {
+	a := math.Log(sigma * sigma)
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/(tau*tau)
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*tau) < 0 {
+			k++
+		}
+		B = a - k*tau
+	}
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+	return math.Exp(A / 2)
+}
@@ -0,0 +1,36 @@
+// Package rating computes skill ratings from match results with Glicko-2 or Elo.
+package rating
+
+import "math"
+
+// Rating is a player's skill estimate, Deviation and Volatility are only used by Glicko-2
+type Rating struct {
+	Rating     float64
+	Deviation  float64
+	Volatility float64
+}
+
+// Result is the outcome of a game against one opponent, Score is 1 for a win, 0.5 for a draw and 0 for a loss
+type Result struct {
+	Opponent Rating
+	Score    float64
+}
+
+// Expected is the chance a player rated r beats one rated opponent on the Elo scale
+func Expected(r, opponent float64) float64 {
+	return 1 / (1 + math.Pow(10, (opponent-r)/400))
+}
+
+// Elo moves a rating by k times the difference between the results and their expectation. The games of
+// one match share k so a free-for-all moves a rating as much as a duel.
+func Elo(player Rating, results []Result, k float64) Rating {
+	if len(results) == 0 {
+		return player
+	}
+	var change float64
+	for _, res := range results {
+		change += res.Score - Expected(player.Rating, res.Opponent.Rating)
+	}
+	player.Rating += k * change / float64(len(results))
+	return player
+}
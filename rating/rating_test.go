@@ -0,0 +1,62 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func near(t *testing.T, name string, got, want, tolerance float64) {
+	t.Helper()
+	if math.Abs(got-want) > tolerance {
+		t.Errorf("%s = %.5f, want %.5f", name, got, want)
+	}
+}
+
+// the worked example of http://www.glicko.net/glicko/glicko2.pdf
+func TestGlicko2PaperExample(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	results := []Result{
+		{Opponent: Rating{Rating: 1400, Deviation: 30}, Score: 1},
+		{Opponent: Rating{Rating: 1550, Deviation: 100}, Score: 0},
+		{Opponent: Rating{Rating: 1700, Deviation: 300}, Score: 0},
+	}
+	got := Glicko2(player, results, 0.5)
+	near(t, "Rating", got.Rating, 1464.06, 0.01)
+	near(t, "Deviation", got.Deviation, 151.52, 0.01)
+	near(t, "Volatility", got.Volatility, 0.05999, 0.00001)
+}
+
+func TestGlicko2WithoutGames(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 200, Volatility: 0.06}
+	got := Glicko2(player, nil, 0.5)
+	if got.Rating != player.Rating || got.Volatility != player.Volatility {
+		t.Errorf("a period without games changed %+v to %+v", player, got)
+	}
+	near(t, "Deviation", got.Deviation, math.Sqrt(200*200+0.06*0.06*scale*scale), 0.001)
+}
+
+func TestDecay(t *testing.T) {
+	player := Rating{Rating: 1500, Deviation: 50, Volatility: 0.06}
+	if got := Decay(player, 0, 350); got != player {
+		t.Errorf("Decay over no periods = %+v, want %+v", got, player)
+	}
+	one, ten := Decay(player, 1, 350), Decay(player, 10, 350)
+	if !(player.Deviation < one.Deviation && one.Deviation < ten.Deviation) {
+		t.Errorf("deviation should grow with the periods sat out: %v, %v, %v", player.Deviation, one.Deviation, ten.Deviation)
+	}
+	if got := Decay(player, 10000, 350); got.Deviation != 350 {
+		t.Errorf("Decay past the limit = %v, want 350", got.Deviation)
+	}
+}
+
+func TestElo(t *testing.T) {
+	player := Rating{Rating: 1500}
+	opponent := Rating{Rating: 1500}
+	near(t, "win", Elo(player, []Result{{Opponent: opponent, Score: 1}}, 32).Rating, 1516, 0.001)
+	near(t, "draw", Elo(player, []Result{{Opponent: opponent, Score: 0.5}}, 32).Rating, 1500, 0.001)
+
+	// a free-for-all win over three moves the rating as much as a duel win
+	ffa := Elo(player, []Result{{Opponent: opponent, Score: 1}, {Opponent: opponent, Score: 1}, {Opponent: opponent, Score: 1}}, 32)
+	near(t, "free-for-all win", ffa.Rating, 1516, 0.001)
+	near(t, "Expected", Expected(1600, 1400), 1/(1+math.Pow(10, -0.5)), 1e-9)
+}
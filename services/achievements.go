@@ -0,0 +1,311 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Achievement kinds
+const (
+	// AchievementSubmissions counts the scores a player got onto boards, such as 100 submissions
+	AchievementSubmissions = "submissions"
+	// AchievementRank is reaching Rank or better once, such as a first #1
+	AchievementRank = "rank"
+	// AchievementRankDays is holding Rank or better on Target days in a row, such as top 10 for 7 days
+	AchievementRankDays = "rank_days"
+)
+
+// NotificationAchievement tells a player they earned an achievement
+const NotificationAchievement = "achievement_awarded"
+
+// AchievementService is an interface for achievement definitions and player progress
+type AchievementService interface {
+	CreateAchievement(achievement entity.Achievement) (entity.Achievement, error)
+	ListAchievements() ([]entity.Achievement, error)
+	UserAchievements(userId string) ([]entity.AchievementProgress, error)
+	EvaluateStreaks() error
+}
+
+// achievementservice is an implementation of AchievementService
+type achievementservice struct{}
+
+// NewAchievementService creates and returns a new instance of AchievementService
+func NewAchievementService() AchievementService {
+	return &achievementservice{}
+}
+
+// CreateAchievement adds an achievement definition, progress starts counting from then on
+func (s *achievementservice) CreateAchievement(achievement entity.Achievement) (entity.Achievement, error) {
+	if achievement.Window == "" {
+		achievement.Window = WindowAllTime
+	}
+	if achievement.Target == 0 {
+		achievement.Target = 1
+	}
+	if err := validateAchievement(achievement); err != nil {
+		return entity.Achievement{}, err
+	}
+
+	newAchievement := model.Achievement{
+		Code:          achievement.Code,
+		Name:          achievement.Name,
+		Description:   achievement.Description,
+		LeaderboardId: achievement.LeaderboardId,
+		Kind:          achievement.Kind,
+		Window:        achievement.Window,
+		Rank:          achievement.Rank,
+		Target:        achievement.Target,
+	}
+	if err := config.DB.Create(&newAchievement).Error; err != nil {
+		return entity.Achievement{}, err
+	}
+	return toAchievementEntity(newAchievement), nil
+}
+
+// ListAchievements returns every achievement definition
+func (s *achievementservice) ListAchievements() ([]entity.Achievement, error) {
+	var achievements []model.Achievement
+	if err := config.DB.Order("id").Find(&achievements).Error; err != nil {
+		return nil, err
+	}
+	list := make([]entity.Achievement, 0, len(achievements))
+	for _, a := range achievements {
+		list = append(list, toAchievementEntity(a))
+	}
+	return list, nil
+}
+
+// UserAchievements returns a player's progress on every achievement, earned or not
+func (s *achievementservice) UserAchievements(userId string) ([]entity.AchievementProgress, error) {
+	var achievements []model.Achievement
+	if err := config.DB.Order("id").Find(&achievements).Error; err != nil {
+		return nil, err
+	}
+	var progress []model.AchievementProgress
+	if err := config.DB.Where("user_id = ?", userId).Find(&progress).Error; err != nil {
+		return nil, err
+	}
+	byAchievement := make(map[uint]model.AchievementProgress, len(progress))
+	for _, p := range progress {
+		byAchievement[p.AchievementId] = p
+	}
+
+	list := make([]entity.AchievementProgress, 0, len(achievements))
+	for _, a := range achievements {
+		p := byAchievement[a.ID]
+		list = append(list, entity.AchievementProgress{
+			Achievement: toAchievementEntity(a),
+			Progress:    p.Progress,
+			AwardedAt:   p.AwardedAt,
+		})
+	}
+	return list, nil
+}
+
+// EvaluateStreaks counts a day for every player holding the rank of a rank_days achievement. It runs
+// many times a day, a player is only counted once per UTC day and a missed day starts the streak over.
+// An achievement that fails does not hold the others back, the errors are returned together.
+func (s *achievementservice) EvaluateStreaks() error {
+	var achievements []model.Achievement
+	if err := config.DB.Where("kind = ?", AchievementRankDays).Find(&achievements).Error; err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	today := now.Format(time.DateOnly)
+	yesterday := now.AddDate(0, 0, -1).Format(time.DateOnly)
+
+	var errs []error
+	for _, a := range achievements {
+		board, err := findLeaderboard(config.DB, *a.LeaderboardId)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("achievement %s: %w", a.Code, err))
+			continue
+		}
+		p, err := queryPeriod(config.DB, board, entity.LeaderboardQuery{Window: a.Window})
+		if err != nil {
+			// a season window without a running season has nobody to count
+			continue
+		}
+		top, err := topEntries(board, p, a.Rank)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("achievement %s: %w", a.Code, err))
+			continue
+		}
+		for _, entry := range top {
+			if entry.Rank > a.Rank {
+				continue
+			}
+			err := advance(a, entry.UserId, func(progress *model.AchievementProgress) {
+				switch progress.LastDay {
+				case today:
+					return
+				case yesterday:
+					progress.Progress++
+				default:
+					progress.Progress = 1
+				}
+				progress.LastDay = today
+			})
+			if err != nil {
+				errs = append(errs, fmt.Errorf("achievement %s: %w", a.Code, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordAchievements moves the achievements a counted score can reach, entries are the player's
+// entries after the score keyed by window. One achievement failing does not hold the others back.
+func recordAchievements(board model.Leaderboard, userId string, entries map[string]entity.LeaderboardEntry) error {
+	var achievements []model.Achievement
+	result := config.DB.Where("(leaderboard_id IS NULL OR leaderboard_id = ?) AND kind IN ?",
+		board.ID, []string{AchievementSubmissions, AchievementRank}).Find(&achievements)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	var errs []error
+	for _, a := range achievements {
+		var step func(progress *model.AchievementProgress)
+		switch a.Kind {
+		case AchievementSubmissions:
+			step = func(progress *model.AchievementProgress) {
+				progress.Progress++
+			}
+		case AchievementRank:
+			entry, ok := entries[a.Window]
+			if !ok || entry.Rank > a.Rank {
+				continue
+			}
+			step = func(progress *model.AchievementProgress) {
+				progress.Progress = 1
+			}
+		}
+		if err := advance(a, userId, step); err != nil {
+			errs = append(errs, fmt.Errorf("achievement %s: %w", a.Code, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// advance applies a step to a player's progress and awards the achievement the first time it reaches
+// the target. Progress stops once awarded, so replaying a step never awards twice.
+func advance(a model.Achievement, userId string, step func(progress *model.AchievementProgress)) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.AchievementProgress{AchievementId: a.ID, UserId: userId})
+		if result.Error != nil {
+			return result.Error
+		}
+		var progress model.AchievementProgress
+		result = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("achievement_id = ? AND user_id = ?", a.ID, userId).
+			First(&progress)
+		if result.Error != nil {
+			return result.Error
+		}
+		if progress.AwardedAt != nil {
+			return nil
+		}
+
+		step(&progress)
+		if progress.Progress >= a.Target {
+			now := time.Now()
+			progress.AwardedAt = &now
+			notification := model.Notification{
+				UserId:  userId,
+				Kind:    NotificationAchievement,
+				Message: fmt.Sprintf("You earned the %s achievement", a.Name),
+			}
+			if err := tx.Create(&notification).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&progress).Error
+	})
+}
+
+// userBadges returns the achievements a player earned, oldest first
+func userBadges(db *gorm.DB, userId string) ([]entity.Badge, error) {
+	var rows []struct {
+		Code        string
+		Name        string
+		Description string
+		AwardedAt   time.Time
+	}
+	result := db.Model(&model.AchievementProgress{}).
+		Select("achievements.code, achievements.name, achievements.description, achievement_progresses.awarded_at").
+		Joins("JOIN achievements ON achievements.id = achievement_progresses.achievement_id AND achievements.deleted_at IS NULL").
+		Where("achievement_progresses.user_id = ? AND achievement_progresses.awarded_at IS NOT NULL", userId).
+		Order("achievement_progresses.awarded_at").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	badges := make([]entity.Badge, 0, len(rows))
+	for _, row := range rows {
+		badges = append(badges, entity.Badge{
+			Code:        row.Code,
+			Name:        row.Name,
+			Description: row.Description,
+			AwardedAt:   row.AwardedAt,
+		})
+	}
+	return badges, nil
+}
+
+// validateAchievement checks an achievement definition
+func validateAchievement(a entity.Achievement) error {
+	if len(a.Code) > 50 || len(a.Name) > 100 {
+		return invalid("code must be at most 50 and name at most 100 characters")
+	}
+	if a.Target < 1 {
+		return invalid("target must be at least 1")
+	}
+	if !slices.Contains(Windows, a.Window) && a.Window != WindowSeason {
+		return invalid("window must be all, daily, weekly, monthly or season")
+	}
+	switch a.Kind {
+	case AchievementSubmissions:
+	case AchievementRank, AchievementRankDays:
+		if a.Rank < 1 {
+			return invalid("rank must be at least 1")
+		}
+		if a.Kind == AchievementRank && a.Target != 1 {
+			return invalid("a rank achievement is reached once, its target must be 1")
+		}
+		if a.Kind == AchievementRankDays && a.LeaderboardId == nil {
+			return invalid("a rank_days achievement needs a leaderboard_id")
+		}
+	default:
+		return invalid("kind must be submissions, rank or rank_days")
+	}
+	if a.LeaderboardId != nil {
+		if _, err := findLeaderboard(config.DB, *a.LeaderboardId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toAchievementEntity(a model.Achievement) entity.Achievement {
+	return entity.Achievement{
+		ID:            a.ID,
+		Code:          a.Code,
+		Name:          a.Name,
+		Description:   a.Description,
+		LeaderboardId: a.LeaderboardId,
+		Kind:          a.Kind,
+		Window:        a.Window,
+		Rank:          a.Rank,
+		Target:        a.Target,
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+func TestEvaluateStreaksSkipsFailedAchievement(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a")
+	board, err := NewLeaderboardService().CreateLeaderboard(entity.Leaderboard{Name: "streaks", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit(t, board.ID, "a", 10)
+	missing := board.ID + 1
+	achievements := []model.Achievement{
+		{Code: "gone", Name: "Gone", LeaderboardId: &missing, Kind: AchievementRankDays, Window: WindowAllTime, Rank: 1, Target: 3},
+		{Code: "top", Name: "Top", LeaderboardId: &board.ID, Kind: AchievementRankDays, Window: WindowAllTime, Rank: 1, Target: 3},
+	}
+	if err := config.DB.Create(&achievements).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewAchievementService().EvaluateStreaks(); err == nil {
+		t.Error("got no error for an achievement on a missing board")
+	}
+	var progress model.AchievementProgress
+	if err := config.DB.Where("achievement_id = ? AND user_id = ?", achievements[1].ID, "a").First(&progress).Error; err != nil {
+		t.Fatalf("the achievement after the failed one was not counted: %v", err)
+	}
+	if progress.Progress != 1 {
+		t.Errorf("got progress %d, want 1", progress.Progress)
+	}
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+// Plausibility rules a submission can break
+const (
+	FlagBelowMin    = "below_min"
+	FlagAboveMax    = "above_max"
+	FlagImprovement = "improvement"
+	FlagRate        = "rate"
+	FlagZScore      = "z_score"
+)
+
+// minZScoreSample is how many entries a board needs before the z-score rule means anything
+const minZScoreSample = 30
+
+// scoreStats are the count, mean and mean square of the all time scores of a board
+type scoreStats struct {
+	Total  int64
+	Mean   float64
+	MeanSq float64
+}
+
+// checkPlausibility returns the rules of the board the score breaks
+func checkPlausibility(tx *gorm.DB, board model.Leaderboard, userId string, score float64, now time.Time) ([]string, error) {
+	// a jump past the personal best only means something when entries hold single scores
+	var best *float64
+	if board.MaxImprovement != nil && (board.Aggregation == AggregateBest || board.Aggregation == AggregateLatest) {
+		entry, err := findEntry(tx, board, period{Window: WindowAllTime, Start: allTimeStart}, userId)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if err == nil {
+			best = &entry.Score
+		}
+	}
+	var recent int
+	if board.MaxPerMinute > 0 {
+		var err error
+		if recent, err = recentSubmissions(tx, board, userId, now); err != nil {
+			return nil, err
+		}
+	}
+	var stats scoreStats
+	if board.MaxZScore > 0 {
+		var err error
+		if stats, err = allTimeStats(tx, board); err != nil {
+			return nil, err
+		}
+	}
+	return plausibilityFlags(board, score, best, recent, stats), nil
+}
+
+// plausibilityFlags returns the rules a score breaks given the player's all time score, how many
+// scores they sent in the minute before and the all time scores of the board
+func plausibilityFlags(board model.Leaderboard, score float64, best *float64, recent int, stats scoreStats) []string {
+	var flags []string
+	if board.MinScore != nil && score < *board.MinScore {
+		flags = append(flags, FlagBelowMin)
+	}
+	if board.MaxScore != nil && score > *board.MaxScore {
+		flags = append(flags, FlagAboveMax)
+	}
+
+	if board.MaxImprovement != nil && (board.Aggregation == AggregateBest || board.Aggregation == AggregateLatest) &&
+		best != nil && *best != 0 {
+		improvement := (score - *best) / math.Abs(*best)
+		if board.SortOrder == SortAsc {
+			improvement = -improvement
+		}
+		if improvement > *board.MaxImprovement {
+			flags = append(flags, FlagImprovement)
+		}
+	}
+
+	if board.MaxPerMinute > 0 && recent >= board.MaxPerMinute {
+		flags = append(flags, FlagRate)
+	}
+
+	// only scores too good to be true count, a bad run is not suspicious
+	if sd := math.Sqrt(math.Max(stats.MeanSq-stats.Mean*stats.Mean, 0)); board.MaxZScore > 0 && stats.Total >= minZScoreSample && sd > 0 {
+		z := (score - stats.Mean) / sd
+		if board.SortOrder == SortAsc {
+			z = -z
+		}
+		if z > board.MaxZScore {
+			flags = append(flags, FlagZScore)
+		}
+	}
+	return flags
+}
+
+// recentSubmissions counts the scores a player sent to a board in the minute up to t, later ones are
+// left out so a score imported with a past time is only compared with those around it
+func recentSubmissions(tx *gorm.DB, board model.Leaderboard, userId string, t time.Time) (int, error) {
+	var recent int64
+	result := tx.Model(&model.Submission{}).
+		Where("leaderboard_id = ? AND user_id = ? AND created_at > ? AND created_at <= ?", board.ID, userId, t.Add(-time.Minute), t).
+		Count(&recent)
+	return int(recent), result.Error
+}
+
+// allTimeStats reads the scoreStats of a board
+func allTimeStats(tx *gorm.DB, board model.Leaderboard) (scoreStats, error) {
+	var stats scoreStats
+	result := periodEntries(tx, board, period{Window: WindowAllTime, Start: allTimeStart}).
+		Select("COUNT(*) AS total, COALESCE(AVG(score), 0) AS mean, COALESCE(AVG(score * score), 0) AS mean_sq").
+		Scan(&stats)
+	return stats, result.Error
+}
+
+// validateRules checks the plausibility rules of a board
+func validateRules(rules entity.PlausibilityRules) error {
+	if rules.MinScore != nil && rules.MaxScore != nil && *rules.MinScore > *rules.MaxScore {
+		return invalid("min_score must not be above max_score")
+	}
+	if rules.MaxImprovement != nil && *rules.MaxImprovement <= 0 {
+		return invalid("max_improvement must be above 0")
+	}
+	if rules.MaxPerMinute < 0 {
+		return invalid("max_per_minute must not be negative")
+	}
+	if rules.MaxZScore < 0 {
+		return invalid("max_z_score must not be negative")
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+// maxBatchScores is how many scores one batch may carry
+const maxBatchScores = 500
+
+// errBatchSkipped is reported for the valid scores of an atomic batch that was not applied
+var errBatchSkipped = errors.New("not applied, another score of the atomic batch failed")
+
+// SubmitBatch applies the scores a game server sends for many players and boards at once. Every score
+// is checked first. An atomic batch is applied in one transaction and only when all of them pass,
+// otherwise each score is applied on its own. The rank changes are published once per board. An
+// atomic batch that fails for a reason other than its scores, a DB error say, returns the error.
+func (s *leaderboardservice) SubmitBatch(batch entity.BatchSubmission) (entity.BatchReport, error) {
+	if len(batch.Scores) == 0 {
+		return entity.BatchReport{}, invalid("the batch has no scores")
+	}
+	if len(batch.Scores) > maxBatchScores {
+		return entity.BatchReport{}, invalidf("a batch can carry at most %d scores", maxBatchScores)
+	}
+
+	report := entity.BatchReport{Atomic: batch.Atomic, Results: make([]entity.BatchResult, len(batch.Scores))}
+	boards := make([]model.Leaderboard, len(batch.Scores))
+	signed := make([]bool, len(batch.Scores))
+	failed := make([]bool, len(batch.Scores))
+	fail := func(i int, err error) {
+		report.Results[i].Error = rejected(batch.Scores[i], err).Error()
+		failed[i] = true
+		report.Failed++
+	}
+	checkErrs := make([]error, len(batch.Scores))
+	for i, submission := range batch.Scores {
+		report.Results[i].Index = i
+		if boards[i], signed[i], checkErrs[i] = checkSubmission(submission); checkErrs[i] != nil {
+			fail(i, checkErrs[i])
+		}
+	}
+	if batch.Atomic && report.Failed > 0 {
+		for _, err := range checkErrs {
+			if err != nil && !refused(err) {
+				return entity.BatchReport{}, err
+			}
+		}
+		skipAll(&report, failed)
+		return report, nil
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	recs := make([]recorded, len(batch.Scores))
+	if batch.Atomic {
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			for i, submission := range batch.Scores {
+				var err error
+				if recs[i], err = recordSubmission(tx, boards[i], submission, signed[i], now); err != nil {
+					if refused(err) {
+						fail(i, err)
+					}
+					return err
+				}
+			}
+			return nil
+		})
+		// a batch that failed without a score to blame, on the commit say, reports the error itself
+		if err != nil && report.Failed == 0 {
+			return entity.BatchReport{}, err
+		}
+		if err != nil {
+			skipAll(&report, failed)
+			return report, nil
+		}
+	} else {
+		for i, submission := range batch.Scores {
+			if failed[i] {
+				continue
+			}
+			err := config.DB.Transaction(func(tx *gorm.DB) error {
+				var err error
+				recs[i], err = recordSubmission(tx, boards[i], submission, signed[i], now)
+				return err
+			})
+			if err != nil {
+				fail(i, err)
+			}
+		}
+	}
+
+	// the writes are grouped by board so each board publishes its rank changes once
+	var order []uint
+	byBoard := map[uint][]int{}
+	for i := range batch.Scores {
+		if failed[i] {
+			continue
+		}
+		id := boards[i].ID
+		if _, ok := byBoard[id]; !ok {
+			order = append(order, id)
+		}
+		byBoard[id] = append(byBoard[id], i)
+	}
+	for _, id := range order {
+		items := byBoard[id]
+		writes := make([][]entryWrite, len(items))
+		for j, i := range items {
+			writes[j] = recs[i].writes
+		}
+		// the scores are saved by now, a failure to rank them must not fail the batch
+		entries, err := afterWrites(boards[items[0]], writes)
+		if err != nil {
+			fmt.Println("Error ranking the submitted scores:", err)
+			entries = make([]map[string]entity.LeaderboardEntry, len(items))
+		}
+		for j, i := range items {
+			result := recs[i].result(boards[i], entries[j])
+			report.Results[i].Result = &result
+			report.Accepted++
+		}
+	}
+	return report, nil
+}
+
+// skipAll marks the scores of a failed atomic batch that had no error of their own
+func skipAll(report *entity.BatchReport, failed []bool) {
+	for i := range report.Results {
+		if !failed[i] {
+			report.Results[i].Error = errBatchSkipped.Error()
+		}
+	}
+}
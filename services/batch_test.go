@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+func TestSubmitBatch(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b", "c")
+	boards := NewLeaderboardService()
+	first, err := boards.CreateLeaderboard(entity.Leaderboard{Name: "first", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := boards.CreateLeaderboard(entity.Leaderboard{Name: "second", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewModerationService().Ban("mod", entity.NewBan{UserId: "c", LeaderboardId: second.ID, Reason: "cheating"}); err != nil {
+		t.Fatal(err)
+	}
+
+	scores := []entity.ScoreSubmission{
+		{LeaderboardId: first.ID, UserId: "a", Score: 10},
+		{LeaderboardId: second.ID, UserId: "b", Score: 20},
+		{LeaderboardId: second.ID, UserId: "c", Score: 30},
+		{LeaderboardId: first.ID, UserId: "b", Score: 40},
+	}
+	send := func(atomic bool, scores []entity.ScoreSubmission) entity.BatchReport {
+		t.Helper()
+		report, err := boards.SubmitBatch(entity.BatchSubmission{Scores: scores, Atomic: atomic})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	errs := func(report entity.BatchReport) string {
+		var got []string
+		for _, res := range report.Results {
+			switch {
+			case res.Error == errBatchSkipped.Error():
+				got = append(got, "skipped")
+			case res.Error != "":
+				got = append(got, "failed")
+			case res.Result != nil:
+				got = append(got, "ok")
+			}
+		}
+		return strings.Join(got, " ")
+	}
+	written := func() int64 {
+		t.Helper()
+		var n int64
+		if err := config.DB.Model(&model.Submission{}).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	top := func(boardId uint) string {
+		t.Helper()
+		page, err := boards.TopEntries(entity.LeaderboardQuery{LeaderboardId: boardId, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, entry := range page.Entries {
+			got = append(got, fmt.Sprintf("%s=%g", entry.UserId, entry.Score))
+		}
+		return strings.Join(got, " ")
+	}
+
+	// one refused score stops an atomic batch
+	report := send(true, scores)
+	if report.Accepted != 0 || report.Failed != 1 || errs(report) != "skipped skipped failed skipped" {
+		t.Errorf("atomic batch: got %+v", report)
+	}
+	if n := written(); n != 0 {
+		t.Errorf("a failed atomic batch wrote %d submissions", n)
+	}
+
+	// a batch applied per item keeps the scores that pass
+	report = send(false, scores)
+	if report.Accepted != 3 || report.Failed != 1 || errs(report) != "ok ok failed ok" {
+		t.Errorf("per item batch: got %+v", report)
+	}
+	if got := top(first.ID); got != "b=40 a=10" {
+		t.Errorf("first board: got %q, want b=40 a=10", got)
+	}
+	if got := top(second.ID); got != "b=20" {
+		t.Errorf("second board: got %q, want b=20", got)
+	}
+
+	report = send(true, []entity.ScoreSubmission{scores[0], scores[1]})
+	if report.Accepted != 2 || report.Failed != 0 || errs(report) != "ok ok" {
+		t.Errorf("clean atomic batch: got %+v", report)
+	}
+	if n := written(); n != 5 {
+		t.Errorf("got %d submissions written, want 5", n)
+	}
+
+	var invalid *InvalidError
+	if _, err := boards.SubmitBatch(entity.BatchSubmission{}); !errors.As(err, &invalid) {
+		t.Errorf("empty batch: got %v, want an InvalidError", err)
+	}
+	if _, err := boards.SubmitBatch(entity.BatchSubmission{Scores: make([]entity.ScoreSubmission, maxBatchScores+1)}); !errors.As(err, &invalid) {
+		t.Errorf("oversized batch: got %v, want an InvalidError", err)
+	}
+}
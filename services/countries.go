@@ -0,0 +1,95 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+// discordLocales maps the Discord locales to the country they are spoken in, es-419 covers
+// all of Latin America so it gives no country
+var discordLocales = map[string]string{
+	"id":    "ID",
+	"da":    "DK",
+	"de":    "DE",
+	"en-GB": "GB",
+	"en-US": "US",
+	"es-ES": "ES",
+	"fr":    "FR",
+	"hr":    "HR",
+	"it":    "IT",
+	"lt":    "LT",
+	"hu":    "HU",
+	"nl":    "NL",
+	"no":    "NO",
+	"pl":    "PL",
+	"pt-BR": "BR",
+	"ro":    "RO",
+	"fi":    "FI",
+	"sv-SE": "SE",
+	"vi":    "VN",
+	"tr":    "TR",
+	"cs":    "CZ",
+	"el":    "GR",
+	"bg":    "BG",
+	"ru":    "RU",
+	"uk":    "UA",
+	"hi":    "IN",
+	"th":    "TH",
+	"zh-CN": "CN",
+	"ja":    "JP",
+	"zh-TW": "TW",
+	"ko":    "KR",
+}
+
+// countryFromLocale guesses a player's country from their Discord locale, "" when it cannot tell
+func countryFromLocale(locale string) string {
+	return discordLocales[locale]
+}
+
+// validateCountry checks a country is an ISO 3166-1 alpha-2 code
+func validateCountry(country string) error {
+	if len(country) != 2 || strings.Trim(country, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return invalid("country must be a two letter ISO 3166-1 code")
+	}
+	return nil
+}
+
+// countryOf returns the country on a player's details, "" when they have none
+func countryOf(db *gorm.DB, userId string) (string, error) {
+	var countries []string
+	result := db.Model(&model.User_Details{}).Where("user_id = ?", userId).Limit(1).Pluck("country", &countries)
+	if result.Error != nil || len(countries) == 0 {
+		return "", result.Error
+	}
+	return countries[0], nil
+}
+
+// moveEntries files a player's entries under their new country, archived periods included so the
+// country boards of past periods show the player where they are now
+func moveEntries(tx *gorm.DB, userId, country string) error {
+	return tx.Model(&model.LeaderboardEntry{}).Where("user_id = ?", userId).Update("country", country).Error
+}
+
+// rankMoved tells the ranker about the entries of a player who changed country
+func rankMoved(userId string) error {
+	var entries []model.LeaderboardEntry
+	if err := config.DB.Where("user_id = ?", userId).Find(&entries).Error; err != nil {
+		return err
+	}
+	byBoard := make(map[uint][]model.LeaderboardEntry)
+	for _, entry := range entries {
+		byBoard[entry.LeaderboardId] = append(byBoard[entry.LeaderboardId], entry)
+	}
+	for id, moved := range byBoard {
+		// the sets of a shared store are written with the order of the board
+		board, err := findLeaderboard(config.DB, id)
+		if err != nil {
+			return err
+		}
+		ranks.Move(board, moved...)
+	}
+	return nil
+}
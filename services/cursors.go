@@ -0,0 +1,162 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+// MaxPageSize is the most rows a page of any list returns
+const MaxPageSize = 100
+
+// ErrInvalidCursor is returned for a cursor that was tampered with or issued for another list
+var ErrInvalidCursor = invalid("invalid cursor")
+
+// cursor is the position a page starts from: the sort key of the row next to it and the
+// direction to read in. It is signed so clients cannot forge a position.
+type cursor struct {
+	// Scope names the list the cursor was issued for
+	Scope  string  `json:"s"`
+	Score  float64 `json:"v,omitempty"`
+	Metric float64 `json:"m,omitempty"`
+	At     int64   `json:"t,omitempty"`
+	Key    string  `json:"k,omitempty"`
+	ID     uint    `json:"i"`
+	// Back reads the rows before the cursor instead of after it
+	Back bool `json:"b,omitempty"`
+}
+
+// encodeCursor signs a cursor into an opaque token
+func encodeCursor(c cursor) string {
+	payload, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(payload) + "." + base64.RawURLEncoding.EncodeToString(cursorMac(payload))
+}
+
+// decodeCursor checks the signature of a token and that it belongs to the list read
+func decodeCursor(token string, scope string) (cursor, error) {
+	data, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return cursor{}, ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(data)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, cursorMac(payload)) {
+		return cursor{}, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.Scope != scope {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func cursorMac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(os.Getenv("SECRET")))
+	mac.Write([]byte("cursor:"))
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// pager reads one page of a list in keyset order
+type pager struct {
+	scope string
+	limit int
+	// from is the cursor the page was asked from, nil for the first page
+	from *cursor
+}
+
+// newPager starts reading a list from a token, an empty token reads the first page
+func newPager(scope string, token string, limit int) (pager, error) {
+	if limit < 1 || limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+	pg := pager{scope: scope, limit: limit}
+	if token == "" {
+		return pg, nil
+	}
+	c, err := decodeCursor(token, scope)
+	if err != nil {
+		return pager{}, err
+	}
+	pg.from = &c
+	return pg, nil
+}
+
+// back reports if the page is read backward from its cursor
+func (pg pager) back() bool {
+	return pg.from != nil && pg.from.Back
+}
+
+// byId scopes a query to the page of a list ordered by id, one row past the page is read to
+// know if more follow. Rows read backward come in reverse and have to be flipped.
+func (pg pager) byId(db *gorm.DB, desc bool) *gorm.DB {
+	if pg.back() {
+		desc = !desc
+	}
+	if pg.from != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		db = db.Where("id "+op+" ?", pg.from.ID)
+	}
+	order := "id"
+	if desc {
+		order += " desc"
+	}
+	return db.Order(order).Limit(pg.limit + 1)
+}
+
+// cursors builds the links around a page from the keys of its first and last rows, more is set
+// when rows were found past the page in the direction it was read
+func (pg pager) cursors(more bool, first, last *cursor) entity.Cursors {
+	var res entity.Cursors
+	if first == nil {
+		return res
+	}
+	hasPrev, hasNext := more && pg.back(), more && !pg.back()
+	if pg.from != nil {
+		// the page was reached from the other side, so rows lie there
+		hasNext = hasNext || pg.back()
+		hasPrev = hasPrev || !pg.back()
+	}
+	if hasNext {
+		c := *last
+		c.Scope, c.Back = pg.scope, false
+		res.Next = encodeCursor(c)
+	}
+	if hasPrev {
+		c := *first
+		c.Scope, c.Back = pg.scope, true
+		res.Prev = encodeCursor(c)
+	}
+	return res
+}
+
+// idCursor is the key of a row of a list ordered by id
+func idCursor(id uint) *cursor {
+	return &cursor{ID: id}
+}
+
+// entryCursor is the key of an entry in rank order
+func entryCursor(entry model.LeaderboardEntry) *cursor {
+	return &cursor{Score: entry.Score, Metric: entry.Metric, At: entry.SubmittedAt.UnixNano(), ID: entry.ID}
+}
+
+// cursorEntry turns a cursor back into an entry holding the sort key, for the rank order conditions
+func cursorEntry(c cursor) model.LeaderboardEntry {
+	entry := model.LeaderboardEntry{Score: c.Score, Metric: c.Metric, SubmittedAt: time.Unix(0, c.At).UTC()}
+	entry.ID = c.ID
+	return entry
+}
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+func TestCursorTampering(t *testing.T) {
+	t.Setenv("SECRET", "secret")
+	token := encodeCursor(cursor{Scope: "users:a", Key: "alice", ID: 7})
+	if c, err := decodeCursor(token, "users:a"); err != nil || c.Key != "alice" || c.ID != 7 {
+		t.Fatalf("decodeCursor = %+v, %v", c, err)
+	}
+
+	data, sig, _ := strings.Cut(token, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte(`{"s":"users:a","k":"alice","i":1}`))
+	other := encodeCursor(cursor{Scope: "users:b", Key: "alice", ID: 7})
+	tampered := map[string]string{
+		"forged position":   forged + "." + sig,
+		"flipped signature": data + "." + strings.ToUpper(sig),
+		"no signature":      data,
+		"not base64":        "!!." + sig,
+		"another list":      other,
+	}
+	for name, token := range tampered {
+		if _, err := decodeCursor(token, "users:a"); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalidCursor)
+		}
+	}
+
+	// a cursor signed with another secret is refused
+	t.Setenv("SECRET", "rotated")
+	if _, err := decodeCursor(token, "users:a"); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor of another secret: got %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+func TestSearchUsersPages(t *testing.T) {
+	t.Setenv("SECRET", "secret")
+	testDB(t)
+	for i := 1; i <= 5; i++ {
+		details := model.User_Details{UserId: fmt.Sprint(i), Email: fmt.Sprintf("%d@example.com", i), Username: fmt.Sprintf("player%d", i)}
+		if err := config.DB.Create(&details).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	users := New()
+	page := func(cursor string) entity.UserPage {
+		t.Helper()
+		res, err := users.SearchUsers("player", entity.PageQuery{Cursor: cursor, Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	names := func(res entity.UserPage) string {
+		var got []string
+		for _, u := range res.Users {
+			got = append(got, u.Username)
+		}
+		return strings.Join(got, " ")
+	}
+
+	first := page("")
+	if got := names(first); got != "player1 player2" || first.Cursors.Prev != "" {
+		t.Fatalf("first page: got %q prev %q", got, first.Cursors.Prev)
+	}
+	second := page(first.Cursors.Next)
+	if got := names(second); got != "player3 player4" {
+		t.Fatalf("second page: got %q", got)
+	}
+	last := page(second.Cursors.Next)
+	if got := names(last); got != "player5" || last.Cursors.Next != "" {
+		t.Fatalf("last page: got %q next %q", got, last.Cursors.Next)
+	}
+	if got := names(page(last.Cursors.Prev)); got != "player3 player4" {
+		t.Errorf("back from the last page: got %q", got)
+	}
+
+	// a cursor of another search does not page this one
+	other, err := users.SearchUsers("play", entity.PageQuery{Cursor: first.Cursors.Next, Limit: 2})
+	if !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor of another search: got %+v, %v", other, err)
+	}
+}
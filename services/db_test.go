@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB points config.DB at a fresh in-memory DB with every table migrated
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to file::memory: opens a DB of its own
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+	config.DB = db
+	config.SyncDB()
+	return db
+}
+
+// createUsers registers the players a test submits for
+func createUsers(t *testing.T, userIds ...string) {
+	t.Helper()
+	for _, userId := range userIds {
+		user := model.User{UserId: userId, Email: userId + "@example.com", Password: "password", Account_Type: AccountUser}
+		if err := config.DB.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// submit posts a score and fails the test when it is refused
+func submit(t *testing.T, boardId uint, userId string, score float64) entity.SubmissionResult {
+	t.Helper()
+	result, err := NewLeaderboardService().SubmitScore(entity.ScoreSubmission{LeaderboardId: boardId, UserId: userId, Score: score})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+// Rank event types
+const (
+	EventInserted = "inserted"
+	EventMoved    = "moved"
+	EventUpdated  = "updated"
+	EventDropped  = "dropped"
+	// EventReset tells a resuming client its last event is gone and it has to reload the board
+	EventReset = "reset"
+)
+
+const (
+	// eventBacklog is how many events per board are kept for clients resuming after a reconnect
+	eventBacklog = 1024
+	// subscriberBuffer is how many events a connection may fall behind before it is cut off
+	subscriberBuffer = 64
+)
+
+// ErrSlowConsumer closes a subscription that stopped reading its events
+var ErrSlowConsumer = errors.New("subscriber too slow, reconnect with the last event id")
+
+// EventService is an interface for subscribing to the rank changes of a leaderboard
+type EventService interface {
+	Subscribe(filter entity.EventFilter) (*Subscription, error)
+	Unsubscribe(sub *Subscription)
+}
+
+// Subscription is one connection's stream of rank events, C is closed when the hub drops it
+type Subscription struct {
+	C      <-chan entity.RankEvent
+	ch     chan entity.RankEvent
+	filter entity.EventFilter
+	err    error
+	closed bool
+}
+
+// Err returns why the hub closed the subscription
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// eventservice is an implementation of EventService
+type eventservice struct {
+	hub *eventHub
+}
+
+// NewEventService creates and returns a new instance of EventService
+func NewEventService() EventService {
+	return &eventservice{hub: hub}
+}
+
+// Subscribe starts a stream for the filter, replaying what was missed since filter.LastEventId
+func (s *eventservice) Subscribe(filter entity.EventFilter) (*Subscription, error) {
+	board, err := findLeaderboard(config.DB, filter.LeaderboardId)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Window == "" {
+		filter.Window = WindowAllTime
+	}
+	if filter.Window != WindowSeason {
+		if _, err := periodAt(board, filter.Window, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	if filter.Top < 0 {
+		return nil, invalid("top must be positive")
+	}
+	return s.hub.subscribe(filter), nil
+}
+
+// Unsubscribe stops a stream
+func (s *eventservice) Unsubscribe(sub *Subscription) {
+	s.hub.unsubscribe(sub)
+}
+
+// hub fans the rank events of every board out to its subscribers
+var hub = newEventHub()
+
+// rankChange is an entry whose rank or score moved in a write
+type rankChange struct {
+	entry        model.LeaderboardEntry
+	rank         int
+	previousRank int
+	scoreChanged bool
+}
+
+// boardFeed holds the event sequence, recent events and subscribers of one board
+type boardFeed struct {
+	seq     uint64
+	backlog []entity.RankEvent
+	subs    map[*Subscription]struct{}
+}
+
+type eventHub struct {
+	mu    sync.Mutex
+	feeds map[uint]*boardFeed
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{feeds: make(map[uint]*boardFeed)}
+}
+
+// watching reports if the board has ever been subscribed to, boards nobody follows skip building events
+func (h *eventHub) watching(boardId uint) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.feeds[boardId]
+	return ok
+}
+
+func (h *eventHub) subscribe(filter entity.EventFilter) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	feed := h.feed(filter.LeaderboardId)
+
+	var replay []entity.RankEvent
+	if filter.LastEventId > 0 && filter.LastEventId != feed.seq {
+		// the events after the client's last one were already dropped from the backlog, or the id is
+		// from before a restart started the sequence over
+		if filter.LastEventId > feed.seq || len(feed.backlog) == 0 || feed.backlog[0].Id > filter.LastEventId+1 {
+			replay = append(replay, entity.RankEvent{Id: feed.seq, Type: EventReset, LeaderboardId: filter.LeaderboardId, Window: filter.Window, At: time.Now()})
+		} else {
+			for _, event := range feed.backlog {
+				if event.Id > filter.LastEventId && matches(filter, event) {
+					replay = append(replay, event)
+				}
+			}
+		}
+	}
+
+	ch := make(chan entity.RankEvent, subscriberBuffer+len(replay))
+	for _, event := range replay {
+		ch <- event
+	}
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	feed.subs[sub] = struct{}{}
+	return sub
+}
+
+func (h *eventHub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.close(sub, nil)
+}
+
+// publish turns the changes of a write, or of a batch of writes, into events, at looks up the entry at a
+// rank for dropped events. The lookups run outside the lock so a slow read does not hold up other boards.
+func (h *eventHub) publish(board model.Leaderboard, changes []rankChange, at func(entry model.LeaderboardEntry, rank int) (model.LeaderboardEntry, bool)) {
+	h.mu.Lock()
+	feed := h.feed(board.ID)
+	now := time.Now()
+
+	// top is a top N watched on a window, sample keeps an entry of each window to look ranks up from
+	type top struct {
+		window string
+		n      int
+	}
+	entered := map[top]int{}
+	sample := map[string]model.LeaderboardEntry{}
+	previous := make(map[uint]int, len(changes))
+	var tops []top
+	for _, change := range changes {
+		previous[change.entry.ID] = change.previousRank
+		event := entity.RankEvent{
+			LeaderboardId:  board.ID,
+			Window:         change.entry.Window,
+			UserId:         change.entry.UserId,
+			Score:          change.entry.Score,
+			FormattedScore: toEntryEntity(board, change.entry, change.rank).FormattedScore,
+			Rank:           change.rank,
+			PreviousRank:   change.previousRank,
+			At:             now,
+		}
+		switch {
+		case change.previousRank == 0:
+			event.Type = EventInserted
+		case change.previousRank != change.rank:
+			event.Type = EventMoved
+		case change.scoreChanged:
+			event.Type = EventUpdated
+		default:
+			continue
+		}
+		h.emit(feed, event)
+
+		for _, n := range h.tops(feed, change.entry.Window) {
+			if change.rank > n || (change.previousRank != 0 && change.previousRank <= n) {
+				continue
+			}
+			t := top{window: change.entry.Window, n: n}
+			if entered[t] == 0 {
+				tops = append(tops, t)
+			}
+			entered[t]++
+			sample[t.window] = change.entry
+		}
+	}
+
+	h.mu.Unlock()
+
+	// every entry climbing into a top N pushes one out of it, once for every N being watched
+	var dropped []entity.RankEvent
+	for _, t := range tops {
+		for rank := t.n + 1; rank <= t.n+entered[t]; rank++ {
+			pushed, ok := at(sample[t.window], rank)
+			if !ok {
+				break
+			}
+			// an entry of the same batch that was below N before was not pushed out
+			if p, ok := previous[pushed.ID]; ok && (p == 0 || p > t.n) {
+				continue
+			}
+			dropped = append(dropped, entity.RankEvent{
+				Type:           EventDropped,
+				LeaderboardId:  board.ID,
+				Window:         pushed.Window,
+				UserId:         pushed.UserId,
+				Score:          pushed.Score,
+				FormattedScore: toEntryEntity(board, pushed, rank).FormattedScore,
+				Rank:           rank,
+				PreviousRank:   t.n,
+				At:             now,
+			})
+		}
+	}
+	if len(dropped) == 0 {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, event := range dropped {
+		h.emit(feed, event)
+	}
+}
+
+// emit numbers an event, keeps it for resuming clients and hands it to the matching subscribers
+func (h *eventHub) emit(feed *boardFeed, event entity.RankEvent) {
+	feed.seq++
+	event.Id = feed.seq
+	feed.backlog = append(feed.backlog, event)
+	if len(feed.backlog) > eventBacklog {
+		feed.backlog = slices.Delete(feed.backlog, 0, len(feed.backlog)-eventBacklog)
+	}
+
+	for sub := range feed.subs {
+		if !matches(sub.filter, event) {
+			continue
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			// never block a write on a stalled connection, it can resume from its last event id
+			h.close(sub, ErrSlowConsumer)
+		}
+	}
+}
+
+// tops returns the distinct top N sizes watched on a window
+func (h *eventHub) tops(feed *boardFeed, window string) []int {
+	var tops []int
+	for sub := range feed.subs {
+		if sub.filter.Top > 0 && sub.filter.Window == window && !slices.Contains(tops, sub.filter.Top) {
+			tops = append(tops, sub.filter.Top)
+		}
+	}
+	return tops
+}
+
+func (h *eventHub) feed(boardId uint) *boardFeed {
+	feed, ok := h.feeds[boardId]
+	if !ok {
+		feed = &boardFeed{subs: make(map[*Subscription]struct{})}
+		h.feeds[boardId] = feed
+	}
+	return feed
+}
+
+func (h *eventHub) close(sub *Subscription, err error) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	sub.err = err
+	delete(h.feed(sub.filter.LeaderboardId).subs, sub)
+	close(sub.ch)
+}
+
+// matches reports if an event passes a subscription's filter
+func matches(filter entity.EventFilter, event entity.RankEvent) bool {
+	if event.Window != filter.Window {
+		return false
+	}
+	if len(filter.UserIds) > 0 && !slices.Contains(filter.UserIds, event.UserId) {
+		return false
+	}
+	if event.Type == EventDropped {
+		return filter.Top == event.PreviousRank
+	}
+	if filter.Top > 0 && event.Type != EventReset {
+		return event.Rank <= filter.Top || (event.PreviousRank > 0 && event.PreviousRank <= filter.Top)
+	}
+	return true
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/utils"
+	"gorm.io/gorm"
+)
+
+// Export formats
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// ExportColumns are the columns an export can hold
+var ExportColumns = []string{"rank", "user_id", "username", "score", "formatted_score", "timestamp", "country", "submissions"}
+
+// defaultExportColumns are written when the export does not pick its columns
+var defaultExportColumns = []string{"rank", "username", "score", "timestamp", "country"}
+
+// exportBatch is how many rows an export reads at a time, the rows of a batch are written and
+// flushed before the next one is read
+const exportBatch = 500
+
+// Export is a checked export request, the rows are only read when it is written
+type Export interface {
+	ContentType() string
+	Filename() string
+	Write(w io.Writer) error
+}
+
+// export streams the rows of a board period or of the standings of a closed season
+type export struct {
+	board   model.Leaderboard
+	format  string
+	columns []string
+	name    string
+	// walk reads the rows in rank order, a batch at a time
+	walk func(tx *gorm.DB, fn func(rows []exportRow) error) error
+}
+
+type exportRow struct {
+	rank  int
+	entry model.LeaderboardEntry
+}
+
+// flusher is implemented by the response writers that can push what was written to the client
+type flusher interface {
+	Flush()
+}
+
+// Export checks an export request: the board period or season, the format and the columns
+func (s *leaderboardservice) Export(query entity.ExportQuery) (Export, error) {
+	board, err := findLeaderboard(config.DB, query.LeaderboardId)
+	if err != nil {
+		return nil, err
+	}
+	e := &export{board: board, format: strings.ToLower(query.Format)}
+	if e.format == "" {
+		e.format = ExportCSV
+	}
+	if e.format != ExportCSV && e.format != ExportNDJSON {
+		return nil, invalid("format must be csv or ndjson")
+	}
+	if e.columns, err = exportColumns(query.Columns); err != nil {
+		return nil, err
+	}
+
+	if query.Season > 0 {
+		_, season, err := findSeason(board.ID, query.Season)
+		if err != nil {
+			return nil, err
+		}
+		e.name = fmt.Sprintf("leaderboard-%d-season-%d", board.ID, season.Number)
+		if season.Status == SeasonOpen {
+			p := seasonPeriod(season)
+			if query.Country != "" {
+				p.Country = strings.ToUpper(query.Country)
+				if err := validateCountry(p.Country); err != nil {
+					return nil, err
+				}
+			}
+			e.walk = entryRows(board, p)
+		} else {
+			if query.Country != "" {
+				return nil, invalid("the standings of a closed season cannot be filtered by country")
+			}
+			e.walk = standingRows(season)
+		}
+		return e, nil
+	}
+
+	p, err := queryPeriod(config.DB, board, query.LeaderboardQuery)
+	if err != nil {
+		return nil, err
+	}
+	e.name = fmt.Sprintf("leaderboard-%d-%s", board.ID, p.Window)
+	if p.Window != WindowAllTime {
+		e.name += "-" + p.Start.Format("2006-01-02")
+	}
+	if p.Country != "" {
+		e.name += "-" + strings.ToLower(p.Country)
+	}
+	e.walk = entryRows(board, p)
+	return e, nil
+}
+
+func (e *export) ContentType() string {
+	if e.format == ExportNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+func (e *export) Filename() string {
+	return e.name + "." + e.format
+}
+
+// Write streams the rows, the whole export reads from one transaction so it sees a single
+// state of the board however long it takes
+func (e *export) Write(w io.Writer) error {
+	var cw *csv.Writer
+	if e.format == ExportCSV {
+		cw = csv.NewWriter(w)
+		if err := cw.Write(e.columns); err != nil {
+			return err
+		}
+	}
+	enc := json.NewEncoder(w)
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		return e.walk(tx, func(rows []exportRow) error {
+			details, err := exportDetails(tx, rows)
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				if cw != nil {
+					err = cw.Write(e.record(row, details[row.entry.UserId]))
+				} else {
+					err = enc.Encode(e.object(row, details[row.entry.UserId]))
+				}
+				if err != nil {
+					return err
+				}
+			}
+			if cw != nil {
+				cw.Flush()
+				if err := cw.Error(); err != nil {
+					return err
+				}
+			}
+			if f, ok := w.(flusher); ok {
+				f.Flush()
+			}
+			return nil
+		})
+	})
+}
+
+// record is a CSV row, text cells a spreadsheet would read as a formula are quoted with '
+func (e *export) record(row exportRow, details model.User_Details) []string {
+	record := make([]string, 0, len(e.columns))
+	for _, column := range e.columns {
+		switch value := e.value(column, row, details).(type) {
+		case string:
+			if value != "" && strings.ContainsAny(value[:1], "=+-@\t\r") {
+				value = "'" + value
+			}
+			record = append(record, value)
+		case float64:
+			record = append(record, strconv.FormatFloat(value, 'f', -1, 64))
+		default:
+			record = append(record, fmt.Sprint(value))
+		}
+	}
+	return record
+}
+
+func (e *export) object(row exportRow, details model.User_Details) map[string]interface{} {
+	object := make(map[string]interface{}, len(e.columns))
+	for _, column := range e.columns {
+		object[column] = e.value(column, row, details)
+	}
+	return object
+}
+
+func (e *export) value(column string, row exportRow, details model.User_Details) interface{} {
+	switch column {
+	case "rank":
+		return row.rank
+	case "user_id":
+		return row.entry.UserId
+	case "username":
+		return details.Username
+	case "score":
+		return row.entry.Score
+	case "formatted_score":
+		return utils.FormatScore(row.entry.Score, e.board.Format, e.board.Precision)
+	case "timestamp":
+		return row.entry.SubmittedAt.UTC().Format(time.RFC3339)
+	case "country":
+		if row.entry.Country != "" {
+			return row.entry.Country
+		}
+		return details.Country
+	case "submissions":
+		return row.entry.Submissions
+	}
+	return nil
+}
+
+// entryRows walks the entries of a board period, ranked under the board's tie-break policy
+func entryRows(board model.Leaderboard, p period) func(tx *gorm.DB, fn func(rows []exportRow) error) error {
+	return func(tx *gorm.DB, fn func(rows []exportRow) error) error {
+		counter := tieCounter{board: board}
+		return rankedBatches(tx, board, p, exportBatch, func(entries []model.LeaderboardEntry) error {
+			rows := make([]exportRow, 0, len(entries))
+			for _, entry := range entries {
+				rows = append(rows, exportRow{rank: counter.next(entry.Score), entry: entry})
+			}
+			return fn(rows)
+		})
+	}
+}
+
+// standingRows walks the frozen standings of a closed season
+func standingRows(season model.Season) func(tx *gorm.DB, fn func(rows []exportRow) error) error {
+	return func(tx *gorm.DB, fn func(rows []exportRow) error) error {
+		var last *model.SeasonStanding
+		for {
+			query := tx.Where("season_id = ?", season.ID)
+			if last != nil {
+				query = query.Where("position > ? OR (position = ? AND id > ?)", last.Position, last.Position, last.ID)
+			}
+			var standings []model.SeasonStanding
+			if err := query.Order("position, id").Limit(exportBatch).Find(&standings).Error; err != nil {
+				return err
+			}
+			if len(standings) == 0 {
+				return nil
+			}
+			rows := make([]exportRow, 0, len(standings))
+			for _, standing := range standings {
+				rows = append(rows, exportRow{rank: standing.Position, entry: model.LeaderboardEntry{
+					UserId:      standing.UserId,
+					Score:       standing.Score,
+					SubmittedAt: standing.SubmittedAt,
+				}})
+			}
+			if err := fn(rows); err != nil {
+				return err
+			}
+			if len(standings) < exportBatch {
+				return nil
+			}
+			last = &standings[len(standings)-1]
+		}
+	}
+}
+
+// exportDetails loads the details of the players of a batch by user id
+func exportDetails(tx *gorm.DB, rows []exportRow) (map[string]model.User_Details, error) {
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.entry.UserId)
+	}
+	var details []model.User_Details
+	if err := tx.Select("user_id", "username", "country").Where("user_id IN ?", ids).Find(&details).Error; err != nil {
+		return nil, err
+	}
+	byUser := make(map[string]model.User_Details, len(details))
+	for _, d := range details {
+		byUser[d.UserId] = d
+	}
+	return byUser, nil
+}
+
+// exportColumns reads a comma separated list of columns, empty picks the defaults
+func exportColumns(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return defaultExportColumns, nil
+	}
+	var columns []string
+	seen := map[string]bool{}
+	for _, column := range strings.Split(list, ",") {
+		column = strings.ToLower(strings.TrimSpace(column))
+		if !slices.Contains(ExportColumns, column) {
+			return nil, invalidf("unknown column %q, columns are %s", column, strings.Join(ExportColumns, ", "))
+		}
+		if !seen[column] {
+			seen[column] = true
+			columns = append(columns, column)
+		}
+	}
+	return columns, nil
+}
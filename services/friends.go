@@ -0,0 +1,307 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NotificationFriendRequest is the kind of the notifications sent with a friend request
+const NotificationFriendRequest = "friend_request"
+
+// MaxFriends caps the friends of a player, it keeps the friends view of a board a small read
+const MaxFriends = 1000
+
+var (
+	// ErrBlocked is returned when either player blocked the other one
+	ErrBlocked = errors.New("the player cannot be added as a friend")
+	// ErrAlreadyFriends is returned when a request is sent to a friend
+	ErrAlreadyFriends = errors.New("the players are already friends")
+)
+
+// FriendService is an interface for the friend graph and the friends view of the leaderboards
+type FriendService interface {
+	SendRequest(userId string, friendId string) (entity.FriendRequest, error)
+	Respond(requestId uint, actorId string, accept bool) (entity.FriendRequest, error)
+	ListRequests(userId string) ([]entity.FriendRequest, error)
+	ListFriends(userId string) ([]entity.Friend, error)
+	MutualFriends(userId string, otherId string) ([]entity.Friend, error)
+	RemoveFriend(userId string, friendId string) error
+	Block(userId string, blockedId string) error
+	Unblock(userId string, blockedId string) error
+	ListBlocked(userId string) ([]entity.Blocked, error)
+	FriendEntries(query entity.LeaderboardQuery, userId string) ([]entity.LeaderboardEntry, error)
+}
+
+// friendservice is an implementation of FriendService
+type friendservice struct{}
+
+// NewFriendService creates and returns a new instance of FriendService
+func NewFriendService() FriendService {
+	return &friendservice{}
+}
+
+// SendRequest asks a player to be friends. When they already asked the sender the two become friends
+// straight away.
+func (s *friendservice) SendRequest(userId string, friendId string) (entity.FriendRequest, error) {
+	if userId == friendId {
+		return entity.FriendRequest{}, invalid("players cannot befriend themselves")
+	}
+	if err := config.DB.Where("user_id = ?", friendId).First(&model.User{}).Error; err != nil {
+		return entity.FriendRequest{}, err
+	}
+
+	var req model.FriendRequest
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkBefriend(tx, userId, friendId); err != nil {
+			return err
+		}
+
+		var pending []model.FriendRequest
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("((from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)) AND status = ?", userId, friendId, friendId, userId, RequestPending).
+			Find(&pending)
+		if result.Error != nil {
+			return result.Error
+		}
+		for _, p := range pending {
+			if p.FromId == userId {
+				return invalid("a friend request to the player is already pending")
+			}
+		}
+		if len(pending) > 0 {
+			req = pending[0]
+			return acceptFriend(tx, &req)
+		}
+
+		req = model.FriendRequest{FromId: userId, ToId: friendId, Status: RequestPending}
+		if err := tx.Create(&req).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.Notification{
+			UserId:  friendId,
+			Kind:    NotificationFriendRequest,
+			Message: fmt.Sprintf("%s sent you a friend request", userId),
+		}).Error
+	})
+	if err != nil {
+		return entity.FriendRequest{}, err
+	}
+	return toFriendRequestEntity(req), nil
+}
+
+// Respond accepts or declines a friend request, only the player it was sent to can answer
+func (s *friendservice) Respond(requestId uint, actorId string, accept bool) (entity.FriendRequest, error) {
+	var req model.FriendRequest
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&req, requestId).Error; err != nil {
+			return err
+		}
+		if req.ToId != actorId {
+			return gorm.ErrRecordNotFound
+		}
+		if req.Status != RequestPending {
+			return invalid("the request was already answered")
+		}
+		if !accept {
+			req.Status = RequestDeclined
+			return tx.Save(&req).Error
+		}
+		if err := checkBefriend(tx, req.ToId, req.FromId); err != nil {
+			return err
+		}
+		return acceptFriend(tx, &req)
+	})
+	if err != nil {
+		return entity.FriendRequest{}, err
+	}
+	return toFriendRequestEntity(req), nil
+}
+
+// ListRequests returns the pending friend requests sent by or to a player
+func (s *friendservice) ListRequests(userId string) ([]entity.FriendRequest, error) {
+	var requests []model.FriendRequest
+	result := config.DB.Where("(from_id = ? OR to_id = ?) AND status = ?", userId, userId, RequestPending).
+		Order("id").
+		Find(&requests)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	list := make([]entity.FriendRequest, 0, len(requests))
+	for _, req := range requests {
+		list = append(list, toFriendRequestEntity(req))
+	}
+	return list, nil
+}
+
+// ListFriends returns the friends of a player, oldest friendship first
+func (s *friendservice) ListFriends(userId string) ([]entity.Friend, error) {
+	return findFriends(config.DB.Where("user_id = ?", userId))
+}
+
+// MutualFriends returns the friends two players have in common
+func (s *friendservice) MutualFriends(userId string, otherId string) ([]entity.Friend, error) {
+	return findFriends(config.DB.
+		Where("user_id = ?", userId).
+		Where("friend_id IN (?)", config.DB.Model(&model.Friend{}).Select("friend_id").Where("user_id = ?", otherId)))
+}
+
+// RemoveFriend ends a friendship for both players
+func (s *friendservice) RemoveFriend(userId string, friendId string) error {
+	result := unfriend(config.DB, userId, friendId)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Block stops a player from sending friend requests, it ends their friendship and drops the
+// pending requests between the two
+func (s *friendservice) Block(userId string, blockedId string) error {
+	if userId == blockedId {
+		return invalid("players cannot block themselves")
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		block := model.Block{UserId: userId, BlockedId: blockedId}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&block).Error; err != nil {
+			return err
+		}
+		if err := unfriend(tx, userId, blockedId).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.FriendRequest{}).
+			Where("((from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)) AND status = ?", userId, blockedId, blockedId, userId, RequestPending).
+			Update("status", RequestDeclined).Error
+	})
+}
+
+// Unblock lifts a block, the friendship it ended is not restored
+func (s *friendservice) Unblock(userId string, blockedId string) error {
+	result := config.DB.Where("user_id = ? AND blocked_id = ?", userId, blockedId).Delete(&model.Block{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListBlocked returns the players a player blocked
+func (s *friendservice) ListBlocked(userId string) ([]entity.Blocked, error) {
+	var blocks []model.Block
+	if err := config.DB.Where("user_id = ?", userId).Order("id").Find(&blocks).Error; err != nil {
+		return nil, err
+	}
+	list := make([]entity.Blocked, 0, len(blocks))
+	for _, b := range blocks {
+		list = append(list, entity.Blocked{UserId: b.BlockedId, BlockedAt: b.CreatedAt})
+	}
+	return list, nil
+}
+
+// FriendEntries ranks a player and their friends on a board period, the ranks only count the players
+// of that set and follow the board's tie-break policy. The player's own entry shows even when it is
+// hidden, placed among their friends as if it was on the board.
+func (s *friendservice) FriendEntries(query entity.LeaderboardQuery, userId string) ([]entity.LeaderboardEntry, error) {
+	board, p, err := resolveQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	var entries []model.LeaderboardEntry
+	result := periodScope(config.DB, board, p).
+		Where("user_id = ? OR (hidden = ? AND user_id IN (?))", userId, false, config.DB.Model(&model.Friend{}).Select("friend_id").Where("user_id = ?", userId)).
+		Order(rankOrder(board)).
+		Limit(query.Limit).
+		Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	counter := tieCounter{board: board}
+	list := make([]entity.LeaderboardEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, toEntryEntity(board, entry, counter.next(entry.Score)))
+	}
+	return list, nil
+}
+
+// checkBefriend checks two players can become friends
+func checkBefriend(tx *gorm.DB, userId string, friendId string) error {
+	var blocks int64
+	result := tx.Model(&model.Block{}).
+		Where("(user_id = ? AND blocked_id = ?) OR (user_id = ? AND blocked_id = ?)", userId, friendId, friendId, userId).
+		Count(&blocks)
+	if result.Error != nil {
+		return result.Error
+	}
+	if blocks > 0 {
+		return ErrBlocked
+	}
+
+	var friends int64
+	if err := tx.Model(&model.Friend{}).Where("user_id = ? AND friend_id = ?", userId, friendId).Count(&friends).Error; err != nil {
+		return err
+	}
+	if friends > 0 {
+		return ErrAlreadyFriends
+	}
+	for _, id := range []string{userId, friendId} {
+		if err := tx.Model(&model.Friend{}).Where("user_id = ?", id).Count(&friends).Error; err != nil {
+			return err
+		}
+		if friends >= MaxFriends {
+			return invalidf("a player can have at most %d friends", MaxFriends)
+		}
+	}
+	return nil
+}
+
+// acceptFriend accepts a request and records the friendship on both sides
+func acceptFriend(tx *gorm.DB, req *model.FriendRequest) error {
+	req.Status = RequestAccepted
+	if err := tx.Save(req).Error; err != nil {
+		return err
+	}
+	friends := []model.Friend{
+		{UserId: req.FromId, FriendId: req.ToId},
+		{UserId: req.ToId, FriendId: req.FromId},
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&friends).Error
+}
+
+// unfriend deletes both sides of a friendship
+func unfriend(db *gorm.DB, userId string, friendId string) *gorm.DB {
+	return db.Where("(user_id = ? AND friend_id = ?) OR (user_id = ? AND friend_id = ?)", userId, friendId, friendId, userId).
+		Delete(&model.Friend{})
+}
+
+func findFriends(db *gorm.DB) ([]entity.Friend, error) {
+	var friends []model.Friend
+	if err := db.Order("id").Find(&friends).Error; err != nil {
+		return nil, err
+	}
+	list := make([]entity.Friend, 0, len(friends))
+	for _, f := range friends {
+		list = append(list, entity.Friend{UserId: f.FriendId, Since: f.CreatedAt})
+	}
+	return list, nil
+}
+
+func toFriendRequestEntity(req model.FriendRequest) entity.FriendRequest {
+	return entity.FriendRequest{
+		ID:        req.ID,
+		FromId:    req.FromId,
+		ToId:      req.ToId,
+		Status:    req.Status,
+		CreatedAt: req.CreatedAt,
+	}
+}
@@ -0,0 +1,49 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+func TestFriendEntriesShowHiddenCaller(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b", "c")
+	board, err := NewLeaderboardService().CreateLeaderboard(entity.Leaderboard{Name: "friends", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit(t, board.ID, "a", 10)
+	submit(t, board.ID, "b", 20)
+	submit(t, board.ID, "c", 30)
+	friends := []model.Friend{{UserId: "a", FriendId: "b"}, {UserId: "b", FriendId: "a"}}
+	if err := config.DB.Create(&friends).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewModerationService().Ban("mod", entity.NewBan{UserId: "a", Reason: "cheating", Shadow: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	view := func(userId string) string {
+		t.Helper()
+		entries, err := NewFriendService().FriendEntries(entity.LeaderboardQuery{LeaderboardId: board.ID, Limit: 10}, userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, entry := range entries {
+			got = append(got, fmt.Sprintf("%s#%d", entry.UserId, entry.Rank))
+		}
+		return fmt.Sprint(got)
+	}
+	// the shadow banned player still sees themselves, their friends no longer do
+	if got := view("a"); got != "[b#1 a#2]" {
+		t.Errorf("shadow banned player's view: got %s, want [b#1 a#2]", got)
+	}
+	if got := view("b"); got != "[b#1]" {
+		t.Errorf("friend's view: got %s, want [b#1]", got)
+	}
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultHistoryDays is how long submissions are kept when a board does not say
+	defaultHistoryDays = 90
+	// maxHistoryDays caps the retention so history cannot grow without bound
+	maxHistoryDays = 3650
+	// maxMetadataSize is the largest metadata object a submission may carry
+	maxMetadataSize = 2048
+)
+
+// countedStatuses are the submission states whose scores reached the board
+var countedStatuses = []string{SubmissionAccepted, SubmissionApproved}
+
+// HistoryService is an interface for the submission history of players
+type HistoryService interface {
+	History(query entity.HistoryQuery) (entity.SubmissionPage, error)
+	PersonalBests(leaderboardId uint, userId string) ([]entity.Submission, error)
+	PruneHistory() error
+}
+
+// historyservice is an implementation of HistoryService
+type historyservice struct{}
+
+// NewHistoryService creates and returns a new instance of HistoryService
+func NewHistoryService() HistoryService {
+	return &historyservice{}
+}
+
+// History returns a page of a user's submissions to a board, newest first
+func (s *historyservice) History(query entity.HistoryQuery) (entity.SubmissionPage, error) {
+	if _, err := findLeaderboard(config.DB, query.LeaderboardId); err != nil {
+		return entity.SubmissionPage{}, err
+	}
+	pg, err := newPager(fmt.Sprintf("history:%d:%s:%t", query.LeaderboardId, query.UserId, query.All), query.Cursor, query.Limit)
+	if err != nil {
+		return entity.SubmissionPage{}, err
+	}
+	db := config.DB.Where("leaderboard_id = ? AND user_id = ?", query.LeaderboardId, query.UserId)
+	if !query.All {
+		db = db.Where("status IN ?", countedStatuses)
+	}
+	return submissionPage(pg, db, true)
+}
+
+// submissionPage reads a page of submissions ordered by id
+func submissionPage(pg pager, db *gorm.DB, desc bool) (entity.SubmissionPage, error) {
+	var subs []model.Submission
+	if err := pg.byId(db, desc).Find(&subs).Error; err != nil {
+		return entity.SubmissionPage{}, err
+	}
+	more := len(subs) > pg.limit
+	if more {
+		subs = subs[:pg.limit]
+	}
+	if pg.back() {
+		slices.Reverse(subs)
+	}
+
+	page := entity.SubmissionPage{Submissions: make([]entity.Submission, 0, len(subs))}
+	for _, sub := range subs {
+		page.Submissions = append(page.Submissions, toSubmissionEntity(sub))
+	}
+	if len(subs) > 0 {
+		page.Cursors = pg.cursors(more, idCursor(subs[0].ID), idCursor(subs[len(subs)-1].ID))
+	}
+	return page, nil
+}
+
+// PersonalBests returns the progression of a user's bests on a board, oldest first
+func (s *historyservice) PersonalBests(leaderboardId uint, userId string) ([]entity.Submission, error) {
+	if _, err := findLeaderboard(config.DB, leaderboardId); err != nil {
+		return nil, err
+	}
+	var subs []model.Submission
+	result := config.DB.Where("leaderboard_id = ? AND user_id = ? AND personal_best = ?", leaderboardId, userId, true).
+		Order("id").Find(&subs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	list := make([]entity.Submission, 0, len(subs))
+	for _, sub := range subs {
+		list = append(list, toSubmissionEntity(sub))
+	}
+	return list, nil
+}
+
+// PruneHistory deletes the submissions older than each board's retention. Personal bests stay so the
+// progression survives, and submissions still waiting for review are kept until a moderator acts.
+func (s *historyservice) PruneHistory() error {
+	var boards []model.Leaderboard
+	if err := config.DB.Select("id", "history_days").Find(&boards).Error; err != nil {
+		return err
+	}
+	for _, board := range boards {
+		cutoff := time.Now().AddDate(0, 0, -board.HistoryDays)
+		result := config.DB.Unscoped().
+			Where("leaderboard_id = ? AND created_at < ? AND personal_best = ? AND status NOT IN ?",
+				board.ID, cutoff, false, []string{SubmissionPending, SubmissionFlagged}).
+			Delete(&model.Submission{})
+		if result.Error != nil {
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// previousBest returns the best score of the user's counted submissions to a board, nil before the first
+func previousBest(tx *gorm.DB, board model.Leaderboard, userId string) (*float64, error) {
+	agg := "MAX(score)"
+	if board.SortOrder == SortAsc {
+		agg = "MIN(score)"
+	}
+	var best sql.NullFloat64
+	result := tx.Model(&model.Submission{}).
+		Where("leaderboard_id = ? AND user_id = ? AND status IN ?", board.ID, userId, countedStatuses).
+		Select(agg).Scan(&best)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if !best.Valid {
+		return nil, nil
+	}
+	return &best.Float64, nil
+}
+
+// markPersonalBest sets the personal best flag of a submission about to count, it returns the best it beat
+func markPersonalBest(tx *gorm.DB, board model.Leaderboard, sub *model.Submission) (*float64, error) {
+	best, err := previousBest(tx, board, sub.UserId)
+	if err != nil {
+		return nil, err
+	}
+	sub.PersonalBest = best == nil || isBetter(board, sub.Score, *best)
+	return best, nil
+}
+
+// validateMetadata checks the optional metadata of a submission is a small JSON object
+func validateMetadata(raw json.RawMessage) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+	if len(raw) > maxMetadataSize {
+		return invalid("metadata must be at most 2048 bytes")
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(raw, &object); err != nil {
+		return invalid("metadata must be a JSON object")
+	}
+	return nil
+}
+
+// validateHistoryDays checks the retention of a board
+func validateHistoryDays(days int) error {
+	if days < 1 || days > maxHistoryDays {
+		return invalid("history_days must be between 1 and 3650")
+	}
+	return nil
+}
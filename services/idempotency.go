@@ -0,0 +1,116 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm/clause"
+)
+
+// defaultIdempotencyTTL is how long a key is remembered when IDEMPOTENCY_TTL is not set
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// pendingKeyTTL is how long a key claimed by a request that never answered, one a crash cut short
+// say, holds back its retries. It must be longer than any request takes, an import included.
+const pendingKeyTTL = 10 * time.Minute
+
+var (
+	// ErrKeyReused is returned when a key comes back with a request other than the one it was first sent with
+	ErrKeyReused = errors.New("the Idempotency-Key was already used for a different request")
+	// ErrKeyInFlight is returned when a key comes back while its first request is still being handled
+	ErrKeyInFlight = errors.New("a request with the Idempotency-Key is still being processed")
+)
+
+// IdempotencyService is an interface for the responses kept under the Idempotency-Key of requests
+type IdempotencyService interface {
+	Begin(userId string, key string, hash string) (*entity.StoredResponse, error)
+	Complete(userId string, key string, response entity.StoredResponse) error
+	Release(userId string, key string) error
+	PruneKeys() error
+}
+
+// idempotencyservice is an implementation of IdempotencyService
+type idempotencyservice struct {
+	ttl time.Duration
+}
+
+// NewIdempotencyService creates and returns a new instance of IdempotencyService, the keys live for
+// the IDEMPOTENCY_TTL duration
+func NewIdempotencyService() IdempotencyService {
+	ttl := defaultIdempotencyTTL
+	if d, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_TTL")); err == nil && d > 0 {
+		ttl = d
+	}
+	return &idempotencyservice{ttl: ttl}
+}
+
+// Begin claims a key for a request. It returns the stored response when the request was answered
+// already, or nil when the caller must handle it and then Complete or Release the key.
+func (s *idempotencyservice) Begin(userId string, key string, hash string) (*entity.StoredResponse, error) {
+	// one pass claims a free key, a second one follows when an expired key was cleared
+	for i := 0; i < 2; i++ {
+		claim := model.IdempotencyKey{UserId: userId, Key: key, RequestHash: hash}
+		result := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&claim)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil, nil
+		}
+
+		var stored model.IdempotencyKey
+		if err := config.DB.Where("user_id = ? AND idempotency_key = ?", userId, key).First(&stored).Error; err != nil {
+			return nil, err
+		}
+		if s.expired(stored) {
+			if err := config.DB.Delete(&stored).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if stored.RequestHash != hash {
+			return nil, ErrKeyReused
+		}
+		if stored.Status == 0 {
+			return nil, ErrKeyInFlight
+		}
+		return &entity.StoredResponse{Status: stored.Status, ContentType: stored.ContentType, Body: stored.Body}, nil
+	}
+	return nil, ErrKeyInFlight
+}
+
+// expired reports whether a key is past its lifetime, a pending one lives for pendingKeyTTL
+func (s *idempotencyservice) expired(key model.IdempotencyKey) bool {
+	if key.Status == 0 {
+		return key.CreatedAt.Before(time.Now().Add(-pendingKeyTTL))
+	}
+	return key.CreatedAt.Before(time.Now().Add(-s.ttl))
+}
+
+// Complete stores the response to the request a key was claimed for
+func (s *idempotencyservice) Complete(userId string, key string, response entity.StoredResponse) error {
+	return config.DB.Model(&model.IdempotencyKey{}).
+		Where("user_id = ? AND idempotency_key = ?", userId, key).
+		Updates(map[string]interface{}{
+			"status":       response.Status,
+			"content_type": response.ContentType,
+			"body":         response.Body,
+		}).Error
+}
+
+// Release forgets a claimed key whose request failed, so a retry runs it again
+func (s *idempotencyservice) Release(userId string, key string) error {
+	return config.DB.Where("user_id = ? AND idempotency_key = ? AND status = ?", userId, key, 0).
+		Delete(&model.IdempotencyKey{}).Error
+}
+
+// PruneKeys forgets the keys past their lifetime
+func (s *idempotencyservice) PruneKeys() error {
+	now := time.Now()
+	return config.DB.Where("created_at < ? OR (status = ? AND created_at < ?)", now.Add(-s.ttl), 0, now.Add(-pendingKeyTTL)).
+		Delete(&model.IdempotencyKey{}).Error
+}
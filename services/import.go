@@ -0,0 +1,638 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// importBatch is how many rows are read, checked and written at a time
+	importBatch = 500
+	// maxImportErrors caps the row errors a report lists, the rest are only counted
+	maxImportErrors = 1000
+	// maxImportLine is the longest NDJSON line an import reads
+	maxImportLine = 64 * 1024
+)
+
+// errImportRollback ends the import transaction without an error once a dry run or a failed import is done
+var errImportRollback = errors.New("import rolled back")
+
+// importRow is a parsed row of an import, err is set when the row cannot be imported
+type importRow struct {
+	line   int
+	userId string
+	email  string
+	score  float64
+	metric float64
+	at     time.Time
+	err    error
+}
+
+// importReader reads the rows of an import one at a time, it returns io.EOF after the last one
+type importReader interface {
+	next() (importRow, error)
+}
+
+// Import loads historical scores into a board. Every row is checked like a live submission, bans and
+// board rules included, and written as if it was submitted at its timestamp, all in one transaction
+// so each row is checked against the rows before it. A dry run or an import with a failed row rolls
+// it back and only returns the report. Imported scores skip moderation, events and achievements.
+func (s *leaderboardservice) Import(leaderboardId uint, req entity.ImportRequest, body io.Reader) (entity.ImportReport, error) {
+	board, err := findLeaderboard(config.DB, leaderboardId)
+	if err != nil {
+		return entity.ImportReport{}, err
+	}
+	var reader importReader
+	switch strings.ToLower(req.Format) {
+	case "", ExportCSV:
+		if reader, err = newCSVImport(body); err != nil {
+			return entity.ImportReport{}, err
+		}
+	case ExportNDJSON:
+		reader = newNDJSONImport(body)
+	default:
+		return entity.ImportReport{}, invalid("format must be csv or ndjson")
+	}
+
+	report := entity.ImportReport{DryRun: req.DryRun, Errors: []entity.ImportError{}}
+	touched := map[string]period{}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		for {
+			batch, err := readImportBatch(reader)
+			if err != nil {
+				return err
+			}
+			if len(batch) == 0 {
+				break
+			}
+			if err := resolveImportUsers(tx, batch); err != nil {
+				return err
+			}
+			w, err := newImportWriter(tx, board, batch)
+			if err != nil {
+				return err
+			}
+			for _, row := range batch {
+				report.Rows++
+				if row.err == nil {
+					if row.err, err = w.check(*row); err != nil {
+						return err
+					}
+				}
+				if row.err != nil {
+					report.Failed++
+					if len(report.Errors) < maxImportErrors {
+						report.Errors = append(report.Errors, entity.ImportError{Row: row.line, Error: row.err.Error()})
+					}
+					continue
+				}
+				w.add(*row)
+				report.Imported++
+			}
+			// the valid rows are written even once a row failed, so the later ones are checked the
+			// same way a clean import would check them
+			if err := w.flush(); err != nil {
+				return err
+			}
+			for key, p := range w.periods {
+				touched[key] = p
+			}
+		}
+		if req.DryRun || report.Failed > 0 {
+			return errImportRollback
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRollback) {
+		return entity.ImportReport{}, err
+	}
+	report.Committed = err == nil
+	if report.Committed {
+		// the rank index reloads the periods the import wrote to on their next read
+		for _, p := range touched {
+			ranks.Drop(board, p)
+		}
+	}
+	return report, nil
+}
+
+// entryKey names the entry of a player in a board period
+type entryKey struct {
+	window string
+	start  time.Time
+	userId string
+}
+
+// teamPeriod is a team entry an import batch changes
+type teamPeriod struct {
+	teamId uint
+	period string
+}
+
+// importWriter checks and applies the rows of one import batch. It reads what the checks and writes
+// need for the whole batch up front, applies the rows in memory and writes them together on flush.
+type importWriter struct {
+	tx      *gorm.DB
+	board   model.Leaderboard
+	season  *period
+	bans    map[string]model.Ban
+	hidden  map[string]bool
+	country map[string]string
+	team    map[string]*uint
+	bests   map[string]float64
+	stats   scoreStats
+	entries map[entryKey]*model.LeaderboardEntry
+	// written lists the entries in the order the rows first touched them
+	written []*model.LeaderboardEntry
+	queued  map[entryKey]bool
+	// sent is when each player's rows of the batch were sent, for the rate rule
+	sent    map[string][]time.Time
+	subs    []model.Submission
+	periods map[string]period
+	teams   map[teamPeriod]period
+}
+
+func newImportWriter(tx *gorm.DB, board model.Leaderboard, batch []*importRow) (*importWriter, error) {
+	w := &importWriter{
+		tx:      tx,
+		board:   board,
+		bans:    map[string]model.Ban{},
+		hidden:  map[string]bool{},
+		country: map[string]string{},
+		team:    map[string]*uint{},
+		bests:   map[string]float64{},
+		entries: map[entryKey]*model.LeaderboardEntry{},
+		queued:  map[entryKey]bool{},
+		sent:    map[string][]time.Time{},
+		periods: map[string]period{},
+		teams:   map[teamPeriod]period{},
+	}
+	if p, ok, err := openSeasonPeriod(tx, board.ID); err != nil {
+		return nil, err
+	} else if ok {
+		w.season = &p
+	}
+
+	var userIds []string
+	starts := map[time.Time]bool{}
+	for _, row := range batch {
+		if row.err != nil {
+			continue
+		}
+		if row.err = checkImportRow(*row); row.err != nil {
+			continue
+		}
+		userIds = append(userIds, row.userId)
+		periods, err := w.periodsAt(row.at)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range periods {
+			starts[p.Start] = true
+		}
+	}
+	if len(userIds) == 0 {
+		return w, nil
+	}
+
+	// bans and shadow bans in force now, as a live submission would meet them
+	var bans []model.Ban
+	result := tx.Where("user_id IN ? AND lifted_at IS NULL", userIds).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Where("leaderboard_id IS NULL OR leaderboard_id = ?", board.ID).
+		Order("id").
+		Find(&bans)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, ban := range bans {
+		if ban.Shadow {
+			w.hidden[ban.UserId] = true
+		} else if _, ok := w.bans[ban.UserId]; !ok {
+			w.bans[ban.UserId] = ban
+		}
+	}
+
+	var details []model.User_Details
+	if err := tx.Select("user_id", "country").Where("user_id IN ?", userIds).Find(&details).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range details {
+		w.country[d.UserId] = d.Country
+	}
+	var members []model.TeamMember
+	if err := tx.Where("user_id IN ?", userIds).Find(&members).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range members {
+		teamId := m.TeamId
+		w.team[m.UserId] = &teamId
+	}
+
+	agg := "MAX(score)"
+	if board.SortOrder == SortAsc {
+		agg = "MIN(score)"
+	}
+	var bests []struct {
+		UserId string
+		Best   float64
+	}
+	result = tx.Model(&model.Submission{}).
+		Select("user_id, "+agg+" AS best").
+		Where("leaderboard_id = ? AND user_id IN ? AND status IN ?", board.ID, userIds, countedStatuses).
+		Group("user_id").
+		Scan(&bests)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, b := range bests {
+		w.bests[b.UserId] = b.Best
+	}
+
+	startList := make([]time.Time, 0, len(starts))
+	for start := range starts {
+		startList = append(startList, start)
+	}
+	var entries []model.LeaderboardEntry
+	result = tx.Where("leaderboard_id = ? AND user_id IN ? AND period_start IN ?", board.ID, userIds, startList).Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for i := range entries {
+		entry := &entries[i]
+		w.entries[entryKey{window: entry.Window, start: entry.PeriodStart.UTC(), userId: entry.UserId}] = entry
+	}
+
+	if board.MaxZScore > 0 {
+		var err error
+		if w.stats, err = allTimeStats(tx, board); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// periodsAt returns the periods a score sent at t counts toward, a running season only takes scores
+// sent since it started
+func (w *importWriter) periodsAt(t time.Time) ([]period, error) {
+	periods, err := currentPeriods(w.board, t)
+	if err != nil {
+		return nil, err
+	}
+	if w.season != nil && !t.Before(w.season.Start) {
+		periods = append(periods, *w.season)
+	}
+	return periods, nil
+}
+
+// check returns why a row cannot be imported, err is set when the checks themselves failed
+func (w *importWriter) check(row importRow) (rowErr error, err error) {
+	if ban, ok := w.bans[row.userId]; ok {
+		return &BannedError{Reason: ban.Reason, ExpiresAt: ban.ExpiresAt}, nil
+	}
+
+	var best *float64
+	if entry, ok := w.entries[entryKey{window: WindowAllTime, start: allTimeStart, userId: row.userId}]; ok {
+		best = &entry.Score
+	}
+	recent := 0
+	if w.board.MaxPerMinute > 0 {
+		if recent, err = recentSubmissions(w.tx, w.board, row.userId, row.at); err != nil {
+			return nil, err
+		}
+		for _, at := range w.sent[row.userId] {
+			if at.After(row.at.Add(-time.Minute)) && !at.After(row.at) {
+				recent++
+			}
+		}
+	}
+	if flags := plausibilityFlags(w.board, row.score, best, recent, w.stats); len(flags) > 0 {
+		return fmt.Errorf("the score breaks the board rules: %s", strings.Join(flags, ", ")), nil
+	}
+	return nil, nil
+}
+
+// add records a checked row as an accepted submission and applies it to the periods open at its time
+func (w *importWriter) add(row importRow) {
+	sub := model.Submission{
+		LeaderboardId: w.board.ID,
+		UserId:        row.userId,
+		Score:         row.score,
+		Metric:        row.metric,
+		Status:        SubmissionAccepted,
+		TeamId:        w.team[row.userId],
+	}
+	sub.CreatedAt = row.at
+	best, ok := w.bests[row.userId]
+	sub.PersonalBest = !ok || isBetter(w.board, row.score, best)
+	if sub.PersonalBest {
+		w.bests[row.userId] = row.score
+	}
+	w.subs = append(w.subs, sub)
+	w.sent[row.userId] = append(w.sent[row.userId], row.at)
+
+	// the periods were worked out when the writer was made, so this cannot fail
+	periods, _ := w.periodsAt(row.at)
+	for _, p := range periods {
+		key := entryKey{window: p.Window, start: p.Start, userId: row.userId}
+		entry, ok := w.entries[key]
+		if !ok {
+			entry = &model.LeaderboardEntry{LeaderboardId: w.board.ID, Window: p.Window, PeriodStart: p.Start, UserId: row.userId}
+			w.entries[key] = entry
+		}
+		if !w.queued[key] {
+			w.queued[key] = true
+			w.written = append(w.written, entry)
+		}
+		applyScore(w.board, entry, row.score, row.metric, row.at)
+		entry.Country = w.country[row.userId]
+		entry.Hidden = w.hidden[row.userId]
+
+		pk := periodKey(w.board, p)
+		w.periods[pk] = p
+		if team := w.team[row.userId]; team != nil {
+			w.teams[teamPeriod{teamId: *team, period: pk}] = p
+		}
+	}
+}
+
+// flush writes what the rows of the batch added
+func (w *importWriter) flush() error {
+	if len(w.subs) == 0 {
+		return nil
+	}
+	for _, p := range w.periods {
+		if err := openPeriod(w.tx, w.board, p); err != nil {
+			return err
+		}
+	}
+	if err := w.tx.CreateInBatches(&w.subs, importBatch).Error; err != nil {
+		return err
+	}
+
+	// new and known entries go in one upsert on the entry's unique key
+	now := time.Now()
+	rows := make([]model.LeaderboardEntry, 0, len(w.written))
+	for _, entry := range w.written {
+		row := *entry
+		row.Model = gorm.Model{UpdatedAt: now}
+		rows = append(rows, row)
+	}
+	result := w.tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "leaderboard_id"}, {Name: "time_window"}, {Name: "period_start"}, {Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"country", "score", "metric", "submissions", "submitted_at", "hidden", "updated_at", "deleted_at"}),
+	}).CreateInBatches(&rows, importBatch)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if len(w.teams) == 0 {
+		return nil
+	}
+	var teamBoards []model.TeamBoard
+	if err := w.tx.Where("leaderboard_id = ?", w.board.ID).Find(&teamBoards).Error; err != nil {
+		return err
+	}
+	for _, teamBoard := range teamBoards {
+		for tp, p := range w.teams {
+			if err := updateTeamEntry(w.tx, teamBoard, w.board, p, tp.teamId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkImportRow checks the values of a row that do not need the board
+func checkImportRow(row importRow) error {
+	if math.IsNaN(row.score) || math.IsInf(row.score, 0) || math.IsNaN(row.metric) || math.IsInf(row.metric, 0) {
+		return errors.New("score and metric must be finite numbers")
+	}
+	if row.at.After(time.Now()) {
+		return errors.New("timestamp is in the future")
+	}
+	return nil
+}
+
+// readImportBatch reads the next rows of an import, an empty batch means the input is done
+func readImportBatch(reader importReader) ([]*importRow, error) {
+	batch := make([]*importRow, 0, importBatch)
+	for len(batch) < importBatch {
+		row, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		batch = append(batch, &row)
+	}
+	return batch, nil
+}
+
+// resolveImportUsers matches the rows of a batch to users by user id or email
+func resolveImportUsers(tx *gorm.DB, batch []*importRow) error {
+	var ids, emails []string
+	for _, row := range batch {
+		if row.err != nil {
+			continue
+		}
+		if row.userId != "" {
+			ids = append(ids, row.userId)
+		} else {
+			emails = append(emails, row.email)
+		}
+	}
+
+	var users []model.User
+	if len(ids) > 0 || len(emails) > 0 {
+		query := tx.Select("user_id", "email")
+		if len(ids) > 0 && len(emails) > 0 {
+			query = query.Where("user_id IN ? OR email IN ?", ids, emails)
+		} else if len(ids) > 0 {
+			query = query.Where("user_id IN ?", ids)
+		} else {
+			query = query.Where("email IN ?", emails)
+		}
+		if err := query.Find(&users).Error; err != nil {
+			return err
+		}
+	}
+	byId := make(map[string]bool, len(users))
+	byEmail := make(map[string]string, len(users))
+	for _, u := range users {
+		byId[u.UserId] = true
+		byEmail[strings.ToLower(u.Email)] = u.UserId
+	}
+
+	for _, row := range batch {
+		if row.err != nil {
+			continue
+		}
+		if row.userId != "" {
+			if !byId[row.userId] {
+				row.err = fmt.Errorf("no user with id %q", row.userId)
+			}
+			continue
+		}
+		if id, ok := byEmail[strings.ToLower(row.email)]; ok {
+			row.userId = id
+		} else {
+			row.err = fmt.Errorf("no user with email %q", row.email)
+		}
+	}
+	return nil
+}
+
+// importFields fills a row from its raw values, the errors are kept on the row
+func importFields(row *importRow, userId, email, score, metric, timestamp string) {
+	row.userId = strings.TrimSpace(userId)
+	row.email = strings.TrimSpace(email)
+	if row.userId == "" && row.email == "" {
+		row.err = errors.New("user_id or email is required")
+		return
+	}
+	var err error
+	if row.score, err = strconv.ParseFloat(strings.TrimSpace(score), 64); err != nil {
+		row.err = errors.New("score must be a number")
+		return
+	}
+	if strings.TrimSpace(metric) != "" {
+		if row.metric, err = strconv.ParseFloat(strings.TrimSpace(metric), 64); err != nil {
+			row.err = errors.New("metric must be a number")
+			return
+		}
+	}
+	if row.at, err = parseImportTime(timestamp); err != nil {
+		row.err = err
+	}
+}
+
+// parseImportTime reads a timestamp in unix seconds or RFC 3339, empty is now
+func parseImportTime(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Now().UTC().Truncate(time.Millisecond), nil
+	}
+	if seconds, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+	at, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, errors.New("timestamp must be unix seconds or RFC 3339")
+	}
+	return at.UTC().Truncate(time.Millisecond), nil
+}
+
+// csvImport reads a CSV import, the first line names the columns
+type csvImport struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVImport(body io.Reader) (*csvImport, error) {
+	reader := csv.NewReader(body)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, invalid("the CSV must start with a header line")
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	_, hasId := columns["user_id"]
+	_, hasEmail := columns["email"]
+	if _, ok := columns["score"]; !ok || (!hasId && !hasEmail) {
+		return nil, invalid("the CSV needs a score column and a user_id or email column")
+	}
+	return &csvImport{reader: reader, columns: columns}, nil
+}
+
+func (r *csvImport) next() (importRow, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return importRow{line: parseErr.StartLine, err: errors.New("the line is not valid CSV")}, nil
+		}
+		return importRow{}, err
+	}
+	line, _ := r.reader.FieldPos(0)
+	field := func(name string) string {
+		if i, ok := r.columns[name]; ok && i < len(record) {
+			return record[i]
+		}
+		return ""
+	}
+	row := importRow{line: line}
+	importFields(&row, field("user_id"), field("email"), field("score"), field("metric"), field("timestamp"))
+	return row, nil
+}
+
+// ndjsonImport reads an import with one JSON object per line
+type ndjsonImport struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newNDJSONImport(body io.Reader) *ndjsonImport {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 4096), maxImportLine)
+	return &ndjsonImport{scanner: scanner}
+}
+
+func (r *ndjsonImport) next() (importRow, error) {
+	for r.scanner.Scan() {
+		r.line++
+		text := strings.TrimSpace(r.scanner.Text())
+		if text == "" {
+			continue
+		}
+		var object struct {
+			UserId    string      `json:"user_id"`
+			Email     string      `json:"email"`
+			Score     json.Number `json:"score"`
+			Metric    json.Number `json:"metric"`
+			Timestamp interface{} `json:"timestamp"`
+		}
+		row := importRow{line: r.line}
+		decoder := json.NewDecoder(strings.NewReader(text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil {
+			row.err = errors.New("the line is not a valid JSON object")
+			return row, nil
+		}
+		timestamp := ""
+		switch t := object.Timestamp.(type) {
+		case json.Number:
+			timestamp = t.String()
+		case string:
+			timestamp = t
+		}
+		importFields(&row, object.UserId, object.Email, object.Score.String(), object.Metric.String(), timestamp)
+		return row, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return importRow{}, invalidf("line %d is longer than %d bytes", r.line+1, maxImportLine)
+		}
+		return importRow{}, err
+	}
+	return importRow{}, io.EOF
+}
@@ -0,0 +1,90 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+func TestImport(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b", "c")
+	boards := NewLeaderboardService()
+	board, err := boards.CreateLeaderboard(entity.Leaderboard{Name: "import", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	max := 100.0
+	if _, err := boards.UpdateRules(board.ID, entity.PlausibilityRules{MaxScore: &max}); err != nil {
+		t.Fatal(err)
+	}
+
+	load := func(format, body string, dryRun bool) entity.ImportReport {
+		t.Helper()
+		report, err := boards.Import(board.ID, entity.ImportRequest{Format: format, DryRun: dryRun}, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+	written := func() int64 {
+		t.Helper()
+		var n int64
+		if err := config.DB.Model(&model.Submission{}).Where("leaderboard_id = ?", board.ID).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	standings := func() string {
+		t.Helper()
+		page, err := boards.TopEntries(entity.LeaderboardQuery{LeaderboardId: board.ID, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, entry := range page.Entries {
+			got = append(got, fmt.Sprintf("%s=%g", entry.UserId, entry.Score))
+		}
+		return strings.Join(got, " ")
+	}
+
+	// every failed row is reported with its line and nothing is written
+	report := load("csv", "user_id,email,score,timestamp\na,,10,1700000000\n,nobody@example.com,20,\nb,,abc,\nc,,500,\n", false)
+	if report.Committed || report.Rows != 4 || report.Imported != 1 || report.Failed != 3 {
+		t.Errorf("failed import: got %+v", report)
+	}
+	var lines []int
+	for _, e := range report.Errors {
+		lines = append(lines, e.Row)
+	}
+	if fmt.Sprint(lines) != "[3 4 5]" {
+		t.Errorf("failed rows: got %v, want [3 4 5]", report.Errors)
+	}
+	if n := written(); n != 0 {
+		t.Errorf("a failed import wrote %d submissions", n)
+	}
+
+	// a dry run checks the rows and rolls them back
+	report = load("csv", "email,score\na@example.com,10\n", true)
+	if report.Committed || !report.DryRun || report.Imported != 1 || report.Failed != 0 {
+		t.Errorf("dry run: got %+v", report)
+	}
+	if n := written(); n != 0 {
+		t.Errorf("a dry run wrote %d submissions", n)
+	}
+
+	report = load("ndjson", `{"user_id": "a", "score": 10, "timestamp": 1700000000}`+"\n\n"+`{"email": "b@example.com", "score": 30}`+"\n"+`{"user_id": "a", "score": 40}`+"\n", false)
+	if !report.Committed || report.Rows != 3 || report.Imported != 3 || len(report.Errors) != 0 {
+		t.Errorf("clean import: got %+v", report)
+	}
+	if n := written(); n != 3 {
+		t.Errorf("got %d submissions written, want 3", n)
+	}
+	if got := standings(); got != "a=40 b=30" {
+		t.Errorf("standings after the import: got %s, want a=40 b=30", got)
+	}
+}
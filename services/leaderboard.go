@@ -0,0 +1,943 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Sort directions of a leaderboard
+const (
+	SortDesc = "desc"
+	SortAsc  = "asc"
+)
+
+// Aggregation modes, they decide how a new submission changes an entry
+const (
+	AggregateBest   = "best"
+	AggregateLatest = "latest"
+	AggregateSum    = "sum"
+	AggregateCount  = "count"
+)
+
+// Tie-break policies, they decide the order and ranks of equal scores
+const (
+	// TieEarliest ranks the earliest submission first
+	TieEarliest = "earliest"
+	// TieMetric ranks by a secondary metric sent with the score, such as the time taken
+	TieMetric = "metric"
+	// TieCompetition shares the rank and skips the ranks after it, 1 1 3
+	TieCompetition = "competition"
+	// TieDense shares the rank without skipping, 1 1 2
+	TieDense = "dense"
+)
+
+// LeaderboardService is an interface for leaderboard and score services
+type LeaderboardService interface {
+	CreateLeaderboard(board entity.Leaderboard) (entity.Leaderboard, error)
+	FindLeaderboard(id uint) (entity.Leaderboard, error)
+	ListLeaderboards() ([]entity.Leaderboard, error)
+	UpdateRules(id uint, rules entity.PlausibilityRules) (entity.Leaderboard, error)
+	SubmitScore(submission entity.ScoreSubmission) (entity.SubmissionResult, error)
+	SubmitBatch(batch entity.BatchSubmission) (entity.BatchReport, error)
+	TopEntries(query entity.LeaderboardQuery) (entity.EntryPage, error)
+	Export(query entity.ExportQuery) (Export, error)
+	Import(leaderboardId uint, req entity.ImportRequest, body io.Reader) (entity.ImportReport, error)
+	ListPeriods(leaderboardId uint, window string) ([]entity.LeaderboardPeriod, error)
+	ArchiveExpiredPeriods() error
+	AroundUser(query entity.LeaderboardQuery, userId string, k int) ([]entity.LeaderboardEntry, error)
+	EntryAtRank(query entity.LeaderboardQuery, rank int) (entity.LeaderboardEntry, error)
+	UserRank(query entity.LeaderboardQuery, userId string) (entity.UserRank, error)
+}
+
+// leaderboardservice is an implementation of LeaderboardService
+type leaderboardservice struct{}
+
+// NewLeaderboardService creates and returns a new instance of LeaderboardService
+func NewLeaderboardService() LeaderboardService {
+	return &leaderboardservice{}
+}
+
+// CreateLeaderboard validates the board settings and saves them
+func (s *leaderboardservice) CreateLeaderboard(board entity.Leaderboard) (entity.Leaderboard, error) {
+	// fill in the defaults
+	if board.SortOrder == "" {
+		board.SortOrder = SortDesc
+	}
+	if board.Aggregation == "" {
+		board.Aggregation = AggregateBest
+	}
+	if board.Format == "" {
+		board.Format = utils.FormatInteger
+	}
+	if board.Timezone == "" {
+		board.Timezone = "UTC"
+	}
+	if board.WeekStart == "" {
+		board.WeekStart = "monday"
+	}
+	if board.TieBreak == "" {
+		board.TieBreak = TieEarliest
+	}
+	if board.MetricOrder == "" {
+		board.MetricOrder = SortAsc
+	}
+	if board.HistoryDays == 0 {
+		board.HistoryDays = defaultHistoryDays
+	}
+	board.WeekStart = strings.ToLower(board.WeekStart)
+	if err := validateLeaderboard(board); err != nil {
+		return entity.Leaderboard{}, err
+	}
+
+	newBoard := model.Leaderboard{
+		Name:             board.Name,
+		Game:             board.Game,
+		SortOrder:        board.SortOrder,
+		Aggregation:      board.Aggregation,
+		Format:           board.Format,
+		Precision:        board.Precision,
+		Timezone:         board.Timezone,
+		WeekStart:        board.WeekStart,
+		TieBreak:         board.TieBreak,
+		MetricOrder:      board.MetricOrder,
+		HistoryDays:      board.HistoryDays,
+		RequiresApproval: board.RequiresApproval,
+	}
+	setRules(&newBoard, board.Rules)
+	result := config.DB.Create(&newBoard)
+	if result.Error != nil {
+		return entity.Leaderboard{}, result.Error
+	}
+	return toLeaderboardEntity(newBoard), nil
+}
+
+// FindLeaderboard finds a leaderboard by id
+func (s *leaderboardservice) FindLeaderboard(id uint) (entity.Leaderboard, error) {
+	board, err := findLeaderboard(config.DB, id)
+	if err != nil {
+		return entity.Leaderboard{}, err
+	}
+	return toLeaderboardEntity(board), nil
+}
+
+// ListLeaderboards returns every leaderboard
+func (s *leaderboardservice) ListLeaderboards() ([]entity.Leaderboard, error) {
+	var boards []model.Leaderboard
+	result := config.DB.Order("id").Find(&boards)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	list := make([]entity.Leaderboard, 0, len(boards))
+	for _, board := range boards {
+		list = append(list, toLeaderboardEntity(board))
+	}
+	return list, nil
+}
+
+// UpdateRules replaces the plausibility rules of a board
+func (s *leaderboardservice) UpdateRules(id uint, rules entity.PlausibilityRules) (entity.Leaderboard, error) {
+	if err := validateRules(rules); err != nil {
+		return entity.Leaderboard{}, err
+	}
+	board, err := findLeaderboard(config.DB, id)
+	if err != nil {
+		return entity.Leaderboard{}, err
+	}
+	setRules(&board, rules)
+	result := config.DB.Select("MinScore", "MaxScore", "MaxImprovement", "MaxPerMinute", "MaxZScore").Save(&board)
+	if result.Error != nil {
+		return entity.Leaderboard{}, result.Error
+	}
+	return toLeaderboardEntity(board), nil
+}
+
+// SubmitScore checks the submission signature and applies the score to the user's entry in every
+// time window following the board's aggregation policy
+func (s *leaderboardservice) SubmitScore(submission entity.ScoreSubmission) (entity.SubmissionResult, error) {
+	board, signed, err := checkSubmission(submission)
+	if err != nil {
+		return entity.SubmissionResult{}, rejected(submission, err)
+	}
+
+	// the DB keeps milliseconds, ranking on the same precision keeps the index and SQL in agreement
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var rec recorded
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		rec, err = recordSubmission(tx, board, submission, signed, now)
+		return err
+	})
+	if err != nil {
+		return entity.SubmissionResult{}, rejected(submission, err)
+	}
+
+	// the score is saved by now, a failure to rank it must not fail the submission
+	entries, err := afterWrite(board, rec.writes)
+	if err != nil {
+		fmt.Println("Error ranking the submitted score:", err)
+	}
+	return rec.result(board, entries), nil
+}
+
+// recorded is a submission saved in a transaction with the entries it wrote
+type recorded struct {
+	sub    model.Submission
+	flags  []string
+	best   *float64
+	writes []entryWrite
+}
+
+func (r recorded) result(board model.Leaderboard, entries map[string]entity.LeaderboardEntry) entity.SubmissionResult {
+	return entity.SubmissionResult{
+		SubmissionId:  r.sub.ID,
+		LeaderboardId: board.ID,
+		Status:        r.sub.Status,
+		Flags:         r.flags,
+		PersonalBest:  r.sub.PersonalBest,
+		PreviousBest:  r.best,
+		Entries:       entries,
+	}
+}
+
+// checkSubmission runs the checks of a submission that need no transaction: its fields, the board,
+// bans and the signature. signed reports whether the nonce still has to be used up.
+func checkSubmission(submission entity.ScoreSubmission) (model.Leaderboard, bool, error) {
+	if err := validateEvidenceUrl(submission.EvidenceUrl); err != nil {
+		return model.Leaderboard{}, false, err
+	}
+	if err := validateMetadata(submission.Metadata); err != nil {
+		return model.Leaderboard{}, false, err
+	}
+	board, err := findLeaderboard(config.DB, submission.LeaderboardId)
+	if err != nil {
+		return model.Leaderboard{}, false, err
+	}
+	// a shadow banned player is let through, writeScore hides what they send
+	if ban, banned, err := activeBan(config.DB, board.ID, submission.UserId, false); err != nil {
+		return model.Leaderboard{}, false, err
+	} else if banned {
+		return model.Leaderboard{}, false, &BannedError{Reason: ban.Reason, ExpiresAt: ban.ExpiresAt}
+	}
+	signed, err := verifySignature(config.DB, board, submission)
+	if err != nil {
+		return model.Leaderboard{}, false, err
+	}
+	return board, signed, nil
+}
+
+// recordSubmission saves a checked submission and applies it to the entries unless it waits for a
+// moderator, it must run in a transaction
+func recordSubmission(tx *gorm.DB, board model.Leaderboard, submission entity.ScoreSubmission, signed bool, now time.Time) (recorded, error) {
+	var rec recorded
+	if signed {
+		if err := useNonce(tx, board.Game, submission.Nonce); err != nil {
+			return recorded{}, err
+		}
+	}
+	var err error
+	rec.flags, err = checkPlausibility(tx, board, submission.UserId, submission.Score, now)
+	if err != nil {
+		return recorded{}, err
+	}
+
+	rec.sub = model.Submission{
+		LeaderboardId: board.ID,
+		UserId:        submission.UserId,
+		Score:         submission.Score,
+		Metric:        submission.Metric,
+		Status:        SubmissionAccepted,
+		Flags:         strings.Join(rec.flags, ","),
+		EvidenceUrl:   submission.EvidenceUrl,
+	}
+	if rec.sub.TeamId, err = teamOf(tx, submission.UserId); err != nil {
+		return recorded{}, err
+	}
+	if len(submission.Metadata) > 0 && string(submission.Metadata) != "null" {
+		rec.sub.Metadata = string(submission.Metadata)
+	}
+	rec.sub.CreatedAt = now
+	// a flagged or pending score waits for a moderator and stays off the board
+	if len(rec.flags) > 0 {
+		rec.sub.Status = SubmissionFlagged
+	} else if board.RequiresApproval {
+		rec.sub.Status = SubmissionPending
+	}
+	if rec.sub.Status != SubmissionAccepted {
+		return rec, tx.Create(&rec.sub).Error
+	}
+	if rec.best, err = markPersonalBest(tx, board, &rec.sub); err != nil {
+		return recorded{}, err
+	}
+	if err := tx.Create(&rec.sub).Error; err != nil {
+		return recorded{}, err
+	}
+	rec.writes, err = writeScore(tx, board, submission.UserId, submission.Score, submission.Metric, now)
+	return rec, err
+}
+
+// InvalidError is returned for a request that carries something the service does not accept
+type InvalidError struct {
+	Err error
+}
+
+func (e *InvalidError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidError) Unwrap() error {
+	return e.Err
+}
+
+// invalid returns an InvalidError with the message
+func invalid(msg string) error {
+	return &InvalidError{Err: errors.New(msg)}
+}
+
+// invalidf returns an InvalidError with the formatted message
+func invalidf(format string, args ...interface{}) error {
+	return &InvalidError{Err: fmt.Errorf(format, args...)}
+}
+
+// refused reports whether err is the service turning the request down rather than failing it, a
+// refused request gets the same answer when it is sent again
+func refused(err error) bool {
+	var invalid *InvalidError
+	var rejection *RejectedError
+	var ban *BannedError
+	return errors.As(err, &invalid) || errors.As(err, &rejection) || errors.As(err, &ban) ||
+		errors.Is(err, gorm.ErrRecordNotFound)
+}
+
+// rejected records the reason of a RejectedError and hands the error back
+func rejected(submission entity.ScoreSubmission, err error) error {
+	var rejection *RejectedError
+	if errors.As(err, &rejection) {
+		recordRejection(submission, rejection.Reason)
+	}
+	return err
+}
+
+// entryWrite is an entry as it was before and after a score was applied, before has no ID for new entries
+type entryWrite struct {
+	before model.LeaderboardEntry
+	after  model.LeaderboardEntry
+}
+
+// writeScore applies a score submitted at now to the user's entry of every window open at that time,
+// it must run in a transaction
+func writeScore(tx *gorm.DB, board model.Leaderboard, userId string, score, metric float64, now time.Time) ([]entryWrite, error) {
+	periods, err := currentPeriods(board, now)
+	if err != nil {
+		return nil, err
+	}
+	// a running season is one more window the score counts toward, imported scores older than it do not
+	if p, ok, err := openSeasonPeriod(tx, board.ID); err != nil {
+		return nil, err
+	} else if ok && !now.Before(p.Start) {
+		periods = append(periods, p)
+	}
+
+	country, err := countryOf(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	_, hidden, err := activeBan(tx, board.ID, userId, true)
+	if err != nil {
+		return nil, err
+	}
+
+	writes := make([]entryWrite, 0, len(periods))
+	for _, p := range periods {
+		if err := openPeriod(tx, board, p); err != nil {
+			return nil, err
+		}
+		entry, err := lockEntry(tx, board, p, userId)
+		if err != nil {
+			return nil, err
+		}
+		before := entry
+		applyScore(board, &entry, score, metric, now)
+		entry.Country = country
+		entry.Hidden = hidden
+		if err := tx.Save(&entry).Error; err != nil {
+			return nil, err
+		}
+		writes = append(writes, entryWrite{before: before, after: entry})
+	}
+	// the player's team entries move with theirs
+	if err := updateTeamScores(tx, board, userId, periods); err != nil {
+		return nil, err
+	}
+	return writes, nil
+}
+
+// afterWrite runs once the writes are committed, it updates the rank index, publishes the
+// rank changes and returns the new entries keyed by window
+func afterWrite(board model.Leaderboard, writes []entryWrite) (map[string]entity.LeaderboardEntry, error) {
+	entries, err := afterWrites(board, [][]entryWrite{writes})
+	if err != nil {
+		return nil, err
+	}
+	return entries[0], nil
+}
+
+// afterWrites runs once the writes of one or more submissions to a board are committed. The rank
+// changes of all of them are published together, an entry written more than once moves from where
+// it was before the first write to where the last one put it. It returns the entries of each
+// submission keyed by window, a submission a later one wrote over gets its own entry ranked among
+// the others as they are now.
+func afterWrites(board model.Leaderboard, batches [][]entryWrite) ([]map[string]entity.LeaderboardEntry, error) {
+	var order []uint
+	net := map[uint]entryWrite{}
+	last := map[uint]int{}
+	for i, writes := range batches {
+		for _, w := range writes {
+			last[w.after.ID] = i
+			if first, ok := net[w.after.ID]; ok {
+				first.after = w.after
+				net[w.after.ID] = first
+				continue
+			}
+			net[w.after.ID] = w
+			order = append(order, w.after.ID)
+		}
+	}
+
+	// the previous ranks are only needed when someone listens for the changes
+	watched := hub.watching(board.ID)
+	previous := make(map[uint]int, len(order))
+	if watched {
+		for _, id := range order {
+			if before := net[id].before; before.ID != 0 {
+				previous[id], _ = ranks.Rank(board, entryPeriod(before), before)
+			}
+		}
+	}
+
+	after := make([]model.LeaderboardEntry, 0, len(order))
+	for _, id := range order {
+		after = append(after, net[id].after)
+	}
+	ranks.Set(board, after...)
+
+	ranked := make(map[uint]entity.LeaderboardEntry, len(order))
+	changes := make([]rankChange, 0, len(order))
+	for _, id := range order {
+		w := net[id]
+		rank, err := ownRank(board, entryPeriod(w.after), w.after)
+		if err != nil {
+			return nil, err
+		}
+		list, err := rankedEntities(board, entryPeriod(w.after), []model.LeaderboardEntry{w.after}, rank)
+		if err != nil {
+			return nil, err
+		}
+		ranked[id] = list[0]
+		// a shadow banned player is the only one who sees their scores move
+		if w.after.Hidden {
+			continue
+		}
+		// events follow positions so a watched top N keeps a fixed size
+		changes = append(changes, rankChange{
+			entry:        w.after,
+			rank:         rank,
+			previousRank: previous[id],
+			scoreChanged: w.before.Score != w.after.Score,
+		})
+	}
+
+	if watched {
+		hub.publish(board, changes, func(entry model.LeaderboardEntry, rank int) (model.LeaderboardEntry, bool) {
+			pushed, err := ranks.At(board, entryPeriod(entry), rank)
+			return pushed, err == nil
+		})
+	}
+
+	results := make([]map[string]entity.LeaderboardEntry, len(batches))
+	for i, writes := range batches {
+		entries := make(map[string]entity.LeaderboardEntry, len(writes))
+		for _, w := range writes {
+			if last[w.after.ID] == i {
+				entries[w.after.Window] = ranked[w.after.ID]
+				continue
+			}
+			p := entryPeriod(w.after)
+			rank, err := entryRank(config.DB, board, p, w.after)
+			if err != nil {
+				return nil, err
+			}
+			list, err := rankedEntities(board, p, []model.LeaderboardEntry{w.after}, rank)
+			if err != nil {
+				return nil, err
+			}
+			entries[w.after.Window] = list[0]
+		}
+		results[i] = entries
+		// the score already counts, achievements that fail to move are not worth failing it for
+		if len(writes) > 0 && !writes[0].after.Hidden {
+			if err := recordAchievements(board, writes[0].after.UserId, entries); err != nil {
+				fmt.Println("Error recording achievements:", err)
+			}
+		}
+	}
+	return results, nil
+}
+
+// TopEntries returns a page of a board window in rank order. Pages are cut on the sort key of the
+// entries around them, so scores moving between two reads neither skip nor repeat players.
+func (s *leaderboardservice) TopEntries(query entity.LeaderboardQuery) (entity.EntryPage, error) {
+	board, p, err := resolveQuery(query)
+	if err != nil {
+		return entity.EntryPage{}, err
+	}
+	return topPage(board, p, query.Cursor, query.Limit)
+}
+
+// topPage reads the page of a board period at the cursor
+func topPage(board model.Leaderboard, p period, cursor string, limit int) (entity.EntryPage, error) {
+	pg, err := newPager(entriesScope(board, p), cursor, limit)
+	if err != nil {
+		return entity.EntryPage{}, err
+	}
+
+	var entries []model.LeaderboardEntry
+	if pg.from == nil {
+		// the first page comes from the rank index
+		entries, err = ranks.Top(board, p, pg.limit+1)
+	} else {
+		db := periodEntries(config.DB, board, p)
+		from := cursorEntry(*pg.from)
+		if pg.back() {
+			cond, args := aboveEntry(board, from)
+			err = db.Where(cond, args...).Order(reverseRankOrder(board)).Limit(pg.limit + 1).Find(&entries).Error
+		} else {
+			cond, args := belowEntry(board, from)
+			err = db.Where(cond, args...).Order(rankOrder(board)).Limit(pg.limit + 1).Find(&entries).Error
+		}
+	}
+	if err != nil {
+		return entity.EntryPage{}, err
+	}
+	more := len(entries) > pg.limit
+	if more {
+		entries = entries[:pg.limit]
+	}
+	if pg.back() {
+		slices.Reverse(entries)
+	}
+
+	page := entity.EntryPage{Entries: []entity.LeaderboardEntry{}}
+	if len(entries) == 0 {
+		return page, nil
+	}
+	position := 1
+	if pg.from != nil {
+		if position, err = ranks.Rank(board, p, entries[0]); err != nil {
+			return entity.EntryPage{}, err
+		}
+	}
+	if page.Entries, err = rankedEntities(board, p, entries, position); err != nil {
+		return entity.EntryPage{}, err
+	}
+	page.Cursors = pg.cursors(more, entryCursor(entries[0]), entryCursor(entries[len(entries)-1]))
+	return page, nil
+}
+
+// entriesScope names the entry list of a board period for its cursors
+func entriesScope(board model.Leaderboard, p period) string {
+	return fmt.Sprintf("entries:%d:%s:%d:%s", board.ID, p.Window, p.Start.Unix(), p.Country)
+}
+
+// ListPeriods returns the current and archived periods of a window with their winners, newest first
+func (s *leaderboardservice) ListPeriods(leaderboardId uint, window string) ([]entity.LeaderboardPeriod, error) {
+	board, err := findLeaderboard(config.DB, leaderboardId)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := periodAt(board, window, time.Now()); err != nil {
+		return nil, err
+	}
+
+	var periods []model.LeaderboardPeriod
+	result := config.DB.Where("leaderboard_id = ? AND time_window = ?", board.ID, window).
+		Order("period_start desc").
+		Limit(100).
+		Find(&periods)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	list := make([]entity.LeaderboardPeriod, 0, len(periods))
+	for _, p := range periods {
+		item := entity.LeaderboardPeriod{
+			Window:      p.Window,
+			PeriodStart: p.PeriodStart,
+			PeriodEnd:   p.PeriodEnd,
+			Archived:    p.Archived,
+		}
+		top, err := topEntries(board, period{Window: p.Window, Start: p.PeriodStart, End: p.PeriodEnd}, 1)
+		if err != nil {
+			return nil, err
+		}
+		if len(top) > 0 {
+			item.Winner = &top[0]
+		}
+		list = append(list, item)
+	}
+	return list, nil
+}
+
+// ArchiveExpiredPeriods marks every period that has ended as archived, their entries are kept
+func (s *leaderboardservice) ArchiveExpiredPeriods() error {
+	var expired []model.LeaderboardPeriod
+	result := config.DB.Where("archived = ? AND period_end <= ?", false, time.Now()).Find(&expired)
+	if result.Error != nil || len(expired) == 0 {
+		return result.Error
+	}
+	ids := make([]uint, len(expired))
+	for i, p := range expired {
+		ids[i] = p.ID
+	}
+	if err := config.DB.Model(&model.LeaderboardPeriod{}).Where("id IN ?", ids).Update("archived", true).Error; err != nil {
+		return err
+	}
+	// archived periods are read from SQL, free their index
+	for _, p := range expired {
+		ranks.Drop(model.Leaderboard{Model: gorm.Model{ID: p.LeaderboardId}}, period{Window: p.Window, Start: p.PeriodStart})
+	}
+	return nil
+}
+
+// openPeriod records a window period the first time a score lands in it and archives the older ones
+func openPeriod(tx *gorm.DB, board model.Leaderboard, p period) error {
+	if p.Window == WindowAllTime {
+		return nil
+	}
+	record := model.LeaderboardPeriod{
+		LeaderboardId: board.ID,
+		Window:        p.Window,
+		PeriodStart:   p.Start,
+		PeriodEnd:     p.End,
+	}
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	return tx.Model(&model.LeaderboardPeriod{}).
+		Where("leaderboard_id = ? AND time_window = ? AND period_start < ? AND archived = ?", board.ID, p.Window, p.Start, false).
+		Update("archived", true).Error
+}
+
+// lockEntry loads the user's entry of a period for update, or a fresh one when there is none yet
+func lockEntry(tx *gorm.DB, board model.Leaderboard, p period, userId string) (model.LeaderboardEntry, error) {
+	var entry model.LeaderboardEntry
+	result := periodScope(tx, board, p).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userId).
+		First(&entry)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return model.LeaderboardEntry{
+			LeaderboardId: board.ID,
+			Window:        p.Window,
+			PeriodStart:   p.Start,
+			UserId:        userId,
+		}, nil
+	}
+	if result.Error != nil {
+		return model.LeaderboardEntry{}, result.Error
+	}
+	return entry, nil
+}
+
+// queryPeriod resolves the window and date of a read, defaulting to the current all time board
+func queryPeriod(db *gorm.DB, board model.Leaderboard, query entity.LeaderboardQuery) (period, error) {
+	p, err := windowPeriod(db, board, query)
+	if err != nil || query.Country == "" {
+		return p, err
+	}
+	p.Country = strings.ToUpper(query.Country)
+	return p, validateCountry(p.Country)
+}
+
+// windowPeriod resolves the window and date of a read
+func windowPeriod(db *gorm.DB, board model.Leaderboard, query entity.LeaderboardQuery) (period, error) {
+	window := query.Window
+	if window == "" {
+		window = WindowAllTime
+	}
+	if window == WindowSeason {
+		p, ok, err := openSeasonPeriod(db, board.ID)
+		if err == nil && !ok {
+			err = invalid("the leaderboard has no open season")
+		}
+		return p, err
+	}
+	at := time.Now()
+	if !query.Period.IsZero() {
+		// the date is a calendar day in the board's timezone, noon keeps it clear of DST shifts
+		loc, err := time.LoadLocation(board.Timezone)
+		if err != nil {
+			return period{}, err
+		}
+		y, m, d := query.Period.Date()
+		at = time.Date(y, m, d, 12, 0, 0, 0, loc)
+	}
+	return periodAt(board, window, at)
+}
+
+// topEntries reads the first entries of a period in rank order
+func topEntries(board model.Leaderboard, p period, limit int) ([]entity.LeaderboardEntry, error) {
+	entries, err := ranks.Top(board, p, limit)
+	if err != nil {
+		return nil, err
+	}
+	return rankedEntities(board, p, entries, 1)
+}
+
+// applyScore updates an entry with a new score according to the board's aggregation mode, the metric
+// is only kept on boards that break ties with it
+func applyScore(board model.Leaderboard, entry *model.LeaderboardEntry, score, metric float64, now time.Time) {
+	first := entry.Submissions == 0
+	entry.Submissions++
+	if board.TieBreak != TieMetric {
+		metric = 0
+	}
+
+	switch board.Aggregation {
+	case AggregateLatest:
+		entry.Score = score
+		entry.Metric = metric
+	case AggregateSum:
+		entry.Score += score
+	case AggregateCount:
+		entry.Score = float64(entry.Submissions)
+	default:
+		// best only moves the entry when the score beats the current one, or ties it with a better metric
+		tieBroken := score == entry.Score && isBetterMetric(board, metric, entry.Metric)
+		if !first && !isBetter(board, score, entry.Score) && !tieBroken {
+			return
+		}
+		entry.Score = score
+		entry.Metric = metric
+	}
+	entry.SubmittedAt = now
+}
+
+// entryRank counts the entries of a period placed above the given one
+func entryRank(db *gorm.DB, board model.Leaderboard, p period, entry model.LeaderboardEntry) (int, error) {
+	var above int64
+	query, args := aboveEntry(board, entry)
+	result := periodEntries(db, board, p).
+		Where(query, args...).
+		Where("id <> ?", entry.ID).
+		Count(&above)
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return int(above) + 1, nil
+}
+
+// rankOrder is the ORDER BY that puts the entries of a board in rank order. The metric is 0 on boards
+// that do not break ties with it, so the earliest submission decides there.
+func rankOrder(board model.Leaderboard) string {
+	return "score " + board.SortOrder + ", metric " + metricOrder(board) + ", submitted_at, id"
+}
+
+// reverseRankOrder walks the entries from the bottom of the board up
+func reverseRankOrder(board model.Leaderboard) string {
+	return "score " + reverse(board.SortOrder) + ", metric " + reverse(metricOrder(board)) + ", submitted_at desc, id desc"
+}
+
+// aboveEntry is the condition matching the entries ranked above the given one, it follows rankOrder
+func aboveEntry(board model.Leaderboard, entry model.LeaderboardEntry) (string, []interface{}) {
+	return "score " + betterOp(board.SortOrder) + " ? OR (score = ? AND (metric " + betterOp(metricOrder(board)) +
+			" ? OR (metric = ? AND (submitted_at < ? OR (submitted_at = ? AND id < ?)))))",
+		[]interface{}{entry.Score, entry.Score, entry.Metric, entry.Metric, entry.SubmittedAt, entry.SubmittedAt, entry.ID}
+}
+
+// belowEntry is the condition matching the entries ranked below the given one
+func belowEntry(board model.Leaderboard, entry model.LeaderboardEntry) (string, []interface{}) {
+	return "score " + betterOp(reverse(board.SortOrder)) + " ? OR (score = ? AND (metric " + betterOp(reverse(metricOrder(board))) +
+			" ? OR (metric = ? AND (submitted_at > ? OR (submitted_at = ? AND id > ?)))))",
+		[]interface{}{entry.Score, entry.Score, entry.Metric, entry.Metric, entry.SubmittedAt, entry.SubmittedAt, entry.ID}
+}
+
+// betterOp is the comparison a better value passes in the given sort direction
+func betterOp(order string) string {
+	if order == SortAsc {
+		return "<"
+	}
+	return ">"
+}
+
+// metricOrder is the direction of the tie-break metric, lowest first unless the board says otherwise
+func metricOrder(board model.Leaderboard) string {
+	if board.MetricOrder == SortDesc {
+		return SortDesc
+	}
+	return SortAsc
+}
+
+func reverse(order string) string {
+	if order == SortAsc {
+		return SortDesc
+	}
+	return SortAsc
+}
+
+// rankedBatches walks every entry of a period in rank order, seeking past the last entry of each batch
+func rankedBatches(db *gorm.DB, board model.Leaderboard, p period, size int, fn func(entries []model.LeaderboardEntry) error) error {
+	var last *model.LeaderboardEntry
+	for {
+		query := periodEntries(db, board, p)
+		if last != nil {
+			cond, args := belowEntry(board, *last)
+			query = query.Where(cond, args...)
+		}
+		var entries []model.LeaderboardEntry
+		if err := query.Order(rankOrder(board)).Limit(size).Find(&entries).Error; err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		if err := fn(entries); err != nil {
+			return err
+		}
+		if len(entries) < size {
+			return nil
+		}
+		last = &entries[len(entries)-1]
+	}
+}
+
+// isBetter reports if score a ranks above score b on the board
+func isBetter(board model.Leaderboard, a, b float64) bool {
+	if board.SortOrder == SortAsc {
+		return a < b
+	}
+	return a > b
+}
+
+// isBetterMetric reports if metric a breaks a tie in favour of a over b
+func isBetterMetric(board model.Leaderboard, a, b float64) bool {
+	if metricOrder(board) == SortDesc {
+		return a > b
+	}
+	return a < b
+}
+
+// validateLeaderboard checks the board settings are known values
+func validateLeaderboard(board entity.Leaderboard) error {
+	switch board.SortOrder {
+	case SortDesc, SortAsc:
+	default:
+		return invalid("sort_order must be desc or asc")
+	}
+	switch board.Aggregation {
+	case AggregateBest, AggregateLatest, AggregateSum, AggregateCount:
+	default:
+		return invalid("aggregation must be best, latest, sum or count")
+	}
+	switch board.Format {
+	case utils.FormatInteger, utils.FormatMilliseconds, utils.FormatDecimal:
+	default:
+		return invalid("format must be integer, milliseconds or decimal")
+	}
+	if board.Precision < 0 || board.Precision > 6 {
+		return invalid("precision must be between 0 and 6")
+	}
+	switch board.TieBreak {
+	case TieEarliest, TieCompetition, TieDense:
+	case TieMetric:
+		// a sum or count has no single run the metric could belong to
+		if board.Aggregation != AggregateBest && board.Aggregation != AggregateLatest {
+			return invalid("tie_break metric needs the best or latest aggregation")
+		}
+	default:
+		return invalid("tie_break must be earliest, metric, competition or dense")
+	}
+	switch board.MetricOrder {
+	case SortDesc, SortAsc:
+	default:
+		return invalid("metric_order must be desc or asc")
+	}
+	if err := validateHistoryDays(board.HistoryDays); err != nil {
+		return err
+	}
+	if err := validateRules(board.Rules); err != nil {
+		return err
+	}
+	return validateWindowSettings(board.Timezone, board.WeekStart)
+}
+
+// findLeaderboard loads a leaderboard by id
+func findLeaderboard(db *gorm.DB, id uint) (model.Leaderboard, error) {
+	var board model.Leaderboard
+	result := db.First(&board, id)
+	if result.Error != nil {
+		return model.Leaderboard{}, result.Error
+	}
+	return board, nil
+}
+
+func toLeaderboardEntity(board model.Leaderboard) entity.Leaderboard {
+	return entity.Leaderboard{
+		ID:               board.ID,
+		Name:             board.Name,
+		Game:             board.Game,
+		SortOrder:        board.SortOrder,
+		Aggregation:      board.Aggregation,
+		Format:           board.Format,
+		Precision:        board.Precision,
+		Timezone:         board.Timezone,
+		WeekStart:        board.WeekStart,
+		TieBreak:         board.TieBreak,
+		MetricOrder:      board.MetricOrder,
+		HistoryDays:      board.HistoryDays,
+		RequiresApproval: board.RequiresApproval,
+		Rules: entity.PlausibilityRules{
+			MinScore:       board.MinScore,
+			MaxScore:       board.MaxScore,
+			MaxImprovement: board.MaxImprovement,
+			MaxPerMinute:   board.MaxPerMinute,
+			MaxZScore:      board.MaxZScore,
+		},
+	}
+}
+
+func setRules(board *model.Leaderboard, rules entity.PlausibilityRules) {
+	board.MinScore = rules.MinScore
+	board.MaxScore = rules.MaxScore
+	board.MaxImprovement = rules.MaxImprovement
+	board.MaxPerMinute = rules.MaxPerMinute
+	board.MaxZScore = rules.MaxZScore
+}
+
+func toEntryEntity(board model.Leaderboard, entry model.LeaderboardEntry, rank int) entity.LeaderboardEntry {
+	return entity.LeaderboardEntry{
+		Rank:           rank,
+		Window:         entry.Window,
+		PeriodStart:    entry.PeriodStart,
+		UserId:         entry.UserId,
+		Country:        entry.Country,
+		Score:          entry.Score,
+		FormattedScore: utils.FormatScore(entry.Score, board.Format, board.Precision),
+		Metric:         entry.Metric,
+		Submissions:    entry.Submissions,
+		SubmittedAt:    entry.SubmittedAt,
+	}
+}
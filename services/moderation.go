@@ -0,0 +1,221 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Submission states, only accepted and approved scores reach the board
+const (
+	SubmissionAccepted = "accepted"
+	// SubmissionPending waits for approval on a board that requires it
+	SubmissionPending = "pending"
+	// SubmissionFlagged broke a plausibility rule and waits for review
+	SubmissionFlagged  = "flagged"
+	SubmissionApproved = "approved"
+	SubmissionRejected = "rejected"
+)
+
+// maxBulkReview is how many submissions one bulk action may touch
+const maxBulkReview = 100
+
+// ErrAlreadyReviewed is returned when a moderator acts on a submission that is not waiting for review
+var ErrAlreadyReviewed = errors.New("submission is not waiting for review")
+
+// ModerationService is an interface for the review queue of score submissions and the sanctions
+// taken on cheaters
+type ModerationService interface {
+	ListQueue(leaderboardId uint, status string, page entity.PageQuery) (entity.SubmissionPage, error)
+	Review(id uint, moderatorId string, review entity.Review) (entity.Submission, error)
+	BulkReview(leaderboardId uint, moderatorId string, req entity.BulkReview) ([]entity.ReviewResult, error)
+	RemoveEntries(moderatorId string, req entity.NewRemoval) (entity.Removal, error)
+	RestoreEntries(removalId uint, moderatorId string, reason string) (entity.Removal, error)
+	ListRemovals(userId string) ([]entity.Removal, error)
+	Ban(moderatorId string, req entity.NewBan) (entity.Ban, error)
+	LiftBan(banId uint, moderatorId string, reason string) (entity.Ban, error)
+	ListBans(userId string) ([]entity.Ban, error)
+	LiftExpiredBans() error
+	AuditLog(userId string, page entity.PageQuery) (entity.AuditPage, error)
+}
+
+// moderationservice is an implementation of ModerationService
+type moderationservice struct{}
+
+// NewModerationService creates and returns a new instance of ModerationService
+func NewModerationService() ModerationService {
+	return &moderationservice{}
+}
+
+// ListQueue returns the oldest submissions of a leaderboard in a status, by default those waiting for review
+func (s *moderationservice) ListQueue(leaderboardId uint, status string, page entity.PageQuery) (entity.SubmissionPage, error) {
+	statuses := []string{SubmissionPending, SubmissionFlagged}
+	switch status {
+	case "":
+	case SubmissionPending, SubmissionFlagged, SubmissionApproved, SubmissionRejected:
+		statuses = []string{status}
+	default:
+		return entity.SubmissionPage{}, invalid("status must be pending, flagged, approved or rejected")
+	}
+
+	pg, err := newPager(fmt.Sprintf("queue:%d:%s", leaderboardId, status), page.Cursor, page.Limit)
+	if err != nil {
+		return entity.SubmissionPage{}, err
+	}
+	return submissionPage(pg, config.DB.Where("leaderboard_id = ? AND status IN ?", leaderboardId, statuses), false)
+}
+
+// Review approves or rejects a waiting submission and tells the submitter,
+// an approved score is applied to the windows open when it was submitted
+func (s *moderationservice) Review(id uint, moderatorId string, review entity.Review) (entity.Submission, error) {
+	if !review.Approve && strings.TrimSpace(review.Reason) == "" {
+		return entity.Submission{}, invalid("a reason is required to reject a submission")
+	}
+
+	var sub model.Submission
+	var board model.Leaderboard
+	var writes []entryWrite
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&sub, id).Error; err != nil {
+			return err
+		}
+		if sub.Status != SubmissionPending && sub.Status != SubmissionFlagged {
+			return ErrAlreadyReviewed
+		}
+		var err error
+		board, err = findLeaderboard(tx, sub.LeaderboardId)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC().Truncate(time.Millisecond)
+		sub.Status = SubmissionRejected
+		if review.Approve {
+			if _, err := markPersonalBest(tx, board, &sub); err != nil {
+				return err
+			}
+			sub.Status = SubmissionApproved
+			// the score counts when it was sent, in the windows open then even if they are archived by now
+			writes, err = writeScore(tx, board, sub.UserId, sub.Score, sub.Metric, sub.CreatedAt.UTC().Truncate(time.Millisecond))
+			if err != nil {
+				return err
+			}
+		}
+		sub.ReviewedBy = moderatorId
+		sub.Reason = review.Reason
+		sub.ReviewedAt = &now
+		if err := tx.Save(&sub).Error; err != nil {
+			return err
+		}
+		return notifyReview(tx, board, sub)
+	})
+	if err != nil {
+		return entity.Submission{}, err
+	}
+
+	if len(writes) > 0 {
+		// the review is saved by now, a failure to rank the score must not fail it
+		if _, err := afterWrite(board, writes); err != nil {
+			fmt.Println("Error ranking the approved score:", err)
+		}
+	}
+	return toSubmissionEntity(sub), nil
+}
+
+// BulkReview applies one decision to many submissions of a board, each is reviewed on its own
+// so one that fails does not hold back the rest
+func (s *moderationservice) BulkReview(leaderboardId uint, moderatorId string, req entity.BulkReview) ([]entity.ReviewResult, error) {
+	if len(req.Ids) > maxBulkReview {
+		return nil, invalidf("at most %d submissions can be reviewed at once", maxBulkReview)
+	}
+	// only submissions of the board in the url are acted on
+	var ids []uint
+	result := config.DB.Model(&model.Submission{}).
+		Where("id IN ? AND leaderboard_id = ?", req.Ids, leaderboardId).
+		Pluck("id", &ids)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	results := make([]entity.ReviewResult, 0, len(req.Ids))
+	for _, id := range req.Ids {
+		res := entity.ReviewResult{Id: id}
+		if !slices.Contains(ids, id) {
+			res.Error = gorm.ErrRecordNotFound.Error()
+		} else if sub, err := s.Review(id, moderatorId, entity.Review{Approve: req.Approve, Reason: req.Reason}); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Submission = &sub
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// notifyReview tells the submitter what a moderator decided
+func notifyReview(tx *gorm.DB, board model.Leaderboard, sub model.Submission) error {
+	score := utils.FormatScore(sub.Score, board.Format, board.Precision)
+	notification := model.Notification{
+		UserId:       sub.UserId,
+		SubmissionId: sub.ID,
+	}
+	if sub.Status == SubmissionApproved {
+		notification.Kind = NotificationApproved
+		notification.Message = fmt.Sprintf("Your score of %s on %s was approved", score, board.Name)
+	} else {
+		notification.Kind = NotificationRejected
+		notification.Message = fmt.Sprintf("Your score of %s on %s was rejected: %s", score, board.Name, sub.Reason)
+	}
+	return tx.Create(&notification).Error
+}
+
+// validateEvidenceUrl checks the optional link to a recording of a run
+func validateEvidenceUrl(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.ParseRequestURI(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || len(raw) > 500 {
+		return invalid("evidence_url must be an http or https url")
+	}
+	return nil
+}
+
+func toSubmissionEntity(sub model.Submission) entity.Submission {
+	flags := []string{}
+	if sub.Flags != "" {
+		flags = strings.Split(sub.Flags, ",")
+	}
+	var metadata json.RawMessage
+	if sub.Metadata != "" {
+		metadata = json.RawMessage(sub.Metadata)
+	}
+	return entity.Submission{
+		ID:            sub.ID,
+		LeaderboardId: sub.LeaderboardId,
+		UserId:        sub.UserId,
+		Score:         sub.Score,
+		Metric:        sub.Metric,
+		Status:        sub.Status,
+		Flags:         flags,
+		EvidenceUrl:   sub.EvidenceUrl,
+		Metadata:      metadata,
+		PersonalBest:  sub.PersonalBest,
+		TeamId:        sub.TeamId,
+		ReviewedBy:    sub.ReviewedBy,
+		Reason:        sub.Reason,
+		ReviewedAt:    sub.ReviewedAt,
+		CreatedAt:     sub.CreatedAt,
+	}
+}
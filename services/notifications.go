@@ -0,0 +1,72 @@
+package services
+
+import (
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+// Notification kinds
+const (
+	NotificationApproved = "submission_approved"
+	NotificationRejected = "submission_rejected"
+)
+
+// NotificationService is an interface for the notifications sent to users
+type NotificationService interface {
+	List(userId string, unread bool, limit int) ([]entity.Notification, error)
+	MarkRead(userId string, id uint) error
+}
+
+// notificationservice is an implementation of NotificationService
+type notificationservice struct{}
+
+// NewNotificationService creates and returns a new instance of NotificationService
+func NewNotificationService() NotificationService {
+	return &notificationservice{}
+}
+
+// List returns the latest notifications of a user, only the unread ones when unread is set
+func (s *notificationservice) List(userId string, unread bool, limit int) ([]entity.Notification, error) {
+	query := config.DB.Where("user_id = ?", userId)
+	if unread {
+		query = query.Where("read_at IS NULL")
+	}
+	var notifications []model.Notification
+	if err := query.Order("id desc").Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	list := make([]entity.Notification, 0, len(notifications))
+	for _, n := range notifications {
+		list = append(list, entity.Notification{
+			ID:           n.ID,
+			Kind:         n.Kind,
+			SubmissionId: n.SubmissionId,
+			Message:      n.Message,
+			Read:         n.ReadAt != nil,
+			CreatedAt:    n.CreatedAt,
+		})
+	}
+	return list, nil
+}
+
+// MarkRead marks one of the user's notifications as read
+func (s *notificationservice) MarkRead(userId string, id uint) error {
+	result := config.DB.Model(&model.Notification{}).
+		Where("id = ? AND user_id = ? AND read_at IS NULL", id, userId).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		config.DB.Model(&model.Notification{}).Where("id = ? AND user_id = ?", id, userId).Count(&count)
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+	}
+	return nil
+}
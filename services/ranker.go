@@ -0,0 +1,584 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/ranking"
+	"gorm.io/gorm"
+)
+
+// loadBatch is how many entries are read per query when an index is rebuilt
+const loadBatch = 5000
+
+// ranker answers the rank reads of board periods
+type ranker interface {
+	// Rank is the position of an entry among the entries of p, a country period counts only its players
+	Rank(board model.Leaderboard, p period, entry model.LeaderboardEntry) (int, error)
+	Count(board model.Leaderboard, p period) (int, error)
+	Top(board model.Leaderboard, p period, limit int) ([]model.LeaderboardEntry, error)
+	At(board model.Leaderboard, p period, rank int) (model.LeaderboardEntry, error)
+	// Around returns the entries of p from k above to k below the given one and the rank of the first
+	Around(board model.Leaderboard, p period, entry model.LeaderboardEntry, k int) ([]model.LeaderboardEntry, int, error)
+	// Better counts the entries with a better score, distinct counts each score once
+	Better(board model.Leaderboard, p period, score float64, distinct bool) (int, error)
+	// Set records entries written by a committed transaction
+	Set(board model.Leaderboard, entries ...model.LeaderboardEntry)
+	// Move records entries a committed transaction filed under a new country
+	Move(board model.Leaderboard, entries ...model.LeaderboardEntry)
+	// Drop forgets a period that will not change any more
+	Drop(board model.Leaderboard, p period)
+}
+
+// ranks is the ranker used by the services, LoadRankIndex picks it at startup
+var ranks ranker = sqlRanker{}
+
+// Rank stores the index can be kept in, picked with RANK_STORE
+const (
+	RankStoreMemory = "memory"
+	RankStoreSQL    = "sql"
+)
+
+// LoadRankIndex picks the ranker from RANK_INDEX and the store of the index from RANK_STORE, then
+// rebuilds the live periods from the DB
+func LoadRankIndex() error {
+	if os.Getenv("RANK_INDEX") == "sql" {
+		ranks = sqlRanker{}
+		return nil
+	}
+
+	name := os.Getenv("RANK_STORE")
+	store, err := newRankStore(name)
+	if err != nil {
+		return err
+	}
+	index := newStoreRanker(store, name == RankStoreSQL)
+	var boards []model.Leaderboard
+	if err := config.DB.Find(&boards).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, board := range boards {
+		periods, err := currentPeriods(board, now)
+		if err != nil {
+			return err
+		}
+		if p, ok, err := openSeasonPeriod(config.DB, board.ID); err != nil {
+			return err
+		} else if ok {
+			periods = append(periods, p)
+		}
+		for _, p := range periods {
+			if _, err := index.period(board, p); err != nil {
+				return fmt.Errorf("loading rank index of leaderboard %d: %w", board.ID, err)
+			}
+		}
+	}
+	ranks = index
+	return nil
+}
+
+// newRankStore returns the store of a RANK_STORE value, the memory store when it is empty
+func newRankStore(name string) (ranking.Store, error) {
+	switch name {
+	case "", RankStoreMemory:
+		return ranking.NewMemoryStore(), nil
+	case RankStoreSQL:
+		return ranking.NewGormStore(config.DB), nil
+	}
+	return nil, fmt.Errorf("RANK_STORE must be %s or %s", RankStoreMemory, RankStoreSQL)
+}
+
+// sqlRanker computes ranks with queries, it is the fallback when no index is kept in memory and the
+// ranker RANK_INDEX=sql picks. It seeks ranks with OFFSET, which reads every row above the rank.
+type sqlRanker struct{}
+
+func (sqlRanker) Rank(board model.Leaderboard, p period, entry model.LeaderboardEntry) (int, error) {
+	return entryRank(config.DB, board, p, entry)
+}
+
+func (sqlRanker) Count(board model.Leaderboard, p period) (int, error) {
+	var total int64
+	if err := periodEntries(config.DB, board, p).Count(&total).Error; err != nil {
+		return 0, err
+	}
+	return int(total), nil
+}
+
+func (sqlRanker) Top(board model.Leaderboard, p period, limit int) ([]model.LeaderboardEntry, error) {
+	var entries []model.LeaderboardEntry
+	result := periodEntries(config.DB, board, p).Order(rankOrder(board)).Limit(limit).Find(&entries)
+	return entries, result.Error
+}
+
+func (sqlRanker) At(board model.Leaderboard, p period, rank int) (model.LeaderboardEntry, error) {
+	var entry model.LeaderboardEntry
+	result := periodEntries(config.DB, board, p).Order(rankOrder(board)).Offset(rank - 1).Limit(1).Find(&entry)
+	if result.Error != nil {
+		return model.LeaderboardEntry{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.LeaderboardEntry{}, gorm.ErrRecordNotFound
+	}
+	return entry, nil
+}
+
+func (r sqlRanker) Around(board model.Leaderboard, p period, entry model.LeaderboardEntry, k int) ([]model.LeaderboardEntry, int, error) {
+	rank, err := r.Rank(board, p, entry)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// seek from the entry both ways, the index on score keeps each read at k rows
+	var above, below []model.LeaderboardEntry
+	cond, args := aboveEntry(board, entry)
+	result := periodEntries(config.DB, board, p).Where(cond, args...).Order(reverseRankOrder(board)).Limit(k).Find(&above)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+	cond, args = belowEntry(board, entry)
+	result = periodEntries(config.DB, board, p).Where(cond, args...).Order(rankOrder(board)).Limit(k).Find(&below)
+	if result.Error != nil {
+		return nil, 0, result.Error
+	}
+
+	list := make([]model.LeaderboardEntry, 0, len(above)+1+len(below))
+	for i := len(above) - 1; i >= 0; i-- {
+		list = append(list, above[i])
+	}
+	list = append(list, entry)
+	list = append(list, below...)
+	return list, rank - len(above), nil
+}
+
+func (sqlRanker) Better(board model.Leaderboard, p period, score float64, distinct bool) (int, error) {
+	query := periodEntries(config.DB, board, p).Where("score "+betterOp(board.SortOrder)+" ?", score)
+	if distinct {
+		query = query.Distinct("score")
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+func (sqlRanker) Set(board model.Leaderboard, entries ...model.LeaderboardEntry) {}
+
+func (sqlRanker) Move(board model.Leaderboard, entries ...model.LeaderboardEntry) {}
+
+func (sqlRanker) Drop(board model.Leaderboard, p period) {}
+
+// storeRanker keeps a ranked set per live board period, and per country of it, in a ranking.Store.
+// Archived periods are answered by the SQL fallback.
+// With the memory store each process keeps its own sets, so every instance must receive the writes through Set.
+// A shared store is written through whether this process has loaded the period or not, and a set another
+// instance dropped is loaded again on the next read.
+type storeRanker struct {
+	mu      sync.Mutex
+	store   ranking.Store
+	shared  bool
+	indexes map[string]*periodIndex
+	sql     sqlRanker
+}
+
+// periodIndex tracks a period this process has loaded into the store with the update time of every
+// ranked entry
+type periodIndex struct {
+	mu      sync.RWMutex
+	key     string
+	order   ranking.Order
+	updated map[uint]time.Time
+}
+
+// newStoreRanker keeps the sets in store, shared tells it other instances read and write the store too
+func newStoreRanker(store ranking.Store, shared bool) *storeRanker {
+	return &storeRanker{store: store, shared: shared, indexes: make(map[string]*periodIndex)}
+}
+
+func (r *storeRanker) Rank(board model.Leaderboard, p period, entry model.LeaderboardEntry) (int, error) {
+	if !indexed(p) {
+		return r.sql.Rank(board, p, entry)
+	}
+	index, err := r.period(board, p)
+	if err != nil {
+		return 0, err
+	}
+	index.mu.RLock()
+	rank, err := r.store.Rank(index.key, entry.ID)
+	index.mu.RUnlock()
+	if err != nil {
+		return 0, err
+	}
+	if rank == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return rank, nil
+}
+
+func (r *storeRanker) Count(board model.Leaderboard, p period) (int, error) {
+	if !indexed(p) {
+		return r.sql.Count(board, p)
+	}
+	index, err := r.period(board, p)
+	if err != nil {
+		return 0, err
+	}
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return r.store.Count(index.key)
+}
+
+func (r *storeRanker) Top(board model.Leaderboard, p period, limit int) ([]model.LeaderboardEntry, error) {
+	if !indexed(p) {
+		return r.sql.Top(board, p, limit)
+	}
+	return r.slice(board, p, 1, limit)
+}
+
+func (r *storeRanker) At(board model.Leaderboard, p period, rank int) (model.LeaderboardEntry, error) {
+	if !indexed(p) {
+		return r.sql.At(board, p, rank)
+	}
+	entries, err := r.slice(board, p, rank, 1)
+	if err != nil {
+		return model.LeaderboardEntry{}, err
+	}
+	if len(entries) == 0 {
+		return model.LeaderboardEntry{}, gorm.ErrRecordNotFound
+	}
+	return entries[0], nil
+}
+
+func (r *storeRanker) Around(board model.Leaderboard, p period, entry model.LeaderboardEntry, k int) ([]model.LeaderboardEntry, int, error) {
+	if !indexed(p) {
+		return r.sql.Around(board, p, entry, k)
+	}
+	rank, err := r.Rank(board, p, entry)
+	if err != nil {
+		return nil, 0, err
+	}
+	first := max(rank-k, 1)
+	entries, err := r.slice(board, p, first, rank+k-first+1)
+	return entries, first, err
+}
+
+func (r *storeRanker) Better(board model.Leaderboard, p period, score float64, distinct bool) (int, error) {
+	// the store does not know how many distinct scores a set holds
+	if !indexed(p) || distinct {
+		return r.sql.Better(board, p, score, distinct)
+	}
+	index, err := r.period(board, p)
+	if err != nil {
+		return 0, err
+	}
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return r.store.Better(index.key, score)
+}
+
+func (r *storeRanker) Set(board model.Leaderboard, entries ...model.LeaderboardEntry) {
+	for _, entry := range entries {
+		p := entryPeriod(entry)
+		r.write(board, p, entry)
+		if entry.Country != "" {
+			p.Country = entry.Country
+			r.write(board, p, entry)
+		}
+	}
+}
+
+func (r *storeRanker) Move(board model.Leaderboard, entries ...model.LeaderboardEntry) {
+	for _, entry := range entries {
+		// take the entry out of the sets of the countries it was filed under before
+		prefix := periodKey(board, entryPeriod(entry)) + "/"
+		keys, err := r.countryKeys(prefix)
+		if err != nil {
+			fmt.Println("Error updating the rank index:", err)
+		}
+		for _, key := range keys {
+			if key != prefix+entry.Country {
+				r.remove(key, entry.ID)
+			}
+		}
+	}
+	r.Set(board, entries...)
+}
+
+// write records an entry in the set of a period. A process that has not loaded the period leaves it
+// alone unless the store is shared, periods nobody has read yet are loaded with the entry already in them.
+func (r *storeRanker) write(board model.Leaderboard, p period, entry model.LeaderboardEntry) {
+	key := periodKey(board, p)
+	r.mu.Lock()
+	index, ok := r.indexes[key]
+	r.mu.Unlock()
+	if !ok {
+		if r.shared {
+			r.put(key, rankSetOrder(board), entry)
+		}
+		return
+	}
+
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	// writes can commit in one order and reach the index in another, keep the newest
+	if last, ok := index.updated[entry.ID]; ok && entry.UpdatedAt.Before(last) {
+		return
+	}
+	if r.put(index.key, index.order, entry) {
+		index.updated[entry.ID] = entry.UpdatedAt
+	}
+}
+
+// put writes an entry into the set under key and reports if it did. A shared set missing from the
+// store was dropped or never loaded, it is left for the next read to load.
+func (r *storeRanker) put(key string, order ranking.Order, entry model.LeaderboardEntry) bool {
+	if r.shared {
+		exists, err := r.store.Exists(key)
+		if err != nil || !exists {
+			r.discard(key, err)
+			return false
+		}
+	}
+	var err error
+	// hidden entries only rank for their player, the SQL fallback places them
+	if entry.Hidden {
+		err = r.store.Remove(key, entry.ID)
+	} else {
+		err = r.store.Add(key, order, toRankItem(entry))
+	}
+	if err != nil {
+		r.discard(key, err)
+		return false
+	}
+	return true
+}
+
+// remove takes an entry out of the set under key
+func (r *storeRanker) remove(key string, id uint) {
+	r.mu.Lock()
+	index, ok := r.indexes[key]
+	r.mu.Unlock()
+	if !ok {
+		if r.shared {
+			if err := r.store.Remove(key, id); err != nil {
+				r.discard(key, err)
+			}
+		}
+		return
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if err := r.store.Remove(key, id); err != nil {
+		r.discard(key, err)
+		return
+	}
+	delete(index.updated, id)
+}
+
+// discard gives up on a set that missed a write, the next read loads it again. A shared set is
+// deleted so every instance loads it again.
+func (r *storeRanker) discard(key string, err error) {
+	r.forget(key)
+	if err == nil {
+		return
+	}
+	fmt.Println("Error updating the rank index:", err)
+	if r.shared {
+		if err := r.store.Delete(key); err != nil {
+			fmt.Println("Error dropping the rank index of a period:", err)
+		}
+	}
+}
+
+// countryKeys lists the country sets of a period under the prefix, those in the store when it is
+// shared and those this process loaded otherwise
+func (r *storeRanker) countryKeys(prefix string) ([]string, error) {
+	if r.shared {
+		return r.store.Keys(prefix)
+	}
+	var keys []string
+	r.mu.Lock()
+	for key := range r.indexes {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	r.mu.Unlock()
+	return keys, nil
+}
+
+// Drop forgets the period with the sets of its countries, in every instance when the store is shared
+func (r *storeRanker) Drop(board model.Leaderboard, p period) {
+	key := periodKey(board, p)
+	countries, err := r.countryKeys(key + "/")
+	if err != nil {
+		fmt.Println("Error dropping the rank index of a period:", err)
+	}
+	for _, key := range append([]string{key}, countries...) {
+		r.forget(key)
+		if err := r.store.Delete(key); err != nil {
+			fmt.Println("Error dropping the rank index of a period:", err)
+		}
+	}
+}
+
+func (r *storeRanker) forget(key string) {
+	r.mu.Lock()
+	delete(r.indexes, key)
+	r.mu.Unlock()
+}
+
+// period returns the index of a board period, loading its set from the DB on first use
+func (r *storeRanker) period(board model.Leaderboard, p period) (*periodIndex, error) {
+	key := periodKey(board, p)
+	r.mu.Lock()
+	index, ok := r.indexes[key]
+	if ok {
+		r.mu.Unlock()
+		// wait for a load that is still running
+		index.mu.RLock()
+		index.mu.RUnlock()
+		if !r.shared {
+			return index, nil
+		}
+		// another instance may have dropped the set since this one loaded it
+		exists, err := r.store.Exists(key)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return index, nil
+		}
+		r.forget(key)
+		return r.period(board, p)
+	}
+	index = &periodIndex{
+		key:     key,
+		order:   rankSetOrder(board),
+		updated: make(map[uint]time.Time),
+	}
+	// hold the index while it loads so writes and reads queue behind it
+	index.mu.Lock()
+	r.indexes[key] = index
+	r.mu.Unlock()
+	defer index.mu.Unlock()
+
+	if err := r.load(board, p, index); err != nil {
+		r.forget(key)
+		return nil, err
+	}
+	return index, nil
+}
+
+// load writes the ranked entries of a period into its set. A shared store can already hold the set,
+// it is refreshed in place so other instances keep reading it.
+func (r *storeRanker) load(board model.Leaderboard, p period, index *periodIndex) error {
+	if err := r.store.Add(index.key, index.order); errors.Is(err, ranking.ErrOrderMismatch) {
+		// the board changed its order since the set was written
+		if err := r.store.Delete(index.key); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	var entries []model.LeaderboardEntry
+	result := periodEntries(config.DB, board, p).
+		Select("id", "score", "metric", "submitted_at", "updated_at").
+		FindInBatches(&entries, loadBatch, func(tx *gorm.DB, batch int) error {
+			items := make([]ranking.Item, 0, len(entries))
+			for _, entry := range entries {
+				items = append(items, toRankItem(entry))
+				index.updated[entry.ID] = entry.UpdatedAt
+			}
+			return r.store.Add(index.key, index.order, items...)
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	count, err := r.store.Count(index.key)
+	if err != nil || count == len(index.updated) {
+		return err
+	}
+	items, err := r.store.RangeByRank(index.key, 1, count)
+	if err != nil {
+		return err
+	}
+	var stale []uint
+	for _, item := range items {
+		if _, ok := index.updated[item.ID]; !ok {
+			stale = append(stale, item.ID)
+		}
+	}
+	return r.store.Remove(index.key, stale...)
+}
+
+// slice reads count entries from the rank in rank order, the rows are loaded by primary key
+func (r *storeRanker) slice(board model.Leaderboard, p period, start, count int) ([]model.LeaderboardEntry, error) {
+	index, err := r.period(board, p)
+	if err != nil {
+		return nil, err
+	}
+	index.mu.RLock()
+	items, err := r.store.RangeByRank(index.key, start, count)
+	index.mu.RUnlock()
+	if err != nil || len(items) == 0 {
+		return nil, err
+	}
+
+	ids := make([]uint, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	var rows []model.LeaderboardEntry
+	if err := config.DB.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	byId := make(map[uint]model.LeaderboardEntry, len(rows))
+	for _, row := range rows {
+		byId[row.ID] = row
+	}
+	entries := make([]model.LeaderboardEntry, 0, len(items))
+	for _, item := range items {
+		if row, ok := byId[item.ID]; ok {
+			entries = append(entries, row)
+		}
+	}
+	return entries, nil
+}
+
+// isLive reports if a period can still receive scores
+func isLive(p period) bool {
+	return p.End.IsZero() || time.Now().Before(p.End)
+}
+
+// indexed reports if a period is kept in the rank store
+func indexed(p period) bool {
+	return isLive(p)
+}
+
+// periodKey names the set of a period, a country set is named after the whole period's
+func periodKey(board model.Leaderboard, p period) string {
+	key := fmt.Sprintf("%d/%s/%d", board.ID, p.Window, p.Start.Unix())
+	if p.Country != "" {
+		key += "/" + p.Country
+	}
+	return key
+}
+
+// rankSetOrder is the order the sets of a board rank by
+func rankSetOrder(board model.Leaderboard) ranking.Order {
+	return ranking.Order{Desc: board.SortOrder != SortAsc, MetricDesc: metricOrder(board) == SortDesc}
+}
+
+func toRankItem(entry model.LeaderboardEntry) ranking.Item {
+	return ranking.Item{ID: entry.ID, Score: entry.Score, Metric: entry.Metric, SubmittedAt: entry.SubmittedAt}
+}
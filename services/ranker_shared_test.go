@@ -0,0 +1,91 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/ranking"
+)
+
+// TestSharedRankStore runs two instances on one SQL store, each sees what the other writes and drops
+func TestSharedRankStore(t *testing.T) {
+	db := testDB(t)
+	board := model.Leaderboard{Name: "shared", Game: "shared", SortOrder: SortDesc}
+	if err := db.Create(&board).Error; err != nil {
+		t.Fatal(err)
+	}
+	p := period{Window: WindowAllTime, Start: allTimeStart}
+	us := period{Window: WindowAllTime, Start: allTimeStart, Country: "US"}
+	entry := func(userId string, score float64) model.LeaderboardEntry {
+		e := model.LeaderboardEntry{
+			LeaderboardId: board.ID,
+			Window:        p.Window,
+			PeriodStart:   p.Start,
+			UserId:        userId,
+			Country:       "US",
+			Score:         score,
+			Submissions:   1,
+			SubmittedAt:   time.Now().UTC(),
+		}
+		if err := db.Create(&e).Error; err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	a, b := entry("a", 10), entry("b", 20)
+
+	store := ranking.NewGormStore(db)
+	one, two := newStoreRanker(store, true), newStoreRanker(store, true)
+	expectCount := func(what string, r ranker, p period, want int) {
+		t.Helper()
+		count, err := r.Count(board, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count != want {
+			t.Errorf("%s: got count %d, want %d", what, count, want)
+		}
+	}
+	expectRank := func(what string, r ranker, e model.LeaderboardEntry, want int) {
+		t.Helper()
+		rank, err := r.Rank(board, p, e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rank != want {
+			t.Errorf("%s: got rank %d, want %d", what, rank, want)
+		}
+	}
+	expectCount("loaded", one, p, 2)
+	expectCount("loaded country", one, us, 2)
+
+	// two never read the period, its writes still reach the sets one reads
+	c := entry("c", 30)
+	two.Set(board, c)
+	expectRank("written by the other instance", one, c, 1)
+	expectCount("country written by the other instance", one, us, 3)
+	b.Hidden = true
+	b.UpdatedAt = time.Now()
+	if err := db.Save(&b).Error; err != nil {
+		t.Fatal(err)
+	}
+	two.Set(board, b)
+	expectCount("hidden by the other instance", one, p, 2)
+	expectCount("country hidden by the other instance", one, us, 2)
+
+	// a bulk rewrite drops the sets, the instance that loaded them reads them again
+	if err := db.Model(&a).Update("score", 50).Error; err != nil {
+		t.Fatal(err)
+	}
+	two.Drop(board, p)
+	keys, err := store.Keys("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Errorf("dropped sets left in the store: %v", keys)
+	}
+	expectRank("reloaded after a drop", one, a, 1)
+	expectCount("country reloaded after a drop", one, us, 2)
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/ranking"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// benchEntries is how many entries the ranker benchmarks rank
+const benchEntries = 20000
+
+// seedBenchBoard fills an in-memory DB with one board of benchEntries all time entries
+func seedBenchBoard(b *testing.B) (model.Leaderboard, period, []model.LeaderboardEntry) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		b.Fatal(err)
+	}
+	config.DB = db
+	config.SyncDB()
+
+	board := model.Leaderboard{Name: "bench", Game: "bench", SortOrder: SortDesc}
+	if err := db.Create(&board).Error; err != nil {
+		b.Fatal(err)
+	}
+	p := period{Window: WindowAllTime, Start: allTimeStart}
+	entries := make([]model.LeaderboardEntry, 0, benchEntries)
+	for i := 0; i < benchEntries; i++ {
+		entries = append(entries, model.LeaderboardEntry{
+			LeaderboardId: board.ID,
+			Window:        p.Window,
+			PeriodStart:   p.Start,
+			UserId:        fmt.Sprintf("bench-%d", i),
+			Score:         float64(rand.Intn(benchEntries * 10)),
+			Submissions:   1,
+			SubmittedAt:   p.Start.Add(time.Duration(i) * time.Millisecond),
+		})
+	}
+	if err := db.CreateInBatches(&entries, 500).Error; err != nil {
+		b.Fatal(err)
+	}
+	return board, p, entries
+}
+
+// benchRankers runs fn on the SQL ranker and on the index on the memory store
+func benchRankers(b *testing.B, fn func(b *testing.B, r ranker, board model.Leaderboard, p period, entries []model.LeaderboardEntry)) {
+	board, p, entries := seedBenchBoard(b)
+	for _, c := range []struct {
+		name string
+		r    ranker
+	}{{"sql", sqlRanker{}}, {"index", newStoreRanker(ranking.NewMemoryStore(), false)}} {
+		b.Run(c.name, func(b *testing.B) {
+			// the first read loads the index
+			if _, err := c.r.Count(board, p); err != nil {
+				b.Fatal(err)
+			}
+			b.ResetTimer()
+			fn(b, c.r, board, p, entries)
+		})
+	}
+}
+
+func BenchmarkRankerRank(b *testing.B) {
+	benchRankers(b, func(b *testing.B, r ranker, board model.Leaderboard, p period, entries []model.LeaderboardEntry) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.Rank(board, p, entries[rand.Intn(len(entries))]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkRankerAt(b *testing.B) {
+	benchRankers(b, func(b *testing.B, r ranker, board model.Leaderboard, p period, entries []model.LeaderboardEntry) {
+		for i := 0; i < b.N; i++ {
+			if _, err := r.At(board, p, rand.Intn(len(entries))+1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
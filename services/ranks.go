@@ -0,0 +1,226 @@
+package services
+
+import (
+	"math"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+// AroundUser returns the user's own entry with up to k neighbours on each side
+func (s *leaderboardservice) AroundUser(query entity.LeaderboardQuery, userId string, k int) ([]entity.LeaderboardEntry, error) {
+	board, p, err := resolveQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := findOwnEntry(config.DB, board, p, userId)
+	if err != nil {
+		return nil, err
+	}
+	// a hidden entry is not in the rank index, the query places it among the shown neighbours
+	var index ranker = ranks
+	if entry.Hidden {
+		index = sqlRanker{}
+	}
+	entries, first, err := index.Around(board, p, entry, k)
+	if err != nil {
+		return nil, err
+	}
+	return rankedEntities(board, p, entries, first)
+}
+
+// EntryAtRank returns the entry at the given position of the board, under a shared tie-break policy
+// the rank it reports can be lower than the position
+func (s *leaderboardservice) EntryAtRank(query entity.LeaderboardQuery, rank int) (entity.LeaderboardEntry, error) {
+	board, p, err := resolveQuery(query)
+	if err != nil {
+		return entity.LeaderboardEntry{}, err
+	}
+	entry, err := ranks.At(board, p, rank)
+	if err != nil {
+		return entity.LeaderboardEntry{}, err
+	}
+	list, err := rankedEntities(board, p, []model.LeaderboardEntry{entry}, rank)
+	if err != nil {
+		return entity.LeaderboardEntry{}, err
+	}
+	return list[0], nil
+}
+
+// UserRank returns the user's rank with the board size and their percentile
+func (s *leaderboardservice) UserRank(query entity.LeaderboardQuery, userId string) (entity.UserRank, error) {
+	board, p, err := resolveQuery(query)
+	if err != nil {
+		return entity.UserRank{}, err
+	}
+	entry, err := findEntry(config.DB, board, p, userId)
+	if err != nil {
+		return entity.UserRank{}, err
+	}
+	rank, err := rankOf(board, p, entry)
+	if err != nil {
+		return entity.UserRank{}, err
+	}
+	total, err := ranks.Count(board, p)
+	if err != nil {
+		return entity.UserRank{}, err
+	}
+
+	return entity.UserRank{
+		Entry:      toEntryEntity(board, entry, rank),
+		Total:      total,
+		Percentile: percentile(rank, total),
+	}, nil
+}
+
+// percentile is the share of the board ranked at or below the given rank, 100 for the leader
+func percentile(rank, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(total-rank+1)/float64(total)*10000) / 100
+}
+
+// sharesRanks reports if equal scores share a rank on the board
+func sharesRanks(board model.Leaderboard) bool {
+	return board.TieBreak == TieCompetition || board.TieBreak == TieDense
+}
+
+// tieCounter numbers the entries of a period walked in rank order from the top
+type tieCounter struct {
+	board    model.Leaderboard
+	position int
+	rank     int
+	last     float64
+	started  bool
+}
+
+// next returns the rank of the next entry, equal scores share one under the competition and dense policies
+func (c *tieCounter) next(score float64) int {
+	c.position++
+	if !c.started || score != c.last || !sharesRanks(c.board) {
+		if c.board.TieBreak == TieDense {
+			c.rank++
+		} else {
+			c.rank = c.position
+		}
+	}
+	c.started = true
+	c.last = score
+	return c.rank
+}
+
+// tieRanks numbers entries read in rank order starting at the given position, the rank of the
+// first one is counted from the scores above it when ties are shared
+func tieRanks(board model.Leaderboard, p period, entries []model.LeaderboardEntry, position int) ([]int, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	rank := position
+	if sharesRanks(board) {
+		better, err := ranks.Better(board, p, entries[0].Score, board.TieBreak == TieDense)
+		if err != nil {
+			return nil, err
+		}
+		rank = better + 1
+	}
+
+	counter := tieCounter{board: board, position: position, rank: rank, last: entries[0].Score, started: true}
+	numbers := make([]int, len(entries))
+	numbers[0] = rank
+	for i := 1; i < len(entries); i++ {
+		numbers[i] = counter.next(entries[i].Score)
+	}
+	return numbers, nil
+}
+
+// rankOf returns the rank of an entry in a period under the board's tie-break policy
+func rankOf(board model.Leaderboard, p period, entry model.LeaderboardEntry) (int, error) {
+	position, err := ranks.Rank(board, p, entry)
+	if err != nil {
+		return 0, err
+	}
+	numbers, err := tieRanks(board, p, []model.LeaderboardEntry{entry}, position)
+	if err != nil {
+		return 0, err
+	}
+	return numbers[0], nil
+}
+
+// rankedEntities converts entries read in rank order from the given position
+func rankedEntities(board model.Leaderboard, p period, entries []model.LeaderboardEntry, position int) ([]entity.LeaderboardEntry, error) {
+	numbers, err := tieRanks(board, p, entries, position)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]entity.LeaderboardEntry, 0, len(entries))
+	for i, entry := range entries {
+		list = append(list, toEntryEntity(board, entry, numbers[i]))
+	}
+	return list, nil
+}
+
+// resolveQuery loads the board of a read and the period it targets
+func resolveQuery(query entity.LeaderboardQuery) (model.Leaderboard, period, error) {
+	board, err := findLeaderboard(config.DB, query.LeaderboardId)
+	if err != nil {
+		return model.Leaderboard{}, period{}, err
+	}
+	p, err := queryPeriod(config.DB, board, query)
+	if err != nil {
+		return model.Leaderboard{}, period{}, err
+	}
+	return board, p, nil
+}
+
+// periodEntries scopes a query to the ranked entries of one board period, the entries hidden by a
+// shadow ban are left out
+func periodEntries(db *gorm.DB, board model.Leaderboard, p period) *gorm.DB {
+	return periodScope(db, board, p).Where("hidden = ?", false)
+}
+
+// periodScope scopes a query to every entry of one board period, hidden ones included
+func periodScope(db *gorm.DB, board model.Leaderboard, p period) *gorm.DB {
+	db = db.Model(&model.LeaderboardEntry{}).
+		Where("leaderboard_id = ? AND time_window = ? AND period_start = ?", board.ID, p.Window, p.Start)
+	if p.Country != "" {
+		db = db.Where("country = ?", p.Country)
+	}
+	return db
+}
+
+// entryPeriod is the whole period an entry belongs to
+func entryPeriod(entry model.LeaderboardEntry) period {
+	return period{Window: entry.Window, Start: entry.PeriodStart}
+}
+
+// findEntry loads a user's entry of a period
+func findEntry(db *gorm.DB, board model.Leaderboard, p period, userId string) (model.LeaderboardEntry, error) {
+	var entry model.LeaderboardEntry
+	result := periodEntries(db, board, p).Where("user_id = ?", userId).First(&entry)
+	if result.Error != nil {
+		return model.LeaderboardEntry{}, result.Error
+	}
+	return entry, nil
+}
+
+// findOwnEntry loads the entry a user sees of their own, it is found even when a shadow ban hides it
+func findOwnEntry(db *gorm.DB, board model.Leaderboard, p period, userId string) (model.LeaderboardEntry, error) {
+	var entry model.LeaderboardEntry
+	result := periodScope(db, board, p).Where("user_id = ?", userId).First(&entry)
+	if result.Error != nil {
+		return model.LeaderboardEntry{}, result.Error
+	}
+	return entry, nil
+}
+
+// ownRank is the rank a user sees for their own entry, a hidden entry is placed among the shown ones
+// as if it was on the board
+func ownRank(board model.Leaderboard, p period, entry model.LeaderboardEntry) (int, error) {
+	if entry.Hidden {
+		return entryRank(config.DB, board, p, entry)
+	}
+	return ranks.Rank(board, p, entry)
+}
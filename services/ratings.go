@@ -0,0 +1,414 @@
+package services
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/rating"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Rating algorithms
+const (
+	AlgorithmGlicko2 = "glicko2"
+	AlgorithmElo     = "elo"
+)
+
+// Match results a participant can send instead of a placement
+const (
+	ResultWin  = "win"
+	ResultLoss = "loss"
+	ResultDraw = "draw"
+)
+
+const (
+	// initialRating, initialDeviation and initialVolatility are where a new player starts
+	initialRating     = 1500
+	initialDeviation  = 350
+	initialVolatility = 0.06
+	// maxParticipants is how many players one match may rate
+	maxParticipants = 100
+)
+
+// RatingService is an interface for skill rating boards fed by match results
+type RatingService interface {
+	CreateRatingBoard(board entity.RatingBoard) (entity.RatingBoard, error)
+	ListRatingBoards() ([]entity.RatingBoard, error)
+	RecordMatch(result entity.MatchResult) (entity.Match, error)
+	TopRatings(ratingBoardId uint, limit int) ([]entity.PlayerRating, error)
+	UserRating(ratingBoardId uint, userId string) (entity.PlayerRating, error)
+}
+
+// ratingservice is an implementation of RatingService
+type ratingservice struct{}
+
+// NewRatingService creates and returns a new instance of RatingService
+func NewRatingService() RatingService {
+	return &ratingservice{}
+}
+
+// CreateRatingBoard validates the rating settings and saves them
+func (s *ratingservice) CreateRatingBoard(board entity.RatingBoard) (entity.RatingBoard, error) {
+	if board.Algorithm == "" {
+		board.Algorithm = AlgorithmGlicko2
+	}
+	if board.KFactor == 0 {
+		board.KFactor = 32
+	}
+	if board.Tau == 0 {
+		board.Tau = 0.5
+	}
+	if board.PeriodHours == 0 {
+		board.PeriodHours = 24
+	}
+	if err := validateRatingBoard(board); err != nil {
+		return entity.RatingBoard{}, err
+	}
+
+	newBoard := model.RatingBoard{
+		Name:        board.Name,
+		Game:        board.Game,
+		Algorithm:   board.Algorithm,
+		KFactor:     board.KFactor,
+		Tau:         board.Tau,
+		PeriodHours: board.PeriodHours,
+	}
+	if err := config.DB.Create(&newBoard).Error; err != nil {
+		return entity.RatingBoard{}, err
+	}
+	return toRatingBoardEntity(newBoard), nil
+}
+
+// ListRatingBoards returns every rating board
+func (s *ratingservice) ListRatingBoards() ([]entity.RatingBoard, error) {
+	var boards []model.RatingBoard
+	if err := config.DB.Order("id").Find(&boards).Error; err != nil {
+		return nil, err
+	}
+	list := make([]entity.RatingBoard, 0, len(boards))
+	for _, board := range boards {
+		list = append(list, toRatingBoardEntity(board))
+	}
+	return list, nil
+}
+
+// RecordMatch rates the participants of a finished match. Every participant plays every opponent on
+// another team, the rows of all of them are locked so concurrent matches of a player apply one after another.
+func (s *ratingservice) RecordMatch(result entity.MatchResult) (entity.Match, error) {
+	placements, err := matchPlacements(result.Participants)
+	if err != nil {
+		return entity.Match{}, err
+	}
+	board, err := findRatingBoard(config.DB, result.RatingBoardId)
+	if err != nil {
+		return entity.Match{}, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	match := model.Match{RatingBoardId: board.ID}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		players, err := lockRatings(tx, board.ID, result.Participants)
+		if err != nil {
+			return err
+		}
+
+		// every rating is computed from the ratings before the match
+		before := make([]rating.Rating, len(result.Participants))
+		for i, p := range result.Participants {
+			before[i] = currentRating(board, players[p.UserId], now)
+		}
+		for i, p := range result.Participants {
+			var results []rating.Result
+			for j, o := range result.Participants {
+				if i == j || (p.Team != "" && p.Team == o.Team) {
+					continue
+				}
+				results = append(results, rating.Result{Opponent: before[j], Score: outcome(placements[i], placements[j])})
+			}
+
+			after := rating.Glicko2(before[i], results, board.Tau)
+			if board.Algorithm == AlgorithmElo {
+				after = rating.Elo(before[i], results, board.KFactor)
+			}
+			player := players[p.UserId]
+			player.Rating = after.Rating
+			player.Deviation = after.Deviation
+			player.Volatility = after.Volatility
+			player.Matches++
+			switch {
+			case placements[i] == 1 && slices.Max(placements) == 1:
+				player.Draws++
+			case placements[i] == 1:
+				player.Wins++
+			default:
+				player.Losses++
+			}
+			player.LastPlayedAt = &now
+			if err := tx.Save(player).Error; err != nil {
+				return err
+			}
+
+			match.Participants = append(match.Participants, model.MatchParticipant{
+				UserId:          p.UserId,
+				Team:            p.Team,
+				Placement:       placements[i],
+				RatingBefore:    before[i].Rating,
+				RatingAfter:     after.Rating,
+				DeviationBefore: before[i].Deviation,
+				DeviationAfter:  after.Deviation,
+			})
+		}
+		return tx.Create(&match).Error
+	})
+	if err != nil {
+		return entity.Match{}, err
+	}
+	return toMatchEntity(match), nil
+}
+
+// TopRatings returns the highest rated players of a board
+func (s *ratingservice) TopRatings(ratingBoardId uint, limit int) ([]entity.PlayerRating, error) {
+	board, err := findRatingBoard(config.DB, ratingBoardId)
+	if err != nil {
+		return nil, err
+	}
+	var players []model.PlayerRating
+	result := config.DB.Where("rating_board_id = ?", board.ID).
+		Order("rating desc, id").Limit(limit).Find(&players)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	now := time.Now()
+	list := make([]entity.PlayerRating, 0, len(players))
+	for i, player := range players {
+		entry := toPlayerRatingEntity(board, player, now)
+		entry.Position = i + 1
+		list = append(list, entry)
+	}
+	return list, nil
+}
+
+// UserRating returns a player's rating on a board with their position
+func (s *ratingservice) UserRating(ratingBoardId uint, userId string) (entity.PlayerRating, error) {
+	board, err := findRatingBoard(config.DB, ratingBoardId)
+	if err != nil {
+		return entity.PlayerRating{}, err
+	}
+	var player model.PlayerRating
+	result := config.DB.Where("rating_board_id = ? AND user_id = ?", board.ID, userId).First(&player)
+	if result.Error != nil {
+		return entity.PlayerRating{}, result.Error
+	}
+	var better int64
+	result = config.DB.Model(&model.PlayerRating{}).
+		Where("rating_board_id = ? AND (rating > ? OR (rating = ? AND id < ?))", board.ID, player.Rating, player.Rating, player.ID).
+		Count(&better)
+	if result.Error != nil {
+		return entity.PlayerRating{}, result.Error
+	}
+	entry := toPlayerRatingEntity(board, player, time.Now())
+	entry.Position = int(better) + 1
+	return entry, nil
+}
+
+// lockRatings returns the rating rows of the participants locked for update, new players get a row first.
+// Rows are created and locked in user id order so two matches of the same players cannot deadlock.
+func lockRatings(tx *gorm.DB, boardId uint, participants []entity.MatchParticipant) (map[string]*model.PlayerRating, error) {
+	userIds := make([]string, 0, len(participants))
+	for _, p := range participants {
+		userIds = append(userIds, p.UserId)
+	}
+	slices.Sort(userIds)
+
+	for _, userId := range userIds {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.PlayerRating{
+			RatingBoardId: boardId,
+			UserId:        userId,
+			Rating:        initialRating,
+			Deviation:     initialDeviation,
+			Volatility:    initialVolatility,
+		})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+	}
+	var rows []model.PlayerRating
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("rating_board_id = ? AND user_id IN ?", boardId, userIds).
+		Order("user_id").Find(&rows)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	players := make(map[string]*model.PlayerRating, len(rows))
+	for i := range rows {
+		players[rows[i].UserId] = &rows[i]
+	}
+	return players, nil
+}
+
+// currentRating is a player's rating at now, the deviation grows for every rating period they sat out
+func currentRating(board model.RatingBoard, player *model.PlayerRating, now time.Time) rating.Rating {
+	r := rating.Rating{Rating: player.Rating, Deviation: player.Deviation, Volatility: player.Volatility}
+	if board.Algorithm != AlgorithmGlicko2 || player.LastPlayedAt == nil {
+		return r
+	}
+	periods := int(now.Sub(*player.LastPlayedAt) / (time.Duration(board.PeriodHours) * time.Hour))
+	return rating.Decay(r, periods, initialDeviation)
+}
+
+// matchPlacements checks the participants of a match and returns their placements, 1 is the best
+func matchPlacements(participants []entity.MatchParticipant) ([]int, error) {
+	if len(participants) < 2 || len(participants) > maxParticipants {
+		return nil, invalidf("a match must have between 2 and %d participants", maxParticipants)
+	}
+	byResult := participants[0].Result != ""
+	teams := participants[0].Team != ""
+	seen := make(map[string]bool, len(participants))
+	placements := make([]int, len(participants))
+	for i, p := range participants {
+		if seen[p.UserId] {
+			return nil, invalid("a player can only take part in a match once")
+		}
+		seen[p.UserId] = true
+		if (p.Result != "") != byResult {
+			return nil, invalid("participants must all send a placement or all send a result")
+		}
+		if (p.Team != "") != teams {
+			return nil, invalid("participants must all have a team or none")
+		}
+
+		switch {
+		case !byResult && p.Placement >= 1:
+			placements[i] = p.Placement
+		case !byResult:
+			return nil, invalid("placement must be at least 1")
+		case p.Result == ResultWin || p.Result == ResultDraw:
+			placements[i] = 1
+		case p.Result == ResultLoss:
+			placements[i] = 2
+		default:
+			return nil, invalid("result must be win, loss or draw")
+		}
+	}
+	if byResult {
+		draw := slices.ContainsFunc(participants, func(p entity.MatchParticipant) bool { return p.Result == ResultDraw })
+		win := slices.ContainsFunc(participants, func(p entity.MatchParticipant) bool { return p.Result == ResultWin })
+		if draw && (win || slices.Contains(placements, 2)) {
+			return nil, invalid("a drawn match has a draw result for every participant")
+		}
+	}
+	if teams {
+		// teammates must share a placement and there must be someone to play against
+		placed := make(map[string]int)
+		for i, p := range participants {
+			if at, ok := placed[p.Team]; ok && at != placements[i] {
+				return nil, invalidf("team %s has participants with different placements", p.Team)
+			}
+			placed[p.Team] = placements[i]
+		}
+		if len(placed) < 2 {
+			return nil, invalid("a team match needs at least two teams")
+		}
+	}
+	return placements, nil
+}
+
+// outcome is the score of a placement against another, lower placements win
+func outcome(placement, opponent int) float64 {
+	switch {
+	case placement < opponent:
+		return 1
+	case placement == opponent:
+		return 0.5
+	}
+	return 0
+}
+
+// findRatingBoard finds a rating board by id
+func findRatingBoard(db *gorm.DB, id uint) (model.RatingBoard, error) {
+	var board model.RatingBoard
+	if err := db.First(&board, id).Error; err != nil {
+		return model.RatingBoard{}, err
+	}
+	return board, nil
+}
+
+// validateRatingBoard checks the settings of a rating board
+func validateRatingBoard(board entity.RatingBoard) error {
+	if strings.TrimSpace(board.Name) == "" || len(board.Name) > 100 || len(board.Game) > 50 {
+		return invalid("name must be between 1 and 100 characters and game at most 50")
+	}
+	if board.Algorithm != AlgorithmGlicko2 && board.Algorithm != AlgorithmElo {
+		return invalid("algorithm must be glicko2 or elo")
+	}
+	if board.KFactor <= 0 || board.KFactor > 100 {
+		return invalid("k_factor must be above 0 and at most 100")
+	}
+	if board.Tau < 0.2 || board.Tau > 1.2 {
+		return invalid("tau must be between 0.2 and 1.2")
+	}
+	if board.PeriodHours < 1 || board.PeriodHours > 24*90 {
+		return invalid("period_hours must be between 1 and 2160")
+	}
+	return nil
+}
+
+func toRatingBoardEntity(board model.RatingBoard) entity.RatingBoard {
+	return entity.RatingBoard{
+		ID:          board.ID,
+		Name:        board.Name,
+		Game:        board.Game,
+		Algorithm:   board.Algorithm,
+		KFactor:     board.KFactor,
+		Tau:         board.Tau,
+		PeriodHours: board.PeriodHours,
+	}
+}
+
+// toPlayerRatingEntity shows the deviation a player has now, not the one of their last match
+func toPlayerRatingEntity(board model.RatingBoard, player model.PlayerRating, now time.Time) entity.PlayerRating {
+	current := currentRating(board, &player, now)
+	return entity.PlayerRating{
+		UserId:       player.UserId,
+		Rating:       round(current.Rating),
+		Deviation:    round(current.Deviation),
+		Volatility:   current.Volatility,
+		Matches:      player.Matches,
+		Wins:         player.Wins,
+		Losses:       player.Losses,
+		Draws:        player.Draws,
+		LastPlayedAt: player.LastPlayedAt,
+	}
+}
+
+func toMatchEntity(match model.Match) entity.Match {
+	participants := make([]entity.MatchParticipant, 0, len(match.Participants))
+	for _, p := range match.Participants {
+		participants = append(participants, entity.MatchParticipant{
+			UserId:          p.UserId,
+			Team:            p.Team,
+			Placement:       p.Placement,
+			RatingBefore:    round(p.RatingBefore),
+			RatingAfter:     round(p.RatingAfter),
+			DeviationBefore: round(p.DeviationBefore),
+			DeviationAfter:  round(p.DeviationAfter),
+		})
+	}
+	return entity.Match{
+		ID:            match.ID,
+		RatingBoardId: match.RatingBoardId,
+		Participants:  participants,
+		CreatedAt:     match.CreatedAt,
+	}
+}
+
+// round keeps two decimals of a rating for display
+func round(x float64) float64 {
+	return math.Round(x*100) / 100
+}
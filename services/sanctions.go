@@ -0,0 +1,749 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Moderation actions kept in the audit log
+const (
+	ActionRemove    = "remove_entries"
+	ActionRestore   = "restore_entries"
+	ActionBan       = "ban"
+	ActionShadowBan = "shadow_ban"
+	ActionLiftBan   = "lift_ban"
+)
+
+// systemModerator is the moderator of the actions taken on their own, such as lifting an expired ban
+const systemModerator = "system"
+
+var (
+	// ErrAlreadyRestored is returned when a removal is restored twice
+	ErrAlreadyRestored = errors.New("the removal was already restored")
+	// ErrAlreadyLifted is returned when a ban is lifted twice
+	ErrAlreadyLifted = errors.New("the ban was already lifted")
+)
+
+// BannedError is returned for a score sent by a player banned from the board
+type BannedError struct {
+	Reason    string
+	ExpiresAt *time.Time
+}
+
+func (e *BannedError) Error() string {
+	msg := "banned from the leaderboard: " + e.Reason
+	if e.ExpiresAt != nil {
+		msg += " until " + e.ExpiresAt.UTC().Format(time.RFC3339)
+	}
+	return msg
+}
+
+// RemoveEntries takes a player's entries off a board, or every board, in all windows and closed season
+// standings. The ranks below them move up, the removed rows are kept so the removal can be restored.
+func (s *moderationservice) RemoveEntries(moderatorId string, req entity.NewRemoval) (entity.Removal, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return entity.Removal{}, invalid("a reason is required to remove entries")
+	}
+	boards, err := sanctionBoards(req.UserId, req.LeaderboardId)
+	if err != nil {
+		return entity.Removal{}, err
+	}
+
+	removal := model.Removal{
+		LeaderboardId: boardScope(req.LeaderboardId),
+		UserId:        req.UserId,
+		Reason:        req.Reason,
+		RemovedBy:     moderatorId,
+	}
+	var removed int
+	touched := map[uint][]period{}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&removal).Error; err != nil {
+			return err
+		}
+		for _, board := range boards {
+			n, periods, err := removeBoardEntries(tx, removal, board)
+			if err != nil {
+				return err
+			}
+			removed += n
+			touched[board.ID] = periods
+			if err := updateTeamScores(tx, board, req.UserId, periods); err != nil {
+				return err
+			}
+		}
+		if removed == 0 {
+			return invalid("the player has no entries to remove")
+		}
+		return audit(tx, moderatorId, ActionRemove, req.UserId, removal.LeaderboardId, removal.ID, req.Reason)
+	})
+	if err != nil {
+		return entity.Removal{}, err
+	}
+	dropPeriods(touched)
+	return toRemovalEntity(removal, removed), nil
+}
+
+// RestoreEntries puts the entries of a removal back. A player who scored again since gets the removed
+// scores folded into their entries the way the board aggregates them.
+func (s *moderationservice) RestoreEntries(removalId uint, moderatorId string, reason string) (entity.Removal, error) {
+	var removal model.Removal
+	var restored int
+	touched := map[uint][]period{}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&removal, removalId).Error; err != nil {
+			return err
+		}
+		if removal.RestoredAt != nil {
+			return ErrAlreadyRestored
+		}
+		var rows []model.RemovedEntry
+		if err := tx.Where("removal_id = ?", removal.ID).Order("id").Find(&rows).Error; err != nil {
+			return err
+		}
+		restored = len(rows)
+		// the standings removed with the entry of a season that had already closed come back on their own
+		removedStandings := map[uint]bool{}
+		for _, row := range rows {
+			if row.SeasonId != nil {
+				removedStandings[*row.SeasonId] = true
+			}
+		}
+
+		boards := map[uint]model.Leaderboard{}
+		seasons := map[uint]uint{}
+		for _, row := range rows {
+			board, ok := boards[row.LeaderboardId]
+			if !ok {
+				var err error
+				if board, err = findLeaderboard(tx, row.LeaderboardId); err != nil {
+					return err
+				}
+				boards[board.ID] = board
+			}
+			if row.SeasonId != nil {
+				standing := model.SeasonStanding{
+					SeasonId:    *row.SeasonId,
+					Position:    row.Position,
+					UserId:      removal.UserId,
+					Score:       row.Score,
+					SubmittedAt: row.SubmittedAt,
+				}
+				if err := tx.Create(&standing).Error; err != nil {
+					return err
+				}
+				seasons[*row.SeasonId] = board.ID
+				continue
+			}
+			// a season closed since the removal is ranked from its standings now
+			if row.Window == WindowSeason {
+				seasonId, closed, err := closedSeasonAt(tx, board, row.PeriodStart, removal.UserId)
+				if err != nil {
+					return err
+				}
+				if closed && !removedStandings[seasonId] {
+					if err := restoreStanding(tx, board, seasonId, removal.UserId, row); err != nil {
+						return err
+					}
+					seasons[seasonId] = board.ID
+					continue
+				}
+			}
+			p := period{Window: row.Window, Start: row.PeriodStart}
+			if err := restoreEntry(tx, board, p, removal.UserId, row); err != nil {
+				return err
+			}
+			touched[board.ID] = append(touched[board.ID], p)
+		}
+		for seasonId, boardId := range seasons {
+			if err := renumberStandings(tx, boards[boardId], seasonId); err != nil {
+				return err
+			}
+		}
+		for boardId, periods := range touched {
+			if err := updateTeamScores(tx, boards[boardId], removal.UserId, periods); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now().UTC()
+		removal.RestoredBy = moderatorId
+		removal.RestoredAt = &now
+		if err := tx.Save(&removal).Error; err != nil {
+			return err
+		}
+		return audit(tx, moderatorId, ActionRestore, removal.UserId, removal.LeaderboardId, removal.ID, reason)
+	})
+	if err != nil {
+		return entity.Removal{}, err
+	}
+	dropPeriods(touched)
+	return toRemovalEntity(removal, restored), nil
+}
+
+// ListRemovals returns the removals of a player's entries, newest first
+func (s *moderationservice) ListRemovals(userId string) ([]entity.Removal, error) {
+	var removals []model.Removal
+	if err := config.DB.Where("user_id = ?", userId).Order("id desc").Find(&removals).Error; err != nil {
+		return nil, err
+	}
+	counts := map[uint]int{}
+	if len(removals) > 0 {
+		ids := make([]uint, 0, len(removals))
+		for _, r := range removals {
+			ids = append(ids, r.ID)
+		}
+		var rows []struct {
+			RemovalId uint
+			Count     int
+		}
+		result := config.DB.Model(&model.RemovedEntry{}).
+			Select("removal_id, count(*) AS count").
+			Where("removal_id IN ?", ids).
+			Group("removal_id").
+			Scan(&rows)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		for _, row := range rows {
+			counts[row.RemovalId] = row.Count
+		}
+	}
+	list := make([]entity.Removal, 0, len(removals))
+	for _, r := range removals {
+		list = append(list, toRemovalEntity(r, counts[r.ID]))
+	}
+	return list, nil
+}
+
+// Ban stops a player from submitting to a board, or every board, until the ban expires or is lifted.
+// A shadow ban keeps taking the scores but hides the player's entries from everyone else.
+func (s *moderationservice) Ban(moderatorId string, req entity.NewBan) (entity.Ban, error) {
+	if strings.TrimSpace(req.Reason) == "" {
+		return entity.Ban{}, invalid("a reason is required to ban a player")
+	}
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return entity.Ban{}, invalid("expires_at must be in the future")
+	}
+	if _, err := sanctionBoards(req.UserId, req.LeaderboardId); err != nil {
+		return entity.Ban{}, err
+	}
+
+	ban := model.Ban{
+		LeaderboardId: boardScope(req.LeaderboardId),
+		UserId:        req.UserId,
+		Reason:        req.Reason,
+		Shadow:        req.Shadow,
+		ExpiresAt:     req.ExpiresAt,
+		BannedBy:      moderatorId,
+	}
+	var touched map[uint][]period
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&ban).Error; err != nil {
+			return err
+		}
+		action := ActionBan
+		if ban.Shadow {
+			action = ActionShadowBan
+			var err error
+			if touched, err = hideEntries(tx, ban, true); err != nil {
+				return err
+			}
+		}
+		return audit(tx, moderatorId, action, ban.UserId, ban.LeaderboardId, ban.ID, ban.Reason)
+	})
+	if err != nil {
+		return entity.Ban{}, err
+	}
+	dropPeriods(touched)
+	return toBanEntity(ban), nil
+}
+
+// LiftBan ends a ban, the entries a shadow ban hid are shown again
+func (s *moderationservice) LiftBan(banId uint, moderatorId string, reason string) (entity.Ban, error) {
+	var ban model.Ban
+	var touched map[uint][]period
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&ban, banId).Error; err != nil {
+			return err
+		}
+		if ban.LiftedAt != nil {
+			return ErrAlreadyLifted
+		}
+		var err error
+		touched, err = liftBan(tx, &ban, moderatorId, reason)
+		return err
+	})
+	if err != nil {
+		return entity.Ban{}, err
+	}
+	dropPeriods(touched)
+	return toBanEntity(ban), nil
+}
+
+// ListBans returns the bans of a player, newest first
+func (s *moderationservice) ListBans(userId string) ([]entity.Ban, error) {
+	var bans []model.Ban
+	if err := config.DB.Where("user_id = ?", userId).Order("id desc").Find(&bans).Error; err != nil {
+		return nil, err
+	}
+	list := make([]entity.Ban, 0, len(bans))
+	for _, ban := range bans {
+		list = append(list, toBanEntity(ban))
+	}
+	return list, nil
+}
+
+// LiftExpiredBans lifts the bans past their expiry so the entries of expired shadow bans show again
+func (s *moderationservice) LiftExpiredBans() error {
+	var bans []model.Ban
+	if err := config.DB.Where("lifted_at IS NULL AND expires_at <= ?", time.Now()).Find(&bans).Error; err != nil {
+		return err
+	}
+	for _, ban := range bans {
+		var touched map[uint][]period
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			// a moderator may have lifted it since it was read
+			result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("lifted_at IS NULL").Find(&ban, ban.ID)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+			var err error
+			touched, err = liftBan(tx, &ban, systemModerator, "expired")
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("lifting ban %d: %w", ban.ID, err)
+		}
+		dropPeriods(touched)
+	}
+	return nil
+}
+
+// AuditLog returns the moderation actions, newest first, only those taken on a player when one is given
+func (s *moderationservice) AuditLog(userId string, page entity.PageQuery) (entity.AuditPage, error) {
+	pg, err := newPager("audit:"+userId, page.Cursor, page.Limit)
+	if err != nil {
+		return entity.AuditPage{}, err
+	}
+	query := config.DB.Model(&model.AuditEntry{})
+	if userId != "" {
+		query = query.Where("user_id = ?", userId)
+	}
+	var entries []model.AuditEntry
+	if err := pg.byId(query, true).Find(&entries).Error; err != nil {
+		return entity.AuditPage{}, err
+	}
+	more := len(entries) > pg.limit
+	if more {
+		entries = entries[:pg.limit]
+	}
+	if pg.back() {
+		slices.Reverse(entries)
+	}
+
+	res := entity.AuditPage{Entries: make([]entity.AuditEntry, 0, len(entries))}
+	for _, e := range entries {
+		res.Entries = append(res.Entries, entity.AuditEntry{
+			ID:            e.ID,
+			ModeratorId:   e.ModeratorId,
+			Action:        e.Action,
+			UserId:        e.UserId,
+			LeaderboardId: e.LeaderboardId,
+			TargetId:      e.TargetId,
+			Reason:        e.Reason,
+			CreatedAt:     e.CreatedAt,
+		})
+	}
+	if len(entries) > 0 {
+		res.Cursors = pg.cursors(more, idCursor(entries[0].ID), idCursor(entries[len(entries)-1].ID))
+	}
+	return res, nil
+}
+
+// removeBoardEntries deletes a player's entries and closed season standings on a board and keeps a
+// copy of each under the removal
+func removeBoardEntries(tx *gorm.DB, removal model.Removal, board model.Leaderboard) (int, []period, error) {
+	var entries []model.LeaderboardEntry
+	if err := tx.Where("leaderboard_id = ? AND user_id = ?", board.ID, removal.UserId).Find(&entries).Error; err != nil {
+		return 0, nil, err
+	}
+	var standings []model.SeasonStanding
+	result := tx.Where("user_id = ? AND season_id IN (?)", removal.UserId,
+		tx.Model(&model.Season{}).Select("id").Where("leaderboard_id = ? AND status = ?", board.ID, SeasonClosed)).
+		Find(&standings)
+	if result.Error != nil {
+		return 0, nil, result.Error
+	}
+	if len(entries) == 0 && len(standings) == 0 {
+		return 0, nil, nil
+	}
+
+	rows := make([]model.RemovedEntry, 0, len(entries)+len(standings))
+	periods := make([]period, 0, len(entries))
+	ids := make([]uint, 0, len(entries))
+	for _, entry := range entries {
+		rows = append(rows, model.RemovedEntry{
+			RemovalId:     removal.ID,
+			LeaderboardId: board.ID,
+			Window:        entry.Window,
+			PeriodStart:   entry.PeriodStart,
+			Score:         entry.Score,
+			Metric:        entry.Metric,
+			Submissions:   entry.Submissions,
+			SubmittedAt:   entry.SubmittedAt,
+		})
+		periods = append(periods, entryPeriod(entry))
+		ids = append(ids, entry.ID)
+	}
+	for _, standing := range standings {
+		seasonId := standing.SeasonId
+		rows = append(rows, model.RemovedEntry{
+			RemovalId:     removal.ID,
+			LeaderboardId: board.ID,
+			Window:        WindowSeason,
+			SeasonId:      &seasonId,
+			Position:      standing.Position,
+			Score:         standing.Score,
+			SubmittedAt:   standing.SubmittedAt,
+		})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return 0, nil, err
+	}
+
+	// the entries are deleted for good, a soft deleted row would hold the player's slot in the unique index
+	if len(ids) > 0 {
+		if err := tx.Unscoped().Delete(&model.LeaderboardEntry{}, ids).Error; err != nil {
+			return 0, nil, err
+		}
+	}
+	for _, standing := range standings {
+		if err := tx.Delete(&standing).Error; err != nil {
+			return 0, nil, err
+		}
+		if err := renumberStandings(tx, board, standing.SeasonId); err != nil {
+			return 0, nil, err
+		}
+	}
+	return len(rows), periods, nil
+}
+
+// restoreEntry folds a removed entry back into the entry the player holds in its period now
+func restoreEntry(tx *gorm.DB, board model.Leaderboard, p period, userId string, row model.RemovedEntry) error {
+	entry, err := lockEntry(tx, board, p, userId)
+	if err != nil {
+		return err
+	}
+	foldRemoved(board, &entry, row)
+	if entry.Country, err = countryOf(tx, userId); err != nil {
+		return err
+	}
+	_, entry.Hidden, err = activeBan(tx, board.ID, userId, true)
+	if err != nil {
+		return err
+	}
+	return tx.Save(&entry).Error
+}
+
+// foldRemoved adds a removed entry to the one the player holds now the way the board aggregates scores
+func foldRemoved(board model.Leaderboard, entry *model.LeaderboardEntry, row model.RemovedEntry) {
+	first := entry.Submissions == 0
+	entry.Submissions += row.Submissions
+	switch board.Aggregation {
+	case AggregateSum, AggregateCount:
+		entry.Score += row.Score
+		if row.SubmittedAt.After(entry.SubmittedAt) {
+			entry.SubmittedAt = row.SubmittedAt
+		}
+	case AggregateLatest:
+		if first || row.SubmittedAt.After(entry.SubmittedAt) {
+			entry.Score, entry.Metric, entry.SubmittedAt = row.Score, row.Metric, row.SubmittedAt
+		}
+	default:
+		tieBroken := row.Score == entry.Score && isBetterMetric(board, row.Metric, entry.Metric)
+		if first || isBetter(board, row.Score, entry.Score) || tieBroken {
+			entry.Score, entry.Metric, entry.SubmittedAt = row.Score, row.Metric, row.SubmittedAt
+		}
+	}
+}
+
+// closedSeasonAt finds the season a removed season entry belongs to, closed is set when its standings
+// were taken since. A shadow banned player stays out of the standings, their entry goes back hidden.
+func closedSeasonAt(tx *gorm.DB, board model.Leaderboard, start time.Time, userId string) (uint, bool, error) {
+	var season model.Season
+	result := tx.Where("leaderboard_id = ? AND started_at = ?", board.ID, start).Limit(1).Find(&season)
+	if result.Error != nil || result.RowsAffected == 0 || season.Status != SeasonClosed {
+		return 0, false, result.Error
+	}
+	_, hidden, err := activeBan(tx, board.ID, userId, true)
+	if err != nil || hidden {
+		return 0, false, err
+	}
+	return season.ID, true, nil
+}
+
+// restoreStanding puts a removed season entry into the standings of its closed season, folded into a
+// standing the player already holds or placed among the others by score and submission time
+func restoreStanding(tx *gorm.DB, board model.Leaderboard, seasonId uint, userId string, row model.RemovedEntry) error {
+	var standing model.SeasonStanding
+	result := tx.Where("season_id = ? AND user_id = ?", seasonId, userId).Limit(1).Find(&standing)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		entry := model.LeaderboardEntry{Score: standing.Score, Submissions: 1, SubmittedAt: standing.SubmittedAt}
+		foldRemoved(board, &entry, row)
+		standing.Score, standing.SubmittedAt = entry.Score, entry.SubmittedAt
+		return tx.Save(&standing).Error
+	}
+
+	// the new row goes in before the first equal score sent after it, the rows from there on move down
+	var standings []model.SeasonStanding
+	result = tx.Select("id", "position", "score", "submitted_at").
+		Where("season_id = ?", seasonId).
+		Order("score " + board.SortOrder + ", position, id").
+		Find(&standings)
+	if result.Error != nil {
+		return result.Error
+	}
+	at := len(standings)
+	for i, other := range standings {
+		if isBetter(board, row.Score, other.Score) || (row.Score == other.Score && other.SubmittedAt.After(row.SubmittedAt)) {
+			at = i
+			break
+		}
+	}
+	standing = model.SeasonStanding{SeasonId: seasonId, UserId: userId, Score: row.Score, SubmittedAt: row.SubmittedAt}
+	standings = slices.Insert(standings, at, standing)
+
+	counter := tieCounter{board: board}
+	for i := range standings {
+		position := counter.next(standings[i].Score)
+		if i == at {
+			standing.Position = position
+			continue
+		}
+		if position == standings[i].Position {
+			continue
+		}
+		if err := tx.Model(&model.SeasonStanding{}).Where("id = ?", standings[i].ID).Update("position", position).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Create(&standing).Error
+}
+
+// renumberStandings recounts the positions of a closed season after a removal changed its rows. The
+// rows keep their order, a restored one goes back to the place it held among equal scores.
+func renumberStandings(tx *gorm.DB, board model.Leaderboard, seasonId uint) error {
+	var standings []model.SeasonStanding
+	result := tx.Select("id", "position", "score").
+		Where("season_id = ?", seasonId).
+		Order("score " + board.SortOrder + ", position, id").
+		Find(&standings)
+	if result.Error != nil {
+		return result.Error
+	}
+	counter := tieCounter{board: board}
+	for _, standing := range standings {
+		position := counter.next(standing.Score)
+		if position == standing.Position {
+			continue
+		}
+		if err := tx.Model(&model.SeasonStanding{}).Where("id = ?", standing.ID).Update("position", position).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// liftBan marks a ban lifted and shows the entries of a shadow ban again
+func liftBan(tx *gorm.DB, ban *model.Ban, moderatorId string, reason string) (map[uint][]period, error) {
+	now := time.Now().UTC()
+	ban.LiftedBy = moderatorId
+	ban.LiftedAt = &now
+	if err := tx.Save(ban).Error; err != nil {
+		return nil, err
+	}
+	var touched map[uint][]period
+	if ban.Shadow {
+		var err error
+		if touched, err = hideEntries(tx, *ban, false); err != nil {
+			return nil, err
+		}
+	}
+	return touched, audit(tx, moderatorId, ActionLiftBan, ban.UserId, ban.LeaderboardId, ban.ID, reason)
+}
+
+// hideEntries hides or shows the entries a shadow ban covers and returns the periods that changed.
+// Entries still covered by another shadow ban stay hidden.
+func hideEntries(tx *gorm.DB, ban model.Ban, hidden bool) (map[uint][]period, error) {
+	scope := func() *gorm.DB {
+		query := tx.Model(&model.LeaderboardEntry{}).Where("user_id = ? AND hidden = ?", ban.UserId, !hidden)
+		if ban.LeaderboardId != nil {
+			query = query.Where("leaderboard_id = ?", *ban.LeaderboardId)
+		}
+		return query
+	}
+	if !hidden {
+		var others []model.Ban
+		if err := activeBans(tx, ban.UserId, true).Where("id <> ?", ban.ID).Find(&others).Error; err != nil {
+			return nil, err
+		}
+		var boardIds []uint
+		for _, other := range others {
+			if other.LeaderboardId == nil {
+				return nil, nil
+			}
+			boardIds = append(boardIds, *other.LeaderboardId)
+		}
+		if len(boardIds) > 0 {
+			base := scope
+			scope = func() *gorm.DB {
+				return base().Where("leaderboard_id NOT IN ?", boardIds)
+			}
+		}
+	}
+
+	var entries []model.LeaderboardEntry
+	if err := scope().Select("leaderboard_id", "time_window", "period_start").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	if err := scope().Update("hidden", hidden).Error; err != nil {
+		return nil, err
+	}
+
+	touched := map[uint][]period{}
+	for _, entry := range entries {
+		touched[entry.LeaderboardId] = append(touched[entry.LeaderboardId], entryPeriod(entry))
+	}
+	// the team entries only count what is shown
+	for boardId, periods := range touched {
+		board, err := findLeaderboard(tx, boardId)
+		if err != nil {
+			return nil, err
+		}
+		if err := updateTeamScores(tx, board, ban.UserId, periods); err != nil {
+			return nil, err
+		}
+	}
+	return touched, nil
+}
+
+// activeBan returns the ban, or shadow ban, a player is under on a board now
+func activeBan(db *gorm.DB, boardId uint, userId string, shadow bool) (model.Ban, bool, error) {
+	var ban model.Ban
+	result := activeBans(db, userId, shadow).
+		Where("leaderboard_id IS NULL OR leaderboard_id = ?", boardId).
+		Order("id").
+		Limit(1).
+		Find(&ban)
+	if result.Error != nil {
+		return model.Ban{}, false, result.Error
+	}
+	return ban, result.RowsAffected > 0, nil
+}
+
+// activeBans scopes a query to the bans of a player that are neither lifted nor expired
+func activeBans(db *gorm.DB, userId string, shadow bool) *gorm.DB {
+	return liveBans(db, shadow).Where("user_id = ?", userId)
+}
+
+// liveBans scopes a query to the bans of every player that are neither lifted nor expired
+func liveBans(db *gorm.DB, shadow bool) *gorm.DB {
+	return db.Model(&model.Ban{}).
+		Where("shadow = ? AND lifted_at IS NULL", shadow).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+// sanctionBoards checks the player exists and returns the board a sanction targets, or every board for 0
+func sanctionBoards(userId string, leaderboardId uint) ([]model.Leaderboard, error) {
+	if err := config.DB.Where("user_id = ?", userId).First(&model.User{}).Error; err != nil {
+		return nil, err
+	}
+	if leaderboardId != 0 {
+		board, err := findLeaderboard(config.DB, leaderboardId)
+		if err != nil {
+			return nil, err
+		}
+		return []model.Leaderboard{board}, nil
+	}
+	var boards []model.Leaderboard
+	if err := config.DB.Order("id").Find(&boards).Error; err != nil {
+		return nil, err
+	}
+	return boards, nil
+}
+
+// boardScope is the board column of a sanction, nil covers every board
+func boardScope(leaderboardId uint) *uint {
+	if leaderboardId == 0 {
+		return nil
+	}
+	return &leaderboardId
+}
+
+// dropPeriods makes the rank index reload the periods a moderation action changed on their next read
+func dropPeriods(touched map[uint][]period) {
+	for boardId, periods := range touched {
+		board := model.Leaderboard{Model: gorm.Model{ID: boardId}}
+		for _, p := range periods {
+			ranks.Drop(board, p)
+		}
+	}
+}
+
+// audit records a moderation action
+func audit(tx *gorm.DB, moderatorId, action, userId string, leaderboardId *uint, targetId uint, reason string) error {
+	return tx.Create(&model.AuditEntry{
+		ModeratorId:   moderatorId,
+		Action:        action,
+		UserId:        userId,
+		LeaderboardId: leaderboardId,
+		TargetId:      targetId,
+		Reason:        reason,
+	}).Error
+}
+
+func toRemovalEntity(removal model.Removal, entries int) entity.Removal {
+	return entity.Removal{
+		ID:            removal.ID,
+		LeaderboardId: removal.LeaderboardId,
+		UserId:        removal.UserId,
+		Reason:        removal.Reason,
+		Entries:       entries,
+		RemovedBy:     removal.RemovedBy,
+		RestoredBy:    removal.RestoredBy,
+		RestoredAt:    removal.RestoredAt,
+		CreatedAt:     removal.CreatedAt,
+	}
+}
+
+func toBanEntity(ban model.Ban) entity.Ban {
+	return entity.Ban{
+		ID:            ban.ID,
+		LeaderboardId: ban.LeaderboardId,
+		UserId:        ban.UserId,
+		Reason:        ban.Reason,
+		Shadow:        ban.Shadow,
+		Active:        ban.LiftedAt == nil && (ban.ExpiresAt == nil || ban.ExpiresAt.After(time.Now())),
+		ExpiresAt:     ban.ExpiresAt,
+		BannedBy:      ban.BannedBy,
+		LiftedBy:      ban.LiftedBy,
+		LiftedAt:      ban.LiftedAt,
+		CreatedAt:     ban.CreatedAt,
+	}
+}
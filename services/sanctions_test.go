@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/entity"
+)
+
+// ranked lists the entries as user#rank
+func ranked(entries []entity.LeaderboardEntry) string {
+	var got []string
+	for _, entry := range entries {
+		got = append(got, fmt.Sprintf("%s#%d", entry.UserId, entry.Rank))
+	}
+	return strings.Join(got, " ")
+}
+
+func TestRemoveAndRestoreEntries(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b", "c")
+	boards := NewLeaderboardService()
+	board, err := boards.CreateLeaderboard(entity.Leaderboard{Name: "removal", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seasons := NewSeasonService()
+	moderation := NewModerationService()
+	if _, err := seasons.OpenSeason(board.ID, entity.OpenSeason{Name: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	submit(t, board.ID, "a", 10)
+	submit(t, board.ID, "b", 30)
+	submit(t, board.ID, "c", 20)
+	if _, err := seasons.CloseSeason(board.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seasons.OpenSeason(board.ID, entity.OpenSeason{Name: "two"}); err != nil {
+		t.Fatal(err)
+	}
+	submit(t, board.ID, "b", 25)
+	submit(t, board.ID, "a", 15)
+
+	allTime := func() string {
+		t.Helper()
+		page, err := boards.TopEntries(entity.LeaderboardQuery{LeaderboardId: board.ID, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ranked(page.Entries)
+	}
+	standings := func(number int) string {
+		t.Helper()
+		entries, err := seasons.Standings(board.ID, number, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ranked(entries)
+	}
+	check := func(when, allTimeWant, firstWant, secondWant string) {
+		t.Helper()
+		if got := allTime(); got != allTimeWant {
+			t.Errorf("all time %s: got %q, want %q", when, got, allTimeWant)
+		}
+		if got := standings(1); got != firstWant {
+			t.Errorf("closed season %s: got %q, want %q", when, got, firstWant)
+		}
+		if got := standings(2); got != secondWant {
+			t.Errorf("second season %s: got %q, want %q", when, got, secondWant)
+		}
+	}
+	check("before the removal", "b#1 c#2 a#3", "b#1 c#2 a#3", "b#1 a#2")
+
+	removal, err := moderation.RemoveEntries("mod", entity.NewRemoval{UserId: "b", Reason: "cheating"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("after the removal", "c#1 a#2", "c#1 a#2", "a#1")
+
+	// the second season closes while the entries are removed, they come back into its standings
+	if _, err := seasons.CloseSeason(board.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := moderation.RestoreEntries(removal.ID, "mod", "appeal upheld"); err != nil {
+		t.Fatal(err)
+	}
+	check("after the restore", "b#1 c#2 a#3", "b#1 c#2 a#3", "b#1 a#2")
+
+	if _, err := moderation.RestoreEntries(removal.ID, "mod", "again"); !errors.Is(err, ErrAlreadyRestored) {
+		t.Errorf("second restore: got %v, want %v", err, ErrAlreadyRestored)
+	}
+}
+
+func TestBanAndLift(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b")
+	boards := NewLeaderboardService()
+	board, err := boards.CreateLeaderboard(entity.Leaderboard{Name: "ban", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	moderation := NewModerationService()
+	submit(t, board.ID, "a", 10)
+	submit(t, board.ID, "b", 20)
+	top := func() string {
+		t.Helper()
+		page, err := boards.TopEntries(entity.LeaderboardQuery{LeaderboardId: board.ID, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ranked(page.Entries)
+	}
+
+	ban, err := moderation.Ban("mod", entity.NewBan{UserId: "b", LeaderboardId: board.ID, Reason: "cheating"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = boards.SubmitScore(entity.ScoreSubmission{LeaderboardId: board.ID, UserId: "b", Score: 30})
+	var banned *BannedError
+	if !errors.As(err, &banned) || banned.Reason != "cheating" {
+		t.Errorf("banned submission: got %v, want a BannedError", err)
+	}
+	if _, err := moderation.LiftBan(ban.ID, "mod", "appeal upheld"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := moderation.LiftBan(ban.ID, "mod", "again"); !errors.Is(err, ErrAlreadyLifted) {
+		t.Errorf("second lift: got %v, want %v", err, ErrAlreadyLifted)
+	}
+	submit(t, board.ID, "b", 30)
+
+	// a shadow ban hides the player's entries until it is lifted
+	shadow, err := moderation.Ban("mod", entity.NewBan{UserId: "b", Reason: "cheating", Shadow: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := top(); got != "a#1" {
+		t.Errorf("shadow banned: got %q, want a#1", got)
+	}
+	if _, err := moderation.LiftBan(shadow.ID, "mod", "appeal upheld"); err != nil {
+		t.Fatal(err)
+	}
+	if got := top(); got != "b#1 a#2" {
+		t.Errorf("shadow ban lifted: got %q, want b#1 a#2", got)
+	}
+}
@@ -0,0 +1,324 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WindowSeason holds the entries of the open season, the period start is the season start
+const WindowSeason = "season"
+
+// Season states
+const (
+	SeasonOpen   = "open"
+	SeasonClosed = "closed"
+)
+
+// standingsBatch is how many standings rows are written per insert when a season closes
+const standingsBatch = 500
+
+// SeasonService is an interface for opening, closing and reading leaderboard seasons
+type SeasonService interface {
+	OpenSeason(leaderboardId uint, season entity.OpenSeason) (entity.Season, error)
+	CloseSeason(leaderboardId uint) (entity.Season, error)
+	ListSeasons(leaderboardId uint) ([]entity.Season, error)
+	Standings(leaderboardId uint, number int, limit int) ([]entity.LeaderboardEntry, error)
+	Placement(leaderboardId uint, number int, userId string) (entity.LeaderboardEntry, error)
+}
+
+// seasonservice is an implementation of SeasonService
+type seasonservice struct{}
+
+// NewSeasonService creates and returns a new instance of SeasonService
+func NewSeasonService() SeasonService {
+	return &seasonservice{}
+}
+
+// OpenSeason starts the next season, seeding it from the last final standings when carry over is set
+func (s *seasonservice) OpenSeason(leaderboardId uint, req entity.OpenSeason) (entity.Season, error) {
+	if req.CarryOver < 0 || req.CarryOver > 100 {
+		return entity.Season{}, invalid("carry_over must be a percentage between 0 and 100")
+	}
+
+	var season model.Season
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		board, err := lockLeaderboard(tx, leaderboardId)
+		if err != nil {
+			return err
+		}
+		if err := validateCarryOver(board, req.CarryOver); err != nil {
+			return err
+		}
+		if _, err := findOpenSeason(tx, board.ID); err == nil {
+			return invalid("the leaderboard already has an open season")
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var last model.Season
+		result := tx.Where("leaderboard_id = ?", board.ID).Order("number desc").Limit(1).Find(&last)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		season = model.Season{
+			LeaderboardId: board.ID,
+			Number:        last.Number + 1,
+			Name:          req.Name,
+			Status:        SeasonOpen,
+			CarryOver:     req.CarryOver,
+			StartedAt:     time.Now().UTC().Truncate(time.Millisecond),
+		}
+		if err := tx.Create(&season).Error; err != nil {
+			return err
+		}
+		if req.CarryOver == 0 || last.ID == 0 {
+			return nil
+		}
+		return carryOver(tx, board, last, season)
+	})
+	if err != nil {
+		return entity.Season{}, err
+	}
+	return toSeasonEntity(season), nil
+}
+
+// CloseSeason freezes the open season's standings and closes it
+func (s *seasonservice) CloseSeason(leaderboardId uint) (entity.Season, error) {
+	var season model.Season
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		board, err := lockLeaderboard(tx, leaderboardId)
+		if err != nil {
+			return err
+		}
+		season, err = findOpenSeason(tx, board.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return invalid("the leaderboard has no open season")
+		}
+		if err != nil {
+			return err
+		}
+
+		// copy the ranked season entries into the snapshot a batch at a time
+		counter := tieCounter{board: board}
+		err = rankedBatches(tx, board, seasonPeriod(season), standingsBatch, func(entries []model.LeaderboardEntry) error {
+			standings := make([]model.SeasonStanding, 0, len(entries))
+			for _, entry := range entries {
+				standings = append(standings, model.SeasonStanding{
+					SeasonId:    season.ID,
+					Position:    counter.next(entry.Score),
+					UserId:      entry.UserId,
+					Score:       entry.Score,
+					SubmittedAt: entry.SubmittedAt,
+				})
+			}
+			return tx.Create(&standings).Error
+		})
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		season.Status = SeasonClosed
+		season.EndedAt = &now
+		return tx.Save(&season).Error
+	})
+	if err != nil {
+		return entity.Season{}, err
+	}
+	// the standings are read from the snapshot from now on
+	ranks.Drop(model.Leaderboard{Model: gorm.Model{ID: season.LeaderboardId}}, seasonPeriod(season))
+	return toSeasonEntity(season), nil
+}
+
+// ListSeasons returns the seasons of a leaderboard, newest first
+func (s *seasonservice) ListSeasons(leaderboardId uint) ([]entity.Season, error) {
+	var seasons []model.Season
+	result := config.DB.Where("leaderboard_id = ?", leaderboardId).Order("number desc").Find(&seasons)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	list := make([]entity.Season, 0, len(seasons))
+	for _, season := range seasons {
+		list = append(list, toSeasonEntity(season))
+	}
+	return list, nil
+}
+
+// Standings returns the top of a season, frozen for closed seasons and live for the open one
+func (s *seasonservice) Standings(leaderboardId uint, number int, limit int) ([]entity.LeaderboardEntry, error) {
+	board, season, err := findSeason(leaderboardId, number)
+	if err != nil {
+		return nil, err
+	}
+	if season.Status == SeasonOpen {
+		return topEntries(board, seasonPeriod(season), limit)
+	}
+
+	var standings []model.SeasonStanding
+	result := config.DB.Where("season_id = ?", season.ID).Order("position, id").Limit(limit).Find(&standings)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	list := make([]entity.LeaderboardEntry, 0, len(standings))
+	for _, standing := range standings {
+		list = append(list, toStandingEntity(board, season, standing))
+	}
+	return list, nil
+}
+
+// Placement returns where a user finished in a season
+func (s *seasonservice) Placement(leaderboardId uint, number int, userId string) (entity.LeaderboardEntry, error) {
+	board, season, err := findSeason(leaderboardId, number)
+	if err != nil {
+		return entity.LeaderboardEntry{}, err
+	}
+	if season.Status == SeasonOpen {
+		entry, err := findOwnEntry(config.DB, board, seasonPeriod(season), userId)
+		if err != nil {
+			return entity.LeaderboardEntry{}, err
+		}
+		rank, err := ownRank(board, seasonPeriod(season), entry)
+		if err != nil {
+			return entity.LeaderboardEntry{}, err
+		}
+		list, err := rankedEntities(board, seasonPeriod(season), []model.LeaderboardEntry{entry}, rank)
+		if err != nil {
+			return entity.LeaderboardEntry{}, err
+		}
+		return list[0], nil
+	}
+
+	var standing model.SeasonStanding
+	result := config.DB.Where("season_id = ? AND user_id = ?", season.ID, userId).First(&standing)
+	if result.Error != nil {
+		return entity.LeaderboardEntry{}, result.Error
+	}
+	return toStandingEntity(board, season, standing), nil
+}
+
+// carryOver seeds the new season with a share of every final score of the previous one
+func carryOver(tx *gorm.DB, board model.Leaderboard, last, season model.Season) error {
+	var standings []model.SeasonStanding
+	result := tx.Where("season_id = ?", last.ID).
+		FindInBatches(&standings, standingsBatch, func(batch *gorm.DB, _ int) error {
+			entries := make([]model.LeaderboardEntry, 0, len(standings))
+			for _, standing := range standings {
+				entries = append(entries, model.LeaderboardEntry{
+					LeaderboardId: board.ID,
+					Window:        WindowSeason,
+					PeriodStart:   season.StartedAt,
+					UserId:        standing.UserId,
+					Score:         standing.Score * season.CarryOver / 100,
+					SubmittedAt:   standing.SubmittedAt,
+				})
+			}
+			return tx.Create(&entries).Error
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	// the carried entries keep the country boards of the season whole
+	result = periodEntries(tx, board, seasonPeriod(season)).
+		Update("country", gorm.Expr("COALESCE((SELECT country FROM user_details WHERE user_details.user_id = leaderboard_entries.user_id AND user_details.deleted_at IS NULL), '')"))
+	if result.Error != nil {
+		return result.Error
+	}
+	// and stay hidden for the players a shadow ban covers
+	shadowBanned := liveBans(tx, true).Select("user_id").Where("leaderboard_id IS NULL OR leaderboard_id = ?", board.ID)
+	return periodScope(tx, board, seasonPeriod(season)).Where("user_id IN (?)", shadowBanned).Update("hidden", true).Error
+}
+
+// validateCarryOver checks a board can seed a season from the last standings. Only sum boards ranking
+// the highest score first can, a share of a best, latest or count score would be replaced or reset by
+// the first new submission and a share of a time would rank better than the time itself.
+func validateCarryOver(board model.Leaderboard, carryOver float64) error {
+	if carryOver == 0 {
+		return nil
+	}
+	if board.SortOrder == SortAsc {
+		return invalid("carry_over is not allowed on boards ranking the lowest score first")
+	}
+	if board.Aggregation != AggregateSum {
+		return invalid("carry_over is only allowed on sum boards")
+	}
+	return nil
+}
+
+// openSeasonPeriod returns the period of the board's open season, ok is false when no season runs
+func openSeasonPeriod(tx *gorm.DB, boardId uint) (period, bool, error) {
+	season, err := findOpenSeason(tx, boardId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return period{}, false, nil
+	}
+	if err != nil {
+		return period{}, false, err
+	}
+	return seasonPeriod(season), true, nil
+}
+
+func seasonPeriod(season model.Season) period {
+	return period{Window: WindowSeason, Start: season.StartedAt}
+}
+
+func findOpenSeason(db *gorm.DB, boardId uint) (model.Season, error) {
+	var season model.Season
+	result := db.Where("leaderboard_id = ? AND status = ?", boardId, SeasonOpen).First(&season)
+	if result.Error != nil {
+		return model.Season{}, result.Error
+	}
+	return season, nil
+}
+
+func findSeason(boardId uint, number int) (model.Leaderboard, model.Season, error) {
+	board, err := findLeaderboard(config.DB, boardId)
+	if err != nil {
+		return model.Leaderboard{}, model.Season{}, err
+	}
+	var season model.Season
+	result := config.DB.Where("leaderboard_id = ? AND number = ?", board.ID, number).First(&season)
+	if result.Error != nil {
+		return model.Leaderboard{}, model.Season{}, result.Error
+	}
+	return board, season, nil
+}
+
+// lockLeaderboard loads a board for update so season changes on it run one at a time
+func lockLeaderboard(tx *gorm.DB, id uint) (model.Leaderboard, error) {
+	var board model.Leaderboard
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&board, id)
+	if result.Error != nil {
+		return model.Leaderboard{}, result.Error
+	}
+	return board, nil
+}
+
+func toSeasonEntity(season model.Season) entity.Season {
+	return entity.Season{
+		ID:            season.ID,
+		LeaderboardId: season.LeaderboardId,
+		Number:        season.Number,
+		Name:          season.Name,
+		Status:        season.Status,
+		CarryOver:     season.CarryOver,
+		StartedAt:     season.StartedAt,
+		EndedAt:       season.EndedAt,
+	}
+}
+
+func toStandingEntity(board model.Leaderboard, season model.Season, standing model.SeasonStanding) entity.LeaderboardEntry {
+	entry := model.LeaderboardEntry{
+		Window:      WindowSeason,
+		PeriodStart: season.StartedAt,
+		UserId:      standing.UserId,
+		Score:       standing.Score,
+		SubmittedAt: standing.SubmittedAt,
+	}
+	return toEntryEntity(board, entry, standing.Position)
+}
@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+func TestOpenSeasonPlacementSharesTies(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b", "c")
+	board, err := NewLeaderboardService().CreateLeaderboard(entity.Leaderboard{Name: "season", Game: "game", TieBreak: TieCompetition})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSeasonService()
+	if _, err := s.OpenSeason(board.ID, entity.OpenSeason{Name: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	submit(t, board.ID, "a", 10)
+	submit(t, board.ID, "b", 10)
+	submit(t, board.ID, "c", 5)
+
+	for userId, want := range map[string]int{"a": 1, "b": 1, "c": 3} {
+		placement, err := s.Placement(board.ID, 1, userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if placement.Rank != want {
+			t.Errorf("placement of %s: got %d, want %d", userId, placement.Rank, want)
+		}
+	}
+}
+
+func TestCarryOverKeepsShadowBannedHidden(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b")
+	board, err := NewLeaderboardService().CreateLeaderboard(entity.Leaderboard{Name: "season", Game: "game", Aggregation: AggregateSum})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSeasonService()
+	if _, err := s.OpenSeason(board.ID, entity.OpenSeason{Name: "one"}); err != nil {
+		t.Fatal(err)
+	}
+	submit(t, board.ID, "a", 10)
+	submit(t, board.ID, "b", 20)
+	if _, err := s.CloseSeason(board.ID); err != nil {
+		t.Fatal(err)
+	}
+	// banned between the seasons, the final standings still count the player
+	if _, err := NewModerationService().Ban("mod", entity.NewBan{UserId: "b", Reason: "cheating", Shadow: true}); err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.OpenSeason(board.ID, entity.OpenSeason{Name: "two", CarryOver: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []model.LeaderboardEntry
+	result := config.DB.Where("leaderboard_id = ? AND time_window = ? AND period_start = ?", board.ID, WindowSeason, second.StartedAt).
+		Order("user_id").Find(&entries)
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d carried entries, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		if want := entry.UserId == "b"; entry.Hidden != want {
+			t.Errorf("carried entry of %s: got hidden %v, want %v", entry.UserId, entry.Hidden, want)
+		}
+	}
+	standings, err := s.Standings(board.ID, 2, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(standings) != 1 || standings[0].UserId != "a" || standings[0].Score != 5 {
+		t.Errorf("standings of the new season: got %+v, want only a with 5", standings)
+	}
+}
@@ -1,13 +1,17 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/JohnnyOhms/projectx/config"
 	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -24,8 +28,18 @@ type AuthService interface {
 	CreateDetails(details entity.User_Details) (entity.User_Details, error)
 	FindDetails(userId entity.UserId) (entity.User_Details, error)
 	SetAvatar(avatar entity.Avatar, filename string) (entity.Avatar, error)
+	SetCountry(userId string, country entity.Country) (entity.Country, error)
+	DiscordDetails(userId string, discord entity.UserDiscordData) error
+	SearchUsers(q string, page entity.PageQuery) (entity.UserPage, error)
+	SetAccountType(userId string, accountType entity.AccountType) (entity.User, error)
 }
 
+// The account types, only admins can use the moderation and board management routes
+const (
+	AccountUser  = "user"
+	AccountAdmin = "admin"
+)
+
 // authservice is an implementation of UserAuthService
 type authservice struct{}
 
@@ -47,8 +61,16 @@ func (*authservice) Find(loginUser entity.LoginUser) (entity.User, error) {
 
 // Add new user to the database
 func (s *authservice) Create(user entity.User) (entity.User, error) {
-	// Insert the new userId into the user body
+	// Insert the new userId into the user body, admins are only made by an admin or ADMIN_USER_IDS
 	user.UserId = s.GenerateUserId()
+	user.Account_Type = AccountUser
+	var taken int64
+	if err := config.DB.Model(&model.User{}).Where("email = ?", user.Email).Count(&taken).Error; err != nil {
+		return entity.User{}, err
+	}
+	if taken > 0 {
+		return entity.User{}, invalid("the email is already registered")
+	}
 	result := config.DB.Create(&user)
 	if result.Error != nil {
 
@@ -104,6 +126,13 @@ func (s *authservice) GenearateToken(user entity.User) (string, error) {
 
 // create user infomation form the database
 func (s *authservice) CreateDetails(details entity.User_Details) (entity.User_Details, error) {
+	var taken int64
+	if err := config.DB.Model(&model.User_Details{}).Where("user_id = ?", details.UserId).Count(&taken).Error; err != nil {
+		return entity.User_Details{}, err
+	}
+	if taken > 0 {
+		return entity.User_Details{}, invalid("the user already has details")
+	}
 	result := config.DB.Create(&details)
 	if result.Error != nil {
 		return entity.User_Details{}, result.Error
@@ -114,13 +143,170 @@ func (s *authservice) CreateDetails(details entity.User_Details) (entity.User_De
 // find the user information from the database
 func (s *authservice) FindDetails(userId entity.UserId) (entity.User_Details, error) {
 	var foundDetails entity.User_Details
-	result := config.DB.Where("userid = ?", userId).First(&foundDetails)
+	result := config.DB.Where("user_id = ?", userId.UserId).First(&foundDetails)
 	if result.Error != nil {
 		return entity.User_Details{}, result.Error
 	}
+	// show the badges the user has earned on their profile
+	badges, err := userBadges(config.DB, userId.UserId)
+	if err != nil {
+		return entity.User_Details{}, err
+	}
+	foundDetails.Badges = badges
 	return foundDetails, nil
 }
 
+// SetCountry changes the country the user is ranked under
+func (s *authservice) SetCountry(userId string, country entity.Country) (entity.Country, error) {
+	country.Country = strings.ToUpper(country.Country)
+	if err := validateCountry(country.Country); err != nil {
+		return entity.Country{}, err
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.User_Details{}).Where("user_id = ?", userId).Update("country", country.Country)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return moveEntries(tx, userId, country.Country)
+	})
+	if err != nil {
+		return entity.Country{}, err
+	}
+	if err := rankMoved(userId); err != nil {
+		fmt.Println("Error updating the rank index:", err)
+	}
+	return country, nil
+}
+
+// DiscordDetails fills the details of a Discord user, the country comes from their locale
+// unless they have already picked one
+func (s *authservice) DiscordDetails(userId string, discord entity.UserDiscordData) error {
+	country := countryFromLocale(discord.Locale)
+	var details model.User_Details
+	result := config.DB.Where("user_id = ?", userId).First(&details)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		username := discord.GlobalName
+		if username == "" {
+			username = discord.Username
+		}
+		if len(username) > 30 {
+			username = username[:30]
+		}
+		return config.DB.Create(&model.User_Details{
+			UserId:   userId,
+			Email:    discord.Email,
+			Username: username,
+			Discord:  discord.Username,
+			Country:  country,
+		}).Error
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+	if details.Country != "" || country == "" {
+		return nil
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&details).Update("country", country).Error; err != nil {
+			return err
+		}
+		return moveEntries(tx, userId, country)
+	})
+	if err != nil {
+		return err
+	}
+	if err := rankMoved(userId); err != nil {
+		fmt.Println("Error updating the rank index:", err)
+	}
+	return nil
+}
+
+// SetAccountType makes a user an admin or a regular user
+func (s *authservice) SetAccountType(userId string, accountType entity.AccountType) (entity.User, error) {
+	if accountType.AccountType != AccountUser && accountType.AccountType != AccountAdmin {
+		return entity.User{}, invalid("account_type must be user or admin")
+	}
+	result := config.DB.Model(&model.User{}).Where("user_id = ?", userId).Update("account_type", accountType.AccountType)
+	if result.Error != nil {
+		return entity.User{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return entity.User{}, gorm.ErrRecordNotFound
+	}
+	var user entity.User
+	if err := config.DB.Where("user_id = ?", userId).First(&user).Error; err != nil {
+		return entity.User{}, err
+	}
+	user.Password = ""
+	return user, nil
+}
+
+// SeedAdmins makes admins of the users listed in ADMIN_USER_IDS, a comma separated list, so the
+// first admin can be set up
+func SeedAdmins() error {
+	var userIds []string
+	for _, userId := range strings.Split(os.Getenv("ADMIN_USER_IDS"), ",") {
+		if userId = strings.TrimSpace(userId); userId != "" {
+			userIds = append(userIds, userId)
+		}
+	}
+	if len(userIds) == 0 {
+		return nil
+	}
+	return config.DB.Model(&model.User{}).Where("user_id IN ?", userIds).Update("account_type", AccountAdmin).Error
+}
+
+// SearchUsers returns a page of the users whose username starts with q, in username order
+func (s *authservice) SearchUsers(q string, page entity.PageQuery) (entity.UserPage, error) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return entity.UserPage{}, invalid("a search term is required")
+	}
+	pg, err := newPager("users:"+q, page.Cursor, page.Limit)
+	if err != nil {
+		return entity.UserPage{}, err
+	}
+
+	// escape the LIKE wildcards so they match themselves
+	pattern := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(q) + "%"
+	db := config.DB.Where("username LIKE ? ESCAPE '!'", pattern)
+	order := "username, id"
+	if pg.from != nil {
+		op := ">"
+		if pg.back() {
+			op = "<"
+		}
+		db = db.Where("username "+op+" ? OR (username = ? AND id "+op+" ?)", pg.from.Key, pg.from.Key, pg.from.ID)
+	}
+	if pg.back() {
+		order = "username desc, id desc"
+	}
+	var users []model.User_Details
+	if err := db.Order(order).Limit(pg.limit + 1).Find(&users).Error; err != nil {
+		return entity.UserPage{}, err
+	}
+	more := len(users) > pg.limit
+	if more {
+		users = users[:pg.limit]
+	}
+	if pg.back() {
+		slices.Reverse(users)
+	}
+
+	res := entity.UserPage{Users: make([]entity.UserSummary, 0, len(users))}
+	for _, u := range users {
+		res.Users = append(res.Users, entity.UserSummary{UserId: u.UserId, Username: u.Username, Country: u.Country})
+	}
+	if len(users) > 0 {
+		first, last := users[0], users[len(users)-1]
+		res.Cursors = pg.cursors(more, &cursor{Key: first.Username, ID: first.ID}, &cursor{Key: last.Username, ID: last.ID})
+	}
+	return res, nil
+}
+
 // func (s *authservice) SetAvatar(avatar entity.Avatar, filename string) (entity.Avatar, error) {
 // 	// Check if the user ID already exists in the database
 // 	var existingAvatar entity.Avatar
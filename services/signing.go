@@ -0,0 +1,266 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Reasons a score submission is rejected
+const (
+	RejectMissingSignature = "missing_signature"
+	RejectMissingNonce     = "missing_nonce"
+	RejectUnknownKey       = "unknown_key"
+	RejectBadSignature     = "bad_signature"
+	RejectStaleTimestamp   = "stale_timestamp"
+	RejectReplayedNonce    = "replayed_nonce"
+)
+
+// signatureMaxAge is how far a submission timestamp may be from the server clock
+const signatureMaxAge = 5 * time.Minute
+
+// defaultKeyGrace is how long a rotated out key keeps working so clients can pick up the new one
+const defaultKeyGrace = 24 * time.Hour
+
+// RejectedError is returned for a submission that failed the signature checks
+type RejectedError struct {
+	Reason string
+}
+
+func (e *RejectedError) Error() string {
+	return "score submission rejected: " + e.Reason
+}
+
+// SigningService is an interface for managing the keys games sign score submissions with
+type SigningService interface {
+	RotateKey(game string, req entity.RotateKey) (entity.GameKey, error)
+	ListKeys(game string) ([]entity.GameKey, error)
+	RevokeKey(game string, keyId string) error
+	ListRejections(leaderboardId uint, page entity.PageQuery) (entity.RejectionPage, error)
+	PruneNonces() error
+}
+
+// signingservice is an implementation of SigningService
+type signingservice struct{}
+
+// NewSigningService creates and returns a new instance of SigningService
+func NewSigningService() SigningService {
+	return &signingservice{}
+}
+
+// RotateKey creates a new key for the game, the current keys keep working for the grace period
+func (s *signingservice) RotateKey(game string, req entity.RotateKey) (entity.GameKey, error) {
+	grace := defaultKeyGrace
+	if req.GraceHours < 0 {
+		return entity.GameKey{}, invalid("grace_hours must not be negative")
+	} else if req.GraceHours > 0 {
+		grace = time.Duration(req.GraceHours) * time.Hour
+	}
+
+	key := model.GameKey{
+		Game:   game,
+		KeyId:  randomHex(8),
+		Secret: randomHex(32),
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		retires := time.Now().Add(grace)
+		result := tx.Model(&model.GameKey{}).
+			Where("game = ? AND (retires_at IS NULL OR retires_at > ?)", game, retires).
+			Update("retires_at", retires)
+		if result.Error != nil {
+			return result.Error
+		}
+		return tx.Create(&key).Error
+	})
+	if err != nil {
+		return entity.GameKey{}, err
+	}
+
+	// the secret is only ever shown when the key is created
+	res := toGameKeyEntity(key)
+	res.Secret = key.Secret
+	return res, nil
+}
+
+// ListKeys returns the game's keys without their secrets
+func (s *signingservice) ListKeys(game string) ([]entity.GameKey, error) {
+	var keys []model.GameKey
+	result := config.DB.Where("game = ?", game).Order("id desc").Find(&keys)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	list := make([]entity.GameKey, 0, len(keys))
+	for _, key := range keys {
+		list = append(list, toGameKeyEntity(key))
+	}
+	return list, nil
+}
+
+// RevokeKey stops a key working straight away
+func (s *signingservice) RevokeKey(game string, keyId string) error {
+	result := config.DB.Where("game = ? AND key_id = ?", game, keyId).Delete(&model.GameKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// ListRejections returns the latest rejected submissions of a leaderboard
+func (s *signingservice) ListRejections(leaderboardId uint, page entity.PageQuery) (entity.RejectionPage, error) {
+	pg, err := newPager(fmt.Sprintf("rejections:%d", leaderboardId), page.Cursor, page.Limit)
+	if err != nil {
+		return entity.RejectionPage{}, err
+	}
+	var rejections []model.SubmissionRejection
+	if err := pg.byId(config.DB.Where("leaderboard_id = ?", leaderboardId), true).Find(&rejections).Error; err != nil {
+		return entity.RejectionPage{}, err
+	}
+	more := len(rejections) > pg.limit
+	if more {
+		rejections = rejections[:pg.limit]
+	}
+	if pg.back() {
+		slices.Reverse(rejections)
+	}
+
+	res := entity.RejectionPage{Rejections: make([]entity.SubmissionRejection, 0, len(rejections))}
+	for _, r := range rejections {
+		res.Rejections = append(res.Rejections, entity.SubmissionRejection{
+			LeaderboardId: r.LeaderboardId,
+			UserId:        r.UserId,
+			Reason:        r.Reason,
+			Score:         r.Score,
+			KeyId:         r.KeyId,
+			Nonce:         r.Nonce,
+			Timestamp:     r.Timestamp,
+			CreatedAt:     r.CreatedAt,
+		})
+	}
+	if len(rejections) > 0 {
+		res.Cursors = pg.cursors(more, idCursor(rejections[0].ID), idCursor(rejections[len(rejections)-1].ID))
+	}
+	return res, nil
+}
+
+// PruneNonces forgets nonces whose timestamps are too old to pass the checks again
+func (s *signingservice) PruneNonces() error {
+	return config.DB.Where("created_at < ?", time.Now().Add(-2*signatureMaxAge)).Delete(&model.UsedNonce{}).Error
+}
+
+// SignaturePayload is the message a client signs with HMAC-SHA256 and sends hex encoded. Every field
+// the server keeps is signed on a line of its own, empty and zero ones included, the metadata last and
+// byte for byte as sent so it may span lines.
+func SignaturePayload(submission entity.ScoreSubmission) string {
+	return fmt.Sprintf("%d\n%s\n%s\n%d\n%s\n%s\n%s\n%s",
+		submission.LeaderboardId,
+		submission.UserId,
+		strconv.FormatFloat(submission.Score, 'f', -1, 64),
+		submission.Timestamp,
+		submission.Nonce,
+		strconv.FormatFloat(submission.Metric, 'f', -1, 64),
+		submission.EvidenceUrl,
+		submission.Metadata,
+	)
+}
+
+// verifySignature checks a submission against the keys of the board's game. Games without keys accept
+// unsigned scores, signed reports whether the nonce still has to be used up.
+func verifySignature(db *gorm.DB, board model.Leaderboard, submission entity.ScoreSubmission) (signed bool, err error) {
+	var keys []model.GameKey
+	if err := db.Where("game = ?", board.Game).Find(&keys).Error; err != nil {
+		return false, err
+	}
+	if len(keys) == 0 {
+		return false, nil
+	}
+
+	if submission.Signature == "" {
+		return true, &RejectedError{Reason: RejectMissingSignature}
+	}
+	if submission.Nonce == "" || len(submission.Nonce) > 64 {
+		return true, &RejectedError{Reason: RejectMissingNonce}
+	}
+
+	now := time.Now()
+	var secret string
+	for _, key := range keys {
+		if key.KeyId == submission.KeyId && (key.RetiresAt == nil || key.RetiresAt.After(now)) {
+			secret = key.Secret
+		}
+	}
+	if secret == "" {
+		return true, &RejectedError{Reason: RejectUnknownKey}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(SignaturePayload(submission)))
+	got, err := hex.DecodeString(submission.Signature)
+	if err != nil || !hmac.Equal(got, mac.Sum(nil)) {
+		return true, &RejectedError{Reason: RejectBadSignature}
+	}
+
+	age := now.Sub(time.Unix(submission.Timestamp, 0))
+	if age > signatureMaxAge || age < -signatureMaxAge {
+		return true, &RejectedError{Reason: RejectStaleTimestamp}
+	}
+	return true, nil
+}
+
+// useNonce records a nonce as spent, a second use of it is a replay
+func useNonce(tx *gorm.DB, game string, nonce string) error {
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&model.UsedNonce{Game: game, Nonce: nonce})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return &RejectedError{Reason: RejectReplayedNonce}
+	}
+	return nil
+}
+
+// recordRejection keeps why a submission was turned away
+func recordRejection(submission entity.ScoreSubmission, reason string) {
+	rejection := model.SubmissionRejection{
+		LeaderboardId: submission.LeaderboardId,
+		UserId:        submission.UserId,
+		Reason:        reason,
+		Score:         submission.Score,
+		KeyId:         submission.KeyId,
+		Nonce:         submission.Nonce,
+		Timestamp:     submission.Timestamp,
+	}
+	if err := config.DB.Create(&rejection).Error; err != nil {
+		fmt.Println("Error recording rejected submission:", err)
+	}
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func toGameKeyEntity(key model.GameKey) entity.GameKey {
+	return entity.GameKey{
+		Game:      key.Game,
+		KeyId:     key.KeyId,
+		CreatedAt: key.CreatedAt,
+		RetiresAt: key.RetiresAt,
+	}
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+func sign(secret string, submission entity.ScoreSubmission) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(SignaturePayload(submission)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSignatureCoversEveryField(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b")
+	boards := NewLeaderboardService()
+	board, err := boards.CreateLeaderboard(entity.Leaderboard{Name: "signed", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := boards.CreateLeaderboard(entity.Leaderboard{Name: "other", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := NewSigningService().RotateKey("game", entity.RotateKey{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := entity.ScoreSubmission{
+		LeaderboardId: board.ID,
+		UserId:        "a",
+		Score:         10,
+		Metric:        3,
+		Timestamp:     time.Now().Unix(),
+		Nonce:         "nonce",
+		KeyId:         key.KeyId,
+		EvidenceUrl:   "https://example.com/replay",
+		Metadata:      json.RawMessage(`{"level": 1}`),
+	}
+	signed.Signature = sign(key.Secret, signed)
+
+	tampered := map[string]func(*entity.ScoreSubmission){
+		"leaderboard_id": func(s *entity.ScoreSubmission) { s.LeaderboardId = other.ID },
+		"user_id":        func(s *entity.ScoreSubmission) { s.UserId = "b" },
+		"score":          func(s *entity.ScoreSubmission) { s.Score = 1000 },
+		"metric":         func(s *entity.ScoreSubmission) { s.Metric = 1 },
+		"timestamp":      func(s *entity.ScoreSubmission) { s.Timestamp-- },
+		"nonce":          func(s *entity.ScoreSubmission) { s.Nonce = "other" },
+		"evidence_url":   func(s *entity.ScoreSubmission) { s.EvidenceUrl = "https://example.com/fake" },
+		"metadata":       func(s *entity.ScoreSubmission) { s.Metadata = json.RawMessage(`{"level":1}`) },
+	}
+	for field, tamper := range tampered {
+		submission := signed
+		tamper(&submission)
+		_, err := boards.SubmitScore(submission)
+		var rejection *RejectedError
+		if !errors.As(err, &rejection) || rejection.Reason != RejectBadSignature {
+			t.Errorf("%s changed after signing: got %v, want %s", field, err, RejectBadSignature)
+		}
+	}
+
+	if _, err := boards.SubmitScore(signed); err != nil {
+		t.Fatalf("signed submission: %v", err)
+	}
+	_, err = boards.SubmitScore(signed)
+	var rejection *RejectedError
+	if !errors.As(err, &rejection) || rejection.Reason != RejectReplayedNonce {
+		t.Errorf("replayed submission: got %v, want %s", err, RejectReplayedNonce)
+	}
+
+	stale := signed
+	stale.Nonce = "stale"
+	stale.Timestamp = time.Now().Add(-2 * signatureMaxAge).Unix()
+	stale.Signature = sign(key.Secret, stale)
+	_, err = boards.SubmitScore(stale)
+	if !errors.As(err, &rejection) || rejection.Reason != RejectStaleTimestamp {
+		t.Errorf("stale submission: got %v, want %s", err, RejectStaleTimestamp)
+	}
+
+	// every rejection is kept with its reason
+	var rejections int64
+	if err := config.DB.Model(&model.SubmissionRejection{}).Count(&rejections).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(len(tampered) + 2); rejections != want {
+		t.Errorf("got %d rejections recorded, want %d", rejections, want)
+	}
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+const (
+	// defaultSnapshotInterval is how often the boards are snapshotted when SNAPSHOT_INTERVAL is not set
+	defaultSnapshotInterval = 24 * time.Hour
+	// defaultTimelineDays is how far back a timeline reaches when no range is given
+	defaultTimelineDays = 30
+	// maxTimelinePoints caps the snapshots of one timeline, the newest ones are kept
+	maxTimelinePoints = 1000
+)
+
+// SnapshotService is an interface for the rank snapshots of leaderboards and the deltas counted from them
+type SnapshotService interface {
+	TakeSnapshots() error
+	Snapshot(leaderboardId uint) ([]entity.RankSnapshot, error)
+	ListSnapshots(leaderboardId uint, window string) ([]entity.RankSnapshot, error)
+	Deltas(query entity.DeltaQuery) (entity.DeltaPage, error)
+	Timeline(query entity.TimelineQuery) (entity.RankTimeline, error)
+	PruneSnapshots() error
+}
+
+// snapshotservice is an implementation of SnapshotService
+type snapshotservice struct {
+	interval time.Duration
+}
+
+// NewSnapshotService creates and returns a new instance of SnapshotService, the boards are snapshotted
+// every SNAPSHOT_INTERVAL. A snapshot copies a row per ranked player of each current window, so a
+// board of N players with a season costs up to 5N rows an interval. Keep the interval in hours for
+// large boards.
+func NewSnapshotService() SnapshotService {
+	interval := defaultSnapshotInterval
+	if d, err := time.ParseDuration(os.Getenv("SNAPSHOT_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	return &snapshotservice{interval: interval}
+}
+
+// TakeSnapshots snapshots each current window of the boards not snapshotted since the start of the
+// current interval, a window that opened since then is snapshotted right away. The intervals are
+// counted from the zero time, so daily snapshots fall on midnight UTC. A board that fails is skipped
+// and the others are still snapshotted, the errors are returned together.
+func (s *snapshotservice) TakeSnapshots() error {
+	due := time.Now().UTC().Truncate(s.interval)
+	var boards []model.Leaderboard
+	if err := config.DB.Find(&boards).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for _, board := range boards {
+		if err := takeDueSnapshots(board, due); err != nil {
+			errs = append(errs, fmt.Errorf("leaderboard %d: %w", board.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// takeDueSnapshots snapshots the current periods of a board with no snapshot taken since due
+func takeDueSnapshots(board model.Leaderboard, due time.Time) error {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	periods, err := snapshotPeriods(board, now)
+	if err != nil {
+		return err
+	}
+	for _, p := range periods {
+		var last model.RankSnapshot
+		err := config.DB.Where("leaderboard_id = ? AND time_window = ? AND period_start = ?", board.ID, p.Window, p.Start).
+			Order("taken_at desc").
+			First(&last).Error
+		if err == nil && !last.TakenAt.Before(due) {
+			continue
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if _, err := snapshotPeriod(board, p, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot snapshots every current window of a board right away
+func (s *snapshotservice) Snapshot(leaderboardId uint) ([]entity.RankSnapshot, error) {
+	board, err := findLeaderboard(config.DB, leaderboardId)
+	if err != nil {
+		return nil, err
+	}
+	snapshots, err := snapshotBoard(board)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]entity.RankSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		list = append(list, toSnapshotEntity(snapshot))
+	}
+	return list, nil
+}
+
+// ListSnapshots returns the latest snapshots of a board window, newest first
+func (s *snapshotservice) ListSnapshots(leaderboardId uint, window string) ([]entity.RankSnapshot, error) {
+	board, err := findLeaderboard(config.DB, leaderboardId)
+	if err != nil {
+		return nil, err
+	}
+	if window == "" {
+		window = WindowAllTime
+	}
+	if err := validateSnapshotWindow(board, window); err != nil {
+		return nil, err
+	}
+
+	var snapshots []model.RankSnapshot
+	result := config.DB.Where("leaderboard_id = ? AND time_window = ?", board.ID, window).
+		Order("taken_at desc").
+		Limit(100).
+		Find(&snapshots)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	list := make([]entity.RankSnapshot, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		list = append(list, toSnapshotEntity(snapshot))
+	}
+	return list, nil
+}
+
+// Deltas returns a page of a board window in rank order with the places each player gained or lost
+// since the latest snapshot of the period, or since the last one taken at query.Since. A period whose
+// snapshots ranked nobody yet, such as a daily window snapshotted at the midnight it reset, is compared
+// against the last snapshot of the period before it.
+func (s *snapshotservice) Deltas(query entity.DeltaQuery) (entity.DeltaPage, error) {
+	if query.Country != "" {
+		return entity.DeltaPage{}, invalid("rank deltas are not kept for country boards")
+	}
+	board, p, err := resolveQuery(query.LeaderboardQuery)
+	if err != nil {
+		return entity.DeltaPage{}, err
+	}
+	page, err := topPage(board, p, query.Cursor, query.Limit)
+	if err != nil {
+		return entity.DeltaPage{}, err
+	}
+
+	baseline, found, err := deltaBaseline(board, p, query.Since)
+	if err != nil {
+		return entity.DeltaPage{}, err
+	}
+
+	previous := make(map[string]int, len(page.Entries))
+	if found && len(page.Entries) > 0 {
+		userIds := make([]string, len(page.Entries))
+		for i, entry := range page.Entries {
+			userIds[i] = entry.UserId
+		}
+		var rows []model.SnapshotRank
+		result := config.DB.Where("snapshot_id = ? AND user_id IN ?", baseline.ID, userIds).Find(&rows)
+		if result.Error != nil {
+			return entity.DeltaPage{}, result.Error
+		}
+		for _, row := range rows {
+			previous[row.UserId] = row.Rank
+		}
+	}
+
+	deltas := entity.DeltaPage{Entries: make([]entity.RankDelta, 0, len(page.Entries)), Cursors: page.Cursors}
+	if found {
+		snapshot := toSnapshotEntity(baseline)
+		deltas.Snapshot = &snapshot
+	}
+	for _, entry := range page.Entries {
+		delta := entity.RankDelta{LeaderboardEntry: entry}
+		if rank, ok := previous[entry.UserId]; ok {
+			delta.PreviousRank = &rank
+			delta.Change = rank - entry.Rank
+		} else {
+			delta.New = found
+		}
+		deltas.Entries = append(deltas.Entries, delta)
+	}
+	return deltas, nil
+}
+
+// deltaBaseline finds the snapshot the deltas of a period are counted against, the latest one that
+// ranked somebody in the period or else the last one of an earlier period, taken at since or before
+func deltaBaseline(board model.Leaderboard, p period, since time.Time) (model.RankSnapshot, bool, error) {
+	scope := func() *gorm.DB {
+		db := config.DB.Where("leaderboard_id = ? AND time_window = ? AND entries > ?", board.ID, p.Window, 0)
+		if !since.IsZero() {
+			db = db.Where("taken_at <= ?", since)
+		}
+		return db.Order("taken_at desc")
+	}
+	var baseline model.RankSnapshot
+	result := scope().Where("period_start = ?", p.Start).Limit(1).Find(&baseline)
+	if result.Error != nil || result.RowsAffected > 0 {
+		return baseline, result.Error == nil, result.Error
+	}
+	result = scope().Where("period_start < ?", p.Start).Limit(1).Find(&baseline)
+	return baseline, result.RowsAffected > 0, result.Error
+}
+
+// Timeline returns the rank of a player in each snapshot of a board window, oldest first. Snapshots
+// the player was not ranked in are kept as gaps.
+func (s *snapshotservice) Timeline(query entity.TimelineQuery) (entity.RankTimeline, error) {
+	board, err := findLeaderboard(config.DB, query.LeaderboardId)
+	if err != nil {
+		return entity.RankTimeline{}, err
+	}
+	if query.Window == "" {
+		query.Window = WindowAllTime
+	}
+	if err := validateSnapshotWindow(board, query.Window); err != nil {
+		return entity.RankTimeline{}, err
+	}
+	if query.To.IsZero() {
+		query.To = time.Now()
+	}
+	if query.From.IsZero() {
+		query.From = query.To.AddDate(0, 0, -defaultTimelineDays)
+	}
+	if query.From.After(query.To) {
+		return entity.RankTimeline{}, invalid("from must be before to")
+	}
+
+	var snapshots []model.RankSnapshot
+	result := config.DB.Where("leaderboard_id = ? AND time_window = ? AND taken_at BETWEEN ? AND ?",
+		board.ID, query.Window, query.From, query.To).
+		Order("taken_at desc").
+		Limit(maxTimelinePoints).
+		Find(&snapshots)
+	if result.Error != nil {
+		return entity.RankTimeline{}, result.Error
+	}
+	slices.Reverse(snapshots)
+
+	rows := map[uint]model.SnapshotRank{}
+	if len(snapshots) > 0 {
+		ids := make([]uint, len(snapshots))
+		for i, snapshot := range snapshots {
+			ids[i] = snapshot.ID
+		}
+		var ranked []model.SnapshotRank
+		if err := config.DB.Where("snapshot_id IN ? AND user_id = ?", ids, query.UserId).Find(&ranked).Error; err != nil {
+			return entity.RankTimeline{}, err
+		}
+		for _, row := range ranked {
+			rows[row.SnapshotId] = row
+		}
+	}
+
+	timeline := entity.RankTimeline{
+		LeaderboardId: board.ID,
+		UserId:        query.UserId,
+		Window:        query.Window,
+		Points:        make([]entity.TimelinePoint, 0, len(snapshots)),
+	}
+	for _, snapshot := range snapshots {
+		point := entity.TimelinePoint{At: snapshot.TakenAt, PeriodStart: snapshot.PeriodStart, Total: snapshot.Entries}
+		if row, ok := rows[snapshot.ID]; ok {
+			point.Rank = &row.Rank
+			point.Score = &row.Score
+		}
+		timeline.Points = append(timeline.Points, point)
+	}
+	return timeline, nil
+}
+
+// PruneSnapshots deletes the snapshots older than each board's history retention. A board that fails
+// is skipped and the others are still pruned, the errors are returned together.
+func (s *snapshotservice) PruneSnapshots() error {
+	var boards []model.Leaderboard
+	if err := config.DB.Select("id", "history_days").Find(&boards).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for _, board := range boards {
+		cutoff := time.Now().AddDate(0, 0, -board.HistoryDays)
+		err := config.DB.Transaction(func(tx *gorm.DB) error {
+			old := tx.Model(&model.RankSnapshot{}).Select("id").Where("leaderboard_id = ? AND taken_at < ?", board.ID, cutoff)
+			if err := tx.Where("snapshot_id IN (?)", old).Delete(&model.SnapshotRank{}).Error; err != nil {
+				return err
+			}
+			return tx.Where("leaderboard_id = ? AND taken_at < ?", board.ID, cutoff).Delete(&model.RankSnapshot{}).Error
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("leaderboard %d: %w", board.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// snapshotBoard records the ranks of the current period of every window of a board, the open season's
+// included
+func snapshotBoard(board model.Leaderboard) ([]model.RankSnapshot, error) {
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	periods, err := snapshotPeriods(board, now)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]model.RankSnapshot, 0, len(periods))
+	for _, p := range periods {
+		snapshot, err := snapshotPeriod(board, p, now)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// snapshotPeriods returns the periods of a board open at now, the running season included
+func snapshotPeriods(board model.Leaderboard, now time.Time) ([]period, error) {
+	periods, err := currentPeriods(board, now)
+	if err != nil {
+		return nil, err
+	}
+	season, ok, err := openSeasonPeriod(config.DB, board.ID)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		periods = append(periods, season)
+	}
+	return periods, nil
+}
+
+// snapshotPeriod copies the ranks of one period of a board into a snapshot taken at now
+func snapshotPeriod(board model.Leaderboard, p period, now time.Time) (model.RankSnapshot, error) {
+	snapshot := model.RankSnapshot{LeaderboardId: board.ID, Window: p.Window, PeriodStart: p.Start, TakenAt: now}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&snapshot).Error; err != nil {
+			return err
+		}
+		// copy the ranked entries a batch at a time
+		counter := tieCounter{board: board}
+		err := rankedBatches(tx, board, p, standingsBatch, func(entries []model.LeaderboardEntry) error {
+			rows := make([]model.SnapshotRank, 0, len(entries))
+			for _, entry := range entries {
+				rows = append(rows, model.SnapshotRank{
+					SnapshotId: snapshot.ID,
+					UserId:     entry.UserId,
+					Rank:       counter.next(entry.Score),
+					Score:      entry.Score,
+				})
+			}
+			snapshot.Entries += len(rows)
+			return tx.Create(&rows).Error
+		})
+		if err != nil {
+			return err
+		}
+		return tx.Model(&snapshot).Update("entries", snapshot.Entries).Error
+	})
+	return snapshot, err
+}
+
+// validateSnapshotWindow checks a window is one the board is snapshotted in
+func validateSnapshotWindow(board model.Leaderboard, window string) error {
+	if window == WindowSeason {
+		return nil
+	}
+	_, err := periodAt(board, window, time.Now())
+	return err
+}
+
+func toSnapshotEntity(snapshot model.RankSnapshot) entity.RankSnapshot {
+	return entity.RankSnapshot{
+		ID:            snapshot.ID,
+		LeaderboardId: snapshot.LeaderboardId,
+		Window:        snapshot.Window,
+		PeriodStart:   snapshot.PeriodStart,
+		TakenAt:       snapshot.TakenAt,
+		Entries:       snapshot.Entries,
+	}
+}
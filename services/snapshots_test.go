@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+// TestDeltasAfterReset compares a daily window that reset since its last snapshot with the day before
+func TestDeltasAfterReset(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b")
+	created, err := NewLeaderboardService().CreateLeaderboard(entity.Leaderboard{Name: "daily", Game: "game"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	board, err := findLeaderboard(config.DB, created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	today, err := periodAt(board, WindowDaily, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := func(start, taken time.Time, ranks ...string) model.RankSnapshot {
+		t.Helper()
+		s := model.RankSnapshot{LeaderboardId: board.ID, Window: WindowDaily, PeriodStart: start, TakenAt: taken, Entries: len(ranks)}
+		if err := config.DB.Create(&s).Error; err != nil {
+			t.Fatal(err)
+		}
+		for i, userId := range ranks {
+			row := model.SnapshotRank{SnapshotId: s.ID, UserId: userId, Rank: i + 1}
+			if err := config.DB.Create(&row).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		return s
+	}
+	yesterday := snapshot(today.Start.AddDate(0, 0, -1), today.Start.Add(-time.Hour), "a", "b")
+	// the snapshot taken as the day reset ranked nobody yet
+	snapshot(today.Start, today.Start)
+	submit(t, board.ID, "a", 10)
+	submit(t, board.ID, "b", 20)
+
+	deltas := func() string {
+		t.Helper()
+		page, err := NewSnapshotService().Deltas(entity.DeltaQuery{LeaderboardQuery: entity.LeaderboardQuery{LeaderboardId: board.ID, Window: WindowDaily, Limit: 10}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := fmt.Sprint(page.Snapshot.ID)
+		for _, entry := range page.Entries {
+			got += fmt.Sprintf(" %s%+d", entry.UserId, entry.Change)
+		}
+		return got
+	}
+	if got, want := deltas(), fmt.Sprintf("%d b+1 a-1", yesterday.ID); got != want {
+		t.Errorf("deltas against the day before: got %s, want %s", got, want)
+	}
+	// once a snapshot of today ranks the players it is the baseline
+	latest := snapshot(today.Start, today.Start.Add(time.Minute), "b", "a")
+	if got, want := deltas(), fmt.Sprintf("%d b+0 a+0", latest.ID); got != want {
+		t.Errorf("deltas against today: got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,574 @@
+package services
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"github.com/JohnnyOhms/projectx/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Roles of a team member
+const (
+	RoleOwner   = "owner"
+	RoleOfficer = "officer"
+	RoleMember  = "member"
+)
+
+// Team request kinds and states
+const (
+	RequestInvite   = "invite"
+	RequestJoin     = "join"
+	RequestPending  = "pending"
+	RequestAccepted = "accepted"
+	RequestDeclined = "declined"
+)
+
+// Rules a team board aggregates member entries with
+const (
+	TeamRuleSum  = "sum"
+	TeamRuleBest = "best"
+	// TeamRuleTopK averages the best TopK member entries
+	TeamRuleTopK = "top_k"
+)
+
+var (
+	// ErrNotTeamOfficer is returned when a player without the rights acts for a team
+	ErrNotTeamOfficer = errors.New("only the team owner or an officer can do this")
+	// ErrAlreadyInTeam is returned when a player in a team is added to another one
+	ErrAlreadyInTeam = errors.New("the player is already in a team")
+)
+
+// TeamService is an interface for teams, their members and team leaderboards
+type TeamService interface {
+	CreateTeam(ownerId string, team entity.Team) (entity.Team, error)
+	FindTeam(id uint) (entity.Team, error)
+	Invite(teamId uint, actorId string, userId string) (entity.TeamRequest, error)
+	RequestJoin(teamId uint, userId string) (entity.TeamRequest, error)
+	ListRequests(teamId uint, actorId string) ([]entity.TeamRequest, error)
+	MyInvites(userId string) ([]entity.TeamRequest, error)
+	Respond(requestId uint, actorId string, accept bool) (entity.TeamRequest, error)
+	SetRole(teamId uint, actorId string, userId string, role string) error
+	RemoveMember(teamId uint, actorId string, userId string) error
+	CreateTeamBoard(board entity.TeamBoard) (entity.TeamBoard, error)
+	TeamEntries(teamBoardId uint, query entity.LeaderboardQuery) ([]entity.TeamEntry, error)
+}
+
+// teamservice is an implementation of TeamService
+type teamservice struct{}
+
+// NewTeamService creates and returns a new instance of TeamService
+func NewTeamService() TeamService {
+	return &teamservice{}
+}
+
+// CreateTeam creates a team owned by the player creating it
+func (s *teamservice) CreateTeam(ownerId string, team entity.Team) (entity.Team, error) {
+	team.Name = strings.TrimSpace(team.Name)
+	if team.Name == "" || len(team.Name) > 50 {
+		return entity.Team{}, invalid("name must be between 1 and 50 characters")
+	}
+	if len(team.Tag) > 10 {
+		return entity.Team{}, invalid("tag must be at most 10 characters")
+	}
+
+	newTeam := model.Team{Name: team.Name, Tag: team.Tag, OwnerId: ownerId}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newTeam).Error; err != nil {
+			return err
+		}
+		return addMember(tx, newTeam.ID, ownerId, RoleOwner)
+	})
+	if err != nil {
+		return entity.Team{}, err
+	}
+	return s.FindTeam(newTeam.ID)
+}
+
+// FindTeam returns a team with its members
+func (s *teamservice) FindTeam(id uint) (entity.Team, error) {
+	var team model.Team
+	if err := config.DB.First(&team, id).Error; err != nil {
+		return entity.Team{}, err
+	}
+	var members []model.TeamMember
+	if err := config.DB.Where("team_id = ?", id).Order("id").Find(&members).Error; err != nil {
+		return entity.Team{}, err
+	}
+
+	res := entity.Team{
+		ID:      team.ID,
+		Name:    team.Name,
+		Tag:     team.Tag,
+		OwnerId: team.OwnerId,
+		Members: make([]entity.TeamMember, 0, len(members)),
+	}
+	for _, m := range members {
+		res.Members = append(res.Members, entity.TeamMember{UserId: m.UserId, Role: m.Role, JoinedAt: m.CreatedAt})
+	}
+	return res, nil
+}
+
+// Invite asks a player to join the team, only the owner and officers can invite
+func (s *teamservice) Invite(teamId uint, actorId string, userId string) (entity.TeamRequest, error) {
+	if err := requireOfficer(config.DB, teamId, actorId); err != nil {
+		return entity.TeamRequest{}, err
+	}
+	return openRequest(teamId, userId, RequestInvite, actorId)
+}
+
+// RequestJoin asks a team to let the player in
+func (s *teamservice) RequestJoin(teamId uint, userId string) (entity.TeamRequest, error) {
+	if err := config.DB.First(&model.Team{}, teamId).Error; err != nil {
+		return entity.TeamRequest{}, err
+	}
+	return openRequest(teamId, userId, RequestJoin, userId)
+}
+
+// ListRequests returns the pending invites and join requests of a team
+func (s *teamservice) ListRequests(teamId uint, actorId string) ([]entity.TeamRequest, error) {
+	if err := requireOfficer(config.DB, teamId, actorId); err != nil {
+		return nil, err
+	}
+	return findRequests(config.DB.Where("team_id = ? AND status = ?", teamId, RequestPending))
+}
+
+// MyInvites returns the pending invites sent to a player
+func (s *teamservice) MyInvites(userId string) ([]entity.TeamRequest, error) {
+	return findRequests(config.DB.Where("user_id = ? AND kind = ? AND status = ?", userId, RequestInvite, RequestPending))
+}
+
+// Respond accepts or declines a request. Invites are answered by the invited player,
+// join requests by the owner or an officer of the team.
+func (s *teamservice) Respond(requestId uint, actorId string, accept bool) (entity.TeamRequest, error) {
+	var req model.TeamRequest
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&req, requestId).Error; err != nil {
+			return err
+		}
+		if req.Status != RequestPending {
+			return invalid("the request was already answered")
+		}
+		if req.Kind == RequestInvite && req.UserId != actorId {
+			return gorm.ErrRecordNotFound
+		}
+		if req.Kind == RequestJoin {
+			if err := requireOfficer(tx, req.TeamId, actorId); err != nil {
+				return err
+			}
+		}
+
+		req.Status = RequestDeclined
+		if accept {
+			req.Status = RequestAccepted
+			if err := addMember(tx, req.TeamId, req.UserId, RoleMember); err != nil {
+				return err
+			}
+		}
+		return tx.Save(&req).Error
+	})
+	if err != nil {
+		return entity.TeamRequest{}, err
+	}
+	return toTeamRequestEntity(req), nil
+}
+
+// SetRole changes the role of a member, only the owner can. Making a member the owner hands the team over.
+func (s *teamservice) SetRole(teamId uint, actorId string, userId string, role string) error {
+	switch role {
+	case RoleOwner, RoleOfficer, RoleMember:
+	default:
+		return invalid("role must be owner, officer or member")
+	}
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		team, err := lockTeam(tx, teamId)
+		if err != nil {
+			return err
+		}
+		if team.OwnerId != actorId {
+			return ErrNotTeamOfficer
+		}
+		if userId == actorId {
+			return invalid("the owner has to hand the team to another member")
+		}
+		member, err := findMember(tx, teamId, userId)
+		if err != nil {
+			return err
+		}
+		if role == RoleOwner {
+			if err := tx.Model(&model.TeamMember{}).Where("team_id = ? AND user_id = ?", teamId, actorId).Update("role", RoleOfficer).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&team).Update("owner_id", userId).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&member).Update("role", role).Error
+	})
+}
+
+// RemoveMember takes a player out of a team. Players can leave on their own, officers can remove members
+// and the owner anyone. The owner can only leave last, which disbands the team and drops its pending requests.
+func (s *teamservice) RemoveMember(teamId uint, actorId string, userId string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		team, err := lockTeam(tx, teamId)
+		if err != nil {
+			return err
+		}
+		member, err := findMember(tx, teamId, userId)
+		if err != nil {
+			return err
+		}
+		if actorId != userId {
+			actor, err := findMember(tx, teamId, actorId)
+			if err != nil || (actor.Role != RoleOwner && (actor.Role != RoleOfficer || member.Role != RoleMember)) {
+				return ErrNotTeamOfficer
+			}
+		}
+
+		if member.Role == RoleOwner {
+			var count int64
+			if err := tx.Model(&model.TeamMember{}).Where("team_id = ?", teamId).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 1 {
+				return invalid("the owner has to hand the team to another member before leaving")
+			}
+		}
+		if err := tx.Delete(&member).Error; err != nil {
+			return err
+		}
+		if member.Role == RoleOwner {
+			if err := tx.Where("team_id = ?", teamId).Delete(&model.TeamEntry{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("team_id = ? AND status = ?", teamId, RequestPending).Delete(&model.TeamRequest{}).Error; err != nil {
+				return err
+			}
+			// deleted for good so a new team can take the name
+			return tx.Unscoped().Delete(&team).Error
+		}
+		return recomputeTeam(tx, teamId)
+	})
+}
+
+// CreateTeamBoard adds a team board ranking teams on a player leaderboard
+func (s *teamservice) CreateTeamBoard(board entity.TeamBoard) (entity.TeamBoard, error) {
+	if board.Rule == "" {
+		board.Rule = TeamRuleSum
+	}
+	switch board.Rule {
+	case TeamRuleSum, TeamRuleBest:
+		board.TopK = 0
+	case TeamRuleTopK:
+		if board.TopK < 1 || board.TopK > 100 {
+			return entity.TeamBoard{}, invalid("top_k must be between 1 and 100")
+		}
+	default:
+		return entity.TeamBoard{}, invalid("rule must be sum, best or top_k")
+	}
+
+	source, err := findLeaderboard(config.DB, board.LeaderboardId)
+	if err != nil {
+		return entity.TeamBoard{}, err
+	}
+	newBoard := model.TeamBoard{
+		LeaderboardId: source.ID,
+		Name:          board.Name,
+		Rule:          board.Rule,
+		TopK:          board.TopK,
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&newBoard).Error; err != nil {
+			return err
+		}
+		// fill the live periods with the teams that already have entries
+		var teamIds []uint
+		if err := tx.Model(&model.Team{}).Pluck("id", &teamIds).Error; err != nil {
+			return err
+		}
+		return forLivePeriods(tx, source, func(p period) error {
+			for _, teamId := range teamIds {
+				if err := updateTeamEntry(tx, newBoard, source, p, teamId); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return entity.TeamBoard{}, err
+	}
+	board.ID = newBoard.ID
+	return board, nil
+}
+
+// TeamEntries returns the top teams of a team board for the window and period of the query
+func (s *teamservice) TeamEntries(teamBoardId uint, query entity.LeaderboardQuery) ([]entity.TeamEntry, error) {
+	var teamBoard model.TeamBoard
+	if err := config.DB.First(&teamBoard, teamBoardId).Error; err != nil {
+		return nil, err
+	}
+	board, err := findLeaderboard(config.DB, teamBoard.LeaderboardId)
+	if err != nil {
+		return nil, err
+	}
+	p, err := queryPeriod(config.DB, board, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []model.TeamEntry
+	result := config.DB.Where("team_board_id = ? AND time_window = ? AND period_start = ?", teamBoard.ID, p.Window, p.Start).
+		Order("score " + board.SortOrder + ", id").
+		Limit(query.Limit).
+		Find(&entries)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	teamIds := make([]uint, len(entries))
+	for i, entry := range entries {
+		teamIds[i] = entry.TeamId
+	}
+	var teams []model.Team
+	if err := config.DB.Where("id IN ?", teamIds).Find(&teams).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(teams))
+	for _, team := range teams {
+		names[team.ID] = team.Name
+	}
+
+	list := make([]entity.TeamEntry, 0, len(entries))
+	for i, entry := range entries {
+		list = append(list, entity.TeamEntry{
+			Rank:           i + 1,
+			TeamId:         entry.TeamId,
+			TeamName:       names[entry.TeamId],
+			Window:         entry.Window,
+			PeriodStart:    entry.PeriodStart,
+			Score:          entry.Score,
+			FormattedScore: utils.FormatScore(entry.Score, board.Format, board.Precision),
+			Members:        entry.Members,
+		})
+	}
+	return list, nil
+}
+
+// teamOf returns the team a player is in, nil when they have none
+func teamOf(db *gorm.DB, userId string) (*uint, error) {
+	var member model.TeamMember
+	result := db.Where("user_id = ?", userId).Limit(1).Find(&member)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+	return &member.TeamId, nil
+}
+
+// addMember puts a player in a team and counts their entries toward it, the team may have been disbanded
+// since the request was sent
+func addMember(tx *gorm.DB, teamId uint, userId string, role string) error {
+	if _, err := lockTeam(tx, teamId); err != nil {
+		return err
+	}
+	if team, err := teamOf(tx, userId); err != nil {
+		return err
+	} else if team != nil {
+		return ErrAlreadyInTeam
+	}
+	if err := tx.Create(&model.TeamMember{TeamId: teamId, UserId: userId, Role: role}).Error; err != nil {
+		return err
+	}
+	return recomputeTeam(tx, teamId)
+}
+
+// openRequest records an invite or join request unless the same one is already pending
+func openRequest(teamId uint, userId string, kind string, createdBy string) (entity.TeamRequest, error) {
+	if team, err := teamOf(config.DB, userId); err != nil {
+		return entity.TeamRequest{}, err
+	} else if team != nil {
+		return entity.TeamRequest{}, ErrAlreadyInTeam
+	}
+
+	var pending int64
+	result := config.DB.Model(&model.TeamRequest{}).
+		Where("team_id = ? AND user_id = ? AND status = ?", teamId, userId, RequestPending).
+		Count(&pending)
+	if result.Error != nil {
+		return entity.TeamRequest{}, result.Error
+	}
+	if pending > 0 {
+		return entity.TeamRequest{}, invalid("a request between the player and the team is already pending")
+	}
+
+	req := model.TeamRequest{TeamId: teamId, UserId: userId, Kind: kind, Status: RequestPending, CreatedBy: createdBy}
+	if err := config.DB.Create(&req).Error; err != nil {
+		return entity.TeamRequest{}, err
+	}
+	return toTeamRequestEntity(req), nil
+}
+
+func findRequests(db *gorm.DB) ([]entity.TeamRequest, error) {
+	var requests []model.TeamRequest
+	if err := db.Order("id").Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	list := make([]entity.TeamRequest, 0, len(requests))
+	for _, req := range requests {
+		list = append(list, toTeamRequestEntity(req))
+	}
+	return list, nil
+}
+
+// requireOfficer checks the player is the owner or an officer of the team
+func requireOfficer(db *gorm.DB, teamId uint, userId string) error {
+	member, err := findMember(db, teamId, userId)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && member.Role == RoleMember) {
+		return ErrNotTeamOfficer
+	}
+	return err
+}
+
+func findMember(db *gorm.DB, teamId uint, userId string) (model.TeamMember, error) {
+	var member model.TeamMember
+	result := db.Where("team_id = ? AND user_id = ?", teamId, userId).First(&member)
+	if result.Error != nil {
+		return model.TeamMember{}, result.Error
+	}
+	return member, nil
+}
+
+// lockTeam loads a team and holds it so membership changes queue behind each other
+func lockTeam(tx *gorm.DB, id uint) (model.Team, error) {
+	var team model.Team
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&team, id)
+	if result.Error != nil {
+		return model.Team{}, result.Error
+	}
+	return team, nil
+}
+
+// recomputeTeam rebuilds the live team entries of a team after its members changed
+func recomputeTeam(tx *gorm.DB, teamId uint) error {
+	var teamBoards []model.TeamBoard
+	if err := tx.Find(&teamBoards).Error; err != nil {
+		return err
+	}
+	for _, teamBoard := range teamBoards {
+		board, err := findLeaderboard(tx, teamBoard.LeaderboardId)
+		if err != nil {
+			return err
+		}
+		err = forLivePeriods(tx, board, func(p period) error {
+			return updateTeamEntry(tx, teamBoard, board, p, teamId)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateTeamScores refreshes the team entries a player's written entries count toward
+func updateTeamScores(tx *gorm.DB, board model.Leaderboard, userId string, periods []period) error {
+	teamId, err := teamOf(tx, userId)
+	if err != nil || teamId == nil {
+		return err
+	}
+	var teamBoards []model.TeamBoard
+	if err := tx.Where("leaderboard_id = ?", board.ID).Find(&teamBoards).Error; err != nil {
+		return err
+	}
+	for _, teamBoard := range teamBoards {
+		for _, p := range periods {
+			if err := updateTeamEntry(tx, teamBoard, board, p, *teamId); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// updateTeamEntry aggregates the member entries of one period by the team board's rule
+func updateTeamEntry(tx *gorm.DB, teamBoard model.TeamBoard, board model.Leaderboard, p period, teamId uint) error {
+	members := tx.Model(&model.TeamMember{}).Select("user_id").Where("team_id = ?", teamId)
+	var scores []float64
+	result := periodEntries(tx, board, p).
+		Where("user_id IN (?)", members).
+		Order("score "+board.SortOrder).
+		Pluck("score", &scores)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	scope := tx.Where("team_board_id = ? AND time_window = ? AND period_start = ? AND team_id = ?", teamBoard.ID, p.Window, p.Start, teamId)
+	if len(scores) == 0 {
+		return scope.Unscoped().Delete(&model.TeamEntry{}).Error
+	}
+
+	var score float64
+	switch teamBoard.Rule {
+	case TeamRuleBest:
+		score = scores[0]
+	case TeamRuleTopK:
+		top := scores[:min(teamBoard.TopK, len(scores))]
+		for _, s := range top {
+			score += s
+		}
+		score /= float64(len(top))
+	default:
+		for _, s := range scores {
+			score += s
+		}
+	}
+
+	entry := model.TeamEntry{
+		TeamBoardId: teamBoard.ID,
+		Window:      p.Window,
+		PeriodStart: p.Start,
+		TeamId:      teamId,
+		Score:       score,
+		Members:     len(scores),
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "team_board_id"}, {Name: "time_window"}, {Name: "period_start"}, {Name: "team_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"score", "members", "updated_at"}),
+	}).Create(&entry).Error
+}
+
+// forLivePeriods calls fn for every period of the board that still receives scores
+func forLivePeriods(tx *gorm.DB, board model.Leaderboard, fn func(p period) error) error {
+	periods, err := currentPeriods(board, time.Now())
+	if err != nil {
+		return err
+	}
+	if p, ok, err := openSeasonPeriod(tx, board.ID); err != nil {
+		return err
+	} else if ok {
+		periods = append(periods, p)
+	}
+	for _, p := range periods {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func toTeamRequestEntity(req model.TeamRequest) entity.TeamRequest {
+	return entity.TeamRequest{
+		ID:        req.ID,
+		TeamId:    req.TeamId,
+		UserId:    req.UserId,
+		Kind:      req.Kind,
+		Status:    req.Status,
+		CreatedBy: req.CreatedBy,
+		CreatedAt: req.CreatedAt,
+	}
+}
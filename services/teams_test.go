@@ -0,0 +1,70 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+)
+
+func TestDisbandFreesNameAndRequests(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b", "c")
+	s := NewTeamService()
+	team, err := s.CreateTeam("a", entity.Team{Name: "red"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	invite, err := s.Invite(team.ID, "a", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveMember(team.ID, "a", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	invites, err := s.MyInvites("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(invites) != 0 {
+		t.Errorf("got %d invites from a disbanded team, want none", len(invites))
+	}
+	if _, err := s.Respond(invite.ID, "b", true); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("accepting an invite of a disbanded team: got %v, want ErrRecordNotFound", err)
+	}
+	if _, err := s.CreateTeam("c", entity.Team{Name: "red"}); err != nil {
+		t.Errorf("reusing the name of a disbanded team: %v", err)
+	}
+}
+
+func TestRespondChecksTeamExists(t *testing.T) {
+	testDB(t)
+	createUsers(t, "a", "b")
+	s := NewTeamService()
+	team, err := s.CreateTeam("a", entity.Team{Name: "red"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := s.RequestJoin(team.ID, "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the team goes away between the request and the answer
+	if err := config.DB.Unscoped().Delete(&model.Team{}, team.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Respond(req.ID, "a", true); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("joining a deleted team: got %v, want ErrRecordNotFound", err)
+	}
+	var members int64
+	if err := config.DB.Model(&model.TeamMember{}).Where("user_id = ?", "b").Count(&members).Error; err != nil {
+		t.Fatal(err)
+	}
+	if members != 0 {
+		t.Error("the player joined a deleted team")
+	}
+}
@@ -0,0 +1,936 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Tournament formats
+const (
+	FormatSingleElimination = "single"
+	FormatDoubleElimination = "double"
+	FormatSwiss             = "swiss"
+)
+
+// Tournament states
+const (
+	TournamentRunning  = "running"
+	TournamentFinished = "finished"
+)
+
+// Brackets a tournament match is played in
+const (
+	BracketWinners = "winners"
+	BracketLosers  = "losers"
+	BracketFinal   = "final"
+	BracketSwiss   = "swiss"
+)
+
+// Tournament match states, a waiting match still misses a player from an earlier match
+const (
+	MatchWaiting = "waiting"
+	MatchReady   = "ready"
+	MatchDone    = "done"
+)
+
+const (
+	// maxTournamentPlayers caps the size of a bracket
+	maxTournamentPlayers = 256
+	// maxPlacementPoints is how many placements may award points
+	maxPlacementPoints = 64
+	// maxPairingSteps bounds the search for a Swiss round without repeated pairings
+	maxPairingSteps = 100000
+)
+
+var (
+	// ErrTournamentFinished is returned when a result is reported to a tournament that is over
+	ErrTournamentFinished = errors.New("tournament is finished")
+	// ErrMatchNotReady is returned when a result is reported to a match that is waiting for players or done
+	ErrMatchNotReady = errors.New("match is not ready to be played")
+)
+
+// TournamentService is an interface for tournaments and their brackets
+type TournamentService interface {
+	CreateTournament(tournament entity.Tournament) (entity.TournamentBracket, error)
+	ListTournaments() ([]entity.Tournament, error)
+	FindTournament(id uint) (entity.TournamentBracket, error)
+	ReportResult(tournamentId, matchId uint, report entity.MatchReport) (entity.TournamentBracket, error)
+	Standings(id uint) ([]entity.TournamentPlayer, error)
+}
+
+// tournamentservice is an implementation of TournamentService
+type tournamentservice struct{}
+
+// NewTournamentService creates and returns a new instance of TournamentService
+func NewTournamentService() TournamentService {
+	return &tournamentservice{}
+}
+
+// CreateTournament seeds the players and draws the first round, from then on reported results move the bracket
+func (s *tournamentservice) CreateTournament(tournament entity.Tournament) (entity.TournamentBracket, error) {
+	if err := validateTournament(tournament); err != nil {
+		return entity.TournamentBracket{}, err
+	}
+	players, err := tournamentSeeds(tournament)
+	if err != nil {
+		return entity.TournamentBracket{}, err
+	}
+	minPlayers := 2
+	if tournament.Format == FormatDoubleElimination {
+		minPlayers = 4
+	}
+	if len(players) < minPlayers || len(players) > maxTournamentPlayers {
+		return entity.TournamentBracket{}, invalidf("a %s tournament needs between %d and %d players", tournament.Format, minPlayers, maxTournamentPlayers)
+	}
+
+	points := make([]string, 0, len(tournament.Points))
+	for _, p := range tournament.Points {
+		points = append(points, strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	t := model.Tournament{
+		Name:                tournament.Name,
+		Format:              tournament.Format,
+		Status:              TournamentRunning,
+		Rounds:              tournament.Rounds,
+		PointsLeaderboardId: tournament.PointsLeaderboardId,
+		Points:              strings.Join(points, ","),
+	}
+	if t.Format == FormatSwiss {
+		t.Round = 1
+		if t.Rounds == 0 {
+			// enough rounds for one player to be left unbeaten
+			t.Rounds = bits.Len(uint(len(players) - 1))
+		}
+		if t.Rounds >= len(players) {
+			return entity.TournamentBracket{}, invalid("a swiss tournament must have fewer rounds than players")
+		}
+	}
+
+	var awards [][]entryWrite
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&t).Error; err != nil {
+			return err
+		}
+		rows := make([]model.TournamentPlayer, 0, len(players))
+		for i, userId := range players {
+			rows = append(rows, model.TournamentPlayer{TournamentId: t.ID, UserId: userId, Seed: i + 1})
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+
+		b := &bracket{tx: tx, t: &t}
+		var err error
+		if t.Format == FormatSwiss {
+			err = b.pairRound()
+		} else {
+			err = b.drawElimination(players)
+		}
+		awards = b.awards
+		return err
+	})
+	if err != nil {
+		return entity.TournamentBracket{}, err
+	}
+	applyAwards(t, awards)
+	return s.FindTournament(t.ID)
+}
+
+// ListTournaments returns every tournament, newest first
+func (s *tournamentservice) ListTournaments() ([]entity.Tournament, error) {
+	var tournaments []model.Tournament
+	if err := config.DB.Order("id desc").Find(&tournaments).Error; err != nil {
+		return nil, err
+	}
+	list := make([]entity.Tournament, 0, len(tournaments))
+	for _, t := range tournaments {
+		list = append(list, toTournamentEntity(t))
+	}
+	return list, nil
+}
+
+// FindTournament returns a tournament with its players in seed order and every match drawn so far
+func (s *tournamentservice) FindTournament(id uint) (entity.TournamentBracket, error) {
+	var t model.Tournament
+	if err := config.DB.First(&t, id).Error; err != nil {
+		return entity.TournamentBracket{}, err
+	}
+	var players []model.TournamentPlayer
+	if err := config.DB.Where("tournament_id = ?", t.ID).Order("seed").Find(&players).Error; err != nil {
+		return entity.TournamentBracket{}, err
+	}
+	var matches []model.TournamentMatch
+	if err := config.DB.Where("tournament_id = ?", t.ID).Order("id").Find(&matches).Error; err != nil {
+		return entity.TournamentBracket{}, err
+	}
+
+	bracket := entity.TournamentBracket{
+		Tournament: toTournamentEntity(t),
+		Players:    make([]entity.TournamentPlayer, 0, len(players)),
+		Matches:    make([]entity.TournamentMatch, 0, len(matches)),
+	}
+	for _, p := range players {
+		bracket.Players = append(bracket.Players, toTournamentPlayerEntity(p))
+	}
+	for _, m := range matches {
+		bracket.Matches = append(bracket.Matches, entity.TournamentMatch{
+			ID:       m.ID,
+			Bracket:  m.Bracket,
+			Round:    m.Round,
+			Number:   m.Number,
+			PlayerA:  m.PlayerA,
+			PlayerB:  m.PlayerB,
+			WinnerId: m.WinnerId,
+			Draw:     m.Draw,
+			Status:   m.Status,
+		})
+	}
+	return bracket, nil
+}
+
+// ReportResult records the result of a ready match and advances the bracket, the tournament is locked
+// so two results never advance it at the same time
+func (s *tournamentservice) ReportResult(tournamentId, matchId uint, report entity.MatchReport) (entity.TournamentBracket, error) {
+	var t model.Tournament
+	var awards [][]entryWrite
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&t, tournamentId).Error; err != nil {
+			return err
+		}
+		if t.Status == TournamentFinished {
+			return ErrTournamentFinished
+		}
+		var m model.TournamentMatch
+		if err := tx.Where("tournament_id = ?", t.ID).First(&m, matchId).Error; err != nil {
+			return err
+		}
+		if m.Status != MatchReady {
+			return ErrMatchNotReady
+		}
+		if report.Draw {
+			if t.Format != FormatSwiss {
+				return invalid("only a swiss match can end in a draw")
+			}
+		} else if report.WinnerId != m.PlayerA && report.WinnerId != m.PlayerB {
+			return invalid("winner_id must be one of the players of the match")
+		}
+
+		b := &bracket{tx: tx, t: &t}
+		var err error
+		if t.Format == FormatSwiss {
+			err = b.recordSwiss(&m, report)
+		} else {
+			loser := m.PlayerA
+			if report.WinnerId == m.PlayerA {
+				loser = m.PlayerB
+			}
+			err = b.complete(&m, report.WinnerId, loser)
+		}
+		awards = b.awards
+		return err
+	})
+	if err != nil {
+		return entity.TournamentBracket{}, err
+	}
+	applyAwards(t, awards)
+	return s.FindTournament(t.ID)
+}
+
+// Standings returns the players by placement, players still in the running come last by seed
+func (s *tournamentservice) Standings(id uint) ([]entity.TournamentPlayer, error) {
+	var t model.Tournament
+	if err := config.DB.First(&t, id).Error; err != nil {
+		return nil, err
+	}
+	var players []model.TournamentPlayer
+	result := config.DB.Where("tournament_id = ?", t.ID).
+		Order("CASE WHEN placement = 0 THEN 1 ELSE 0 END, placement, seed").Find(&players)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	list := make([]entity.TournamentPlayer, 0, len(players))
+	for _, p := range players {
+		list = append(list, toTournamentPlayerEntity(p))
+	}
+	return list, nil
+}
+
+// bracket moves a tournament forward inside a transaction, awards collects the leaderboard writes of
+// the points given out when it finishes
+type bracket struct {
+	tx     *gorm.DB
+	t      *model.Tournament
+	awards [][]entryWrite
+}
+
+// bracketMatch is a match being drawn, next and loser point at the matches its winner and loser go to
+type bracketMatch struct {
+	match     model.TournamentMatch
+	next      int
+	nextSlot  string
+	loser     int
+	loserSlot string
+}
+
+// drawElimination creates every match of a single or double elimination bracket and plays out the byes.
+// Seeds are placed so the best ones meet last and the byes go to the top seeds.
+func (b *bracket) drawElimination(players []string) error {
+	size := 1 << bits.Len(uint(len(players)-1))
+	rounds := bits.Len(uint(size)) - 1
+	double := b.t.Format == FormatDoubleElimination
+
+	var drawn []*bracketMatch
+	add := func(bracketName string, round, number, stage, feeds int) *bracketMatch {
+		m := &bracketMatch{next: -1, loser: -1, match: model.TournamentMatch{
+			TournamentId: b.t.ID,
+			Bracket:      bracketName,
+			Round:        round,
+			Number:       number,
+			Stage:        stage,
+			Status:       MatchWaiting,
+			PendingFeeds: feeds,
+		}}
+		drawn = append(drawn, m)
+		return m
+	}
+	feed := func(from *bracketMatch, to int, slot int, loser bool) {
+		slotName := "a"
+		if slot%2 == 1 {
+			slotName = "b"
+		}
+		if loser {
+			from.loser, from.loserSlot = to, slotName
+		} else {
+			from.next, from.nextSlot = to, slotName
+		}
+	}
+
+	// the winners bracket, in a double elimination its losers drop down instead of going out
+	winners := make([][]int, rounds+1)
+	order := seedOrder(size)
+	for r := 1; r <= rounds; r++ {
+		stage := r
+		if double {
+			stage = 0
+		}
+		for i := 0; i < size>>r; i++ {
+			feeds := 2
+			if r == 1 {
+				feeds = 0
+			}
+			m := add(BracketWinners, r, i+1, stage, feeds)
+			if r == 1 {
+				if seed := order[2*i]; seed <= len(players) {
+					m.match.PlayerA = players[seed-1]
+				}
+				if seed := order[2*i+1]; seed <= len(players) {
+					m.match.PlayerB = players[seed-1]
+				}
+			} else {
+				feed(drawn[winners[r-1][2*i]], len(drawn)-1, 0, false)
+				feed(drawn[winners[r-1][2*i+1]], len(drawn)-1, 1, false)
+			}
+			winners[r] = append(winners[r], len(drawn)-1)
+		}
+	}
+
+	if double {
+		// the losers bracket alternates between rounds where its players meet each other and rounds
+		// where the losers of the next winners round drop in
+		var previous []int
+		for j := 1; j <= 2*(rounds-1); j++ {
+			count := size >> ((j+1)/2 + 1)
+			var current []int
+			for i := 0; i < count; i++ {
+				add(BracketLosers, j, i+1, j, 2)
+				at := len(drawn) - 1
+				switch {
+				case j == 1:
+					feed(drawn[winners[1][2*i]], at, 0, true)
+					feed(drawn[winners[1][2*i+1]], at, 1, true)
+				case j%2 == 0:
+					r := j/2 + 1
+					from := winners[r][i]
+					// drop the losers in reverse every other round so early opponents do not meet again
+					if r%2 == 0 {
+						from = winners[r][len(winners[r])-1-i]
+					}
+					feed(drawn[previous[i]], at, 0, false)
+					feed(drawn[from], at, 1, true)
+				default:
+					feed(drawn[previous[2*i]], at, 0, false)
+					feed(drawn[previous[2*i+1]], at, 1, false)
+				}
+				current = append(current, at)
+			}
+			previous = current
+		}
+		add(BracketFinal, 1, 1, 2*rounds-1, 2)
+		final := len(drawn) - 1
+		feed(drawn[winners[rounds][0]], final, 0, false)
+		feed(drawn[previous[0]], final, 1, false)
+		// the final is played again when the player from the losers bracket wins it, both have lost once then
+		add(BracketFinal, 2, 1, 0, 2)
+		feed(drawn[final], len(drawn)-1, 0, false)
+		feed(drawn[final], len(drawn)-1, 1, true)
+	}
+
+	// save the matches before linking them, the links need their ids
+	matches := make([]model.TournamentMatch, len(drawn))
+	for i, m := range drawn {
+		matches[i] = m.match
+	}
+	if err := b.tx.Create(&matches).Error; err != nil {
+		return err
+	}
+	for i, m := range drawn {
+		if m.next >= 0 {
+			matches[i].NextMatchId, matches[i].NextSlot = &matches[m.next].ID, m.nextSlot
+		}
+		if m.loser >= 0 {
+			matches[i].LoserMatchId, matches[i].LoserSlot = &matches[m.loser].ID, m.loserSlot
+		}
+		if m.next >= 0 || m.loser >= 0 {
+			result := b.tx.Model(&matches[i]).Select("NextMatchId", "NextSlot", "LoserMatchId", "LoserSlot").Updates(&matches[i])
+			if result.Error != nil {
+				return result.Error
+			}
+		}
+	}
+	for _, i := range winners[1] {
+		if err := b.settle(&matches[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// complete records the winner of an elimination match and sends both players on. A loser with nowhere
+// to go is out and placed after everyone eliminated in a later stage, the loser of a final is second.
+func (b *bracket) complete(m *model.TournamentMatch, winner, loser string) error {
+	m.WinnerId = winner
+	m.Status = MatchDone
+	if err := b.tx.Save(m).Error; err != nil {
+		return err
+	}
+	if winner != "" && loser != "" {
+		if err := b.addResult(winner, "wins", 0); err != nil {
+			return err
+		}
+		if err := b.addResult(loser, "losses", 0); err != nil {
+			return err
+		}
+	}
+
+	// the player from the winners bracket has not lost yet, winning the final ends the tournament
+	if m.Bracket == BracketFinal && m.NextMatchId != nil && winner == m.PlayerA {
+		if err := b.skip(*m.NextMatchId); err != nil {
+			return err
+		}
+		m.NextMatchId, m.LoserMatchId = nil, nil
+	}
+
+	if m.LoserMatchId != nil {
+		if err := b.feed(*m.LoserMatchId, m.LoserSlot, loser); err != nil {
+			return err
+		}
+	} else if loser != "" {
+		placement := 2
+		if m.Bracket != BracketFinal {
+			var later int64
+			result := b.tx.Model(&model.TournamentMatch{}).
+				Where("tournament_id = ? AND stage > ?", b.t.ID, m.Stage).Count(&later)
+			if result.Error != nil {
+				return result.Error
+			}
+			placement += int(later)
+		}
+		if err := b.place(loser, placement); err != nil {
+			return err
+		}
+	}
+
+	if m.NextMatchId != nil {
+		return b.feed(*m.NextMatchId, m.NextSlot, winner)
+	}
+	// the final has been played
+	if err := b.place(winner, 1); err != nil {
+		return err
+	}
+	return b.finish()
+}
+
+// feed puts a player, or nobody after a bye, into a slot of a later match and settles it once it has
+// heard from every match feeding it
+func (b *bracket) feed(id uint, slot, player string) error {
+	var m model.TournamentMatch
+	if err := b.tx.First(&m, id).Error; err != nil {
+		return err
+	}
+	if slot == "a" {
+		m.PlayerA = player
+	} else {
+		m.PlayerB = player
+	}
+	m.PendingFeeds--
+	if m.PendingFeeds > 0 {
+		return b.tx.Save(&m).Error
+	}
+	return b.settle(&m)
+}
+
+// skip closes a match that will not be played, the reset of a final the winners bracket player won
+func (b *bracket) skip(id uint) error {
+	return b.tx.Model(&model.TournamentMatch{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"status": MatchDone, "pending_feeds": 0}).Error
+}
+
+// settle opens a match with both players, a match with one player is a bye they win
+func (b *bracket) settle(m *model.TournamentMatch) error {
+	if m.PlayerA != "" && m.PlayerB != "" {
+		m.Status = MatchReady
+		return b.tx.Save(m).Error
+	}
+	return b.complete(m, m.PlayerA+m.PlayerB, "")
+}
+
+// pairRound draws the next Swiss round. Players meet others on the same points without repeating a
+// pairing when possible, with an odd count the lowest placed player without a bye gets one.
+func (b *bracket) pairRound() error {
+	players, err := b.swissOrder()
+	if err != nil {
+		return err
+	}
+	var previous []model.TournamentMatch
+	if err := b.tx.Where("tournament_id = ?", b.t.ID).Find(&previous).Error; err != nil {
+		return err
+	}
+	played := make(map[[2]string]bool, len(previous))
+	hadBye := make(map[string]bool)
+	for _, m := range previous {
+		if m.PlayerB == "" {
+			hadBye[m.PlayerA] = true
+			continue
+		}
+		played[[2]string{m.PlayerA, m.PlayerB}] = true
+		played[[2]string{m.PlayerB, m.PlayerA}] = true
+	}
+
+	unpaired := make([]string, 0, len(players))
+	for _, p := range players {
+		unpaired = append(unpaired, p.UserId)
+	}
+	bye := ""
+	if len(unpaired)%2 == 1 {
+		at := len(unpaired) - 1
+		for i := len(unpaired) - 1; i >= 0; i-- {
+			if !hadBye[unpaired[i]] {
+				at = i
+				break
+			}
+		}
+		bye = unpaired[at]
+		unpaired = slices.Delete(unpaired, at, at+1)
+	}
+
+	budget := maxPairingSteps
+	pairs := pairUp(unpaired, played, &budget)
+	if pairs == nil {
+		// every pairing repeats a match, or finding one takes too long
+		pairs = pairGreedy(unpaired, played)
+	}
+	number := 0
+	for _, pair := range pairs {
+		number++
+		m := model.TournamentMatch{
+			TournamentId: b.t.ID,
+			Bracket:      BracketSwiss,
+			Round:        b.t.Round,
+			Number:       number,
+			PlayerA:      pair[0],
+			PlayerB:      pair[1],
+			Status:       MatchReady,
+		}
+		if err := b.tx.Create(&m).Error; err != nil {
+			return err
+		}
+	}
+	if bye != "" {
+		number++
+		m := model.TournamentMatch{
+			TournamentId: b.t.ID,
+			Bracket:      BracketSwiss,
+			Round:        b.t.Round,
+			Number:       number,
+			PlayerA:      bye,
+			WinnerId:     bye,
+			Status:       MatchDone,
+		}
+		if err := b.tx.Create(&m).Error; err != nil {
+			return err
+		}
+		// a bye counts as a win
+		return b.addResult(bye, "wins", 1)
+	}
+	return nil
+}
+
+// pairUp pairs the players in order, each with the first one after them they have not met that leaves
+// the rest a pairing without repeats. It is nil when there is none or steps runs out looking for it.
+func pairUp(players []string, played map[[2]string]bool, steps *int) [][2]string {
+	if len(players) == 0 {
+		return [][2]string{}
+	}
+	a := players[0]
+	for i := 1; i < len(players); i++ {
+		if *steps <= 0 {
+			return nil
+		}
+		*steps--
+		if played[[2]string{a, players[i]}] {
+			continue
+		}
+		rest := slices.Delete(slices.Clone(players[1:]), i-1, i)
+		if pairs := pairUp(rest, played, steps); pairs != nil {
+			return append([][2]string{{a, players[i]}}, pairs...)
+		}
+	}
+	return nil
+}
+
+// pairGreedy pairs the players in order, each with the first one after them they have not met or the
+// next one when they have met everyone left
+func pairGreedy(players []string, played map[[2]string]bool) [][2]string {
+	players = slices.Clone(players)
+	var pairs [][2]string
+	for len(players) > 0 {
+		a := players[0]
+		at := 1
+		for i := 1; i < len(players); i++ {
+			if !played[[2]string{a, players[i]}] {
+				at = i
+				break
+			}
+		}
+		pairs = append(pairs, [2]string{a, players[at]})
+		players = slices.Delete(players, at, at+1)[1:]
+	}
+	return pairs
+}
+
+// recordSwiss scores a Swiss match and draws the next round once every match of this one is done
+func (b *bracket) recordSwiss(m *model.TournamentMatch, report entity.MatchReport) error {
+	m.Status = MatchDone
+	m.Draw = report.Draw
+	m.WinnerId = report.WinnerId
+	if report.Draw {
+		m.WinnerId = ""
+	}
+	if err := b.tx.Save(m).Error; err != nil {
+		return err
+	}
+	if report.Draw {
+		if err := b.addResult(m.PlayerA, "draws", 0.5); err != nil {
+			return err
+		}
+		if err := b.addResult(m.PlayerB, "draws", 0.5); err != nil {
+			return err
+		}
+	} else {
+		loser := m.PlayerA
+		if report.WinnerId == m.PlayerA {
+			loser = m.PlayerB
+		}
+		if err := b.addResult(report.WinnerId, "wins", 1); err != nil {
+			return err
+		}
+		if err := b.addResult(loser, "losses", 0); err != nil {
+			return err
+		}
+	}
+
+	var open int64
+	result := b.tx.Model(&model.TournamentMatch{}).
+		Where("tournament_id = ? AND round = ? AND status <> ?", b.t.ID, b.t.Round, MatchDone).Count(&open)
+	if result.Error != nil || open > 0 {
+		return result.Error
+	}
+	if b.t.Round < b.t.Rounds {
+		b.t.Round++
+		if err := b.tx.Model(b.t).Update("round", b.t.Round).Error; err != nil {
+			return err
+		}
+		return b.pairRound()
+	}
+
+	players, err := b.swissOrder()
+	if err != nil {
+		return err
+	}
+	for i, p := range players {
+		if err := b.place(p.UserId, i+1); err != nil {
+			return err
+		}
+	}
+	return b.finish()
+}
+
+// swissOrder returns the players by points, then by the points of the opponents they met, then by seed
+func (b *bracket) swissOrder() ([]model.TournamentPlayer, error) {
+	var players []model.TournamentPlayer
+	if err := b.tx.Where("tournament_id = ?", b.t.ID).Find(&players).Error; err != nil {
+		return nil, err
+	}
+	var matches []model.TournamentMatch
+	result := b.tx.Where("tournament_id = ? AND status = ? AND player_b <> ?", b.t.ID, MatchDone, "").Find(&matches)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	points := make(map[string]float64, len(players))
+	for _, p := range players {
+		points[p.UserId] = p.Points
+	}
+	opponents := make(map[string]float64, len(players))
+	for _, m := range matches {
+		opponents[m.PlayerA] += points[m.PlayerB]
+		opponents[m.PlayerB] += points[m.PlayerA]
+	}
+	slices.SortFunc(players, func(x, y model.TournamentPlayer) int {
+		switch {
+		case x.Points != y.Points:
+			return compareDesc(x.Points, y.Points)
+		case opponents[x.UserId] != opponents[y.UserId]:
+			return compareDesc(opponents[x.UserId], opponents[y.UserId])
+		}
+		return x.Seed - y.Seed
+	})
+	return players, nil
+}
+
+// addResult counts a win, loss or draw for a player with the Swiss points it gives
+func (b *bracket) addResult(userId, column string, points float64) error {
+	return b.tx.Model(&model.TournamentPlayer{}).
+		Where("tournament_id = ? AND user_id = ?", b.t.ID, userId).
+		Updates(map[string]interface{}{
+			column:   gorm.Expr(column + " + 1"),
+			"points": gorm.Expr("points + ?", points),
+		}).Error
+}
+
+// place sets the final placement of a player
+func (b *bracket) place(userId string, placement int) error {
+	return b.tx.Model(&model.TournamentPlayer{}).
+		Where("tournament_id = ? AND user_id = ?", b.t.ID, userId).
+		Update("placement", placement).Error
+}
+
+// finish closes the tournament and gives the placement points on its leaderboard, as accepted
+// submissions so they show in each player's history
+func (b *bracket) finish() error {
+	b.t.Status = TournamentFinished
+	if err := b.tx.Model(b.t).Update("status", TournamentFinished).Error; err != nil {
+		return err
+	}
+	if b.t.PointsLeaderboardId == nil || b.t.Points == "" {
+		return nil
+	}
+	board, err := findLeaderboard(b.tx, *b.t.PointsLeaderboardId)
+	if err != nil {
+		return err
+	}
+	points := strings.Split(b.t.Points, ",")
+	var players []model.TournamentPlayer
+	result := b.tx.Where("tournament_id = ? AND placement BETWEEN 1 AND ?", b.t.ID, len(points)).
+		Order("placement, seed").Find(&players)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	for _, p := range players {
+		score, err := strconv.ParseFloat(points[p.Placement-1], 64)
+		if err != nil {
+			return err
+		}
+		sub := model.Submission{
+			LeaderboardId: board.ID,
+			UserId:        p.UserId,
+			Score:         score,
+			Status:        SubmissionAccepted,
+			Metadata:      fmt.Sprintf(`{"tournament_id":%d,"placement":%d}`, b.t.ID, p.Placement),
+		}
+		if sub.TeamId, err = teamOf(b.tx, p.UserId); err != nil {
+			return err
+		}
+		sub.CreatedAt = now
+		if _, err := markPersonalBest(b.tx, board, &sub); err != nil {
+			return err
+		}
+		if err := b.tx.Create(&sub).Error; err != nil {
+			return err
+		}
+		writes, err := writeScore(b.tx, board, p.UserId, score, 0, now)
+		if err != nil {
+			return err
+		}
+		b.awards = append(b.awards, writes)
+	}
+	return nil
+}
+
+// applyAwards publishes the points a finished tournament wrote, one player at a time. The points are
+// committed by then so errors are only logged.
+func applyAwards(t model.Tournament, awards [][]entryWrite) {
+	if len(awards) == 0 {
+		return
+	}
+	board, err := findLeaderboard(config.DB, *t.PointsLeaderboardId)
+	if err != nil {
+		fmt.Println("Error ranking the tournament points:", err)
+		return
+	}
+	for _, writes := range awards {
+		if _, err := afterWrite(board, writes); err != nil {
+			fmt.Println("Error ranking the tournament points:", err)
+		}
+	}
+}
+
+// tournamentSeeds returns the players in seed order, from the leaderboard ranks when one is given
+func tournamentSeeds(tournament entity.Tournament) ([]string, error) {
+	if tournament.LeaderboardId == nil {
+		seen := make(map[string]bool, len(tournament.Players))
+		for _, userId := range tournament.Players {
+			if userId == "" || seen[userId] {
+				return nil, invalid("players must be distinct user ids")
+			}
+			seen[userId] = true
+		}
+		return tournament.Players, nil
+	}
+
+	if tournament.Size < 2 || tournament.Size > maxTournamentPlayers {
+		return nil, invalidf("size must be between 2 and %d", maxTournamentPlayers)
+	}
+	board, p, err := resolveQuery(entity.LeaderboardQuery{LeaderboardId: *tournament.LeaderboardId, Window: tournament.Window})
+	if err != nil {
+		return nil, err
+	}
+	entries, err := topEntries(board, p, tournament.Size)
+	if err != nil {
+		return nil, err
+	}
+	players := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		players = append(players, entry.UserId)
+	}
+	return players, nil
+}
+
+// seedOrder returns the seeds of a bracket of size players in slot order, 1 and 2 can only meet in the final
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, seed := range order {
+			next = append(next, seed, 2*len(order)+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// validateTournament checks the settings of a tournament
+func validateTournament(tournament entity.Tournament) error {
+	if strings.TrimSpace(tournament.Name) == "" || len(tournament.Name) > 100 {
+		return invalid("name must be between 1 and 100 characters")
+	}
+	switch tournament.Format {
+	case FormatSingleElimination, FormatDoubleElimination:
+		if tournament.Rounds != 0 {
+			return invalid("rounds can only be set for a swiss tournament")
+		}
+	case FormatSwiss:
+		if tournament.Rounds < 0 || tournament.Rounds > 20 {
+			return invalid("rounds must be at most 20")
+		}
+	default:
+		return invalid("format must be single, double or swiss")
+	}
+	if tournament.LeaderboardId != nil && len(tournament.Players) > 0 {
+		return invalid("players are seeded from the leaderboard or listed, not both")
+	}
+	if len(tournament.Points) > maxPlacementPoints {
+		return invalidf("at most %d placements can award points", maxPlacementPoints)
+	}
+	if len(tournament.Points) > 0 {
+		if tournament.PointsLeaderboardId == nil {
+			return invalid("points need a points_leaderboard_id")
+		}
+		if _, err := findLeaderboard(config.DB, *tournament.PointsLeaderboardId); err != nil {
+			return err
+		}
+		for _, p := range tournament.Points {
+			if math.IsNaN(p) || math.IsInf(p, 0) {
+				return invalid("points must be finite numbers")
+			}
+		}
+	}
+	return nil
+}
+
+// compareDesc orders higher numbers first
+func compareDesc(x, y float64) int {
+	switch {
+	case x > y:
+		return -1
+	case x < y:
+		return 1
+	}
+	return 0
+}
+
+func toTournamentEntity(t model.Tournament) entity.Tournament {
+	var points []float64
+	if t.Points != "" {
+		for _, p := range strings.Split(t.Points, ",") {
+			value, _ := strconv.ParseFloat(p, 64)
+			points = append(points, value)
+		}
+	}
+	return entity.Tournament{
+		ID:                  t.ID,
+		Name:                t.Name,
+		Format:              t.Format,
+		Rounds:              t.Rounds,
+		Round:               t.Round,
+		Status:              t.Status,
+		PointsLeaderboardId: t.PointsLeaderboardId,
+		Points:              points,
+		CreatedAt:           t.CreatedAt,
+	}
+}
+
+func toTournamentPlayerEntity(p model.TournamentPlayer) entity.TournamentPlayer {
+	return entity.TournamentPlayer{
+		UserId:    p.UserId,
+		Seed:      p.Seed,
+		Placement: p.Placement,
+		Wins:      p.Wins,
+		Losses:    p.Losses,
+		Draws:     p.Draws,
+		Points:    p.Points,
+	}
+}
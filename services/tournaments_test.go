@@ -0,0 +1,336 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/JohnnyOhms/projectx/config"
+	"github.com/JohnnyOhms/projectx/entity"
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+func TestSeedOrder(t *testing.T) {
+	for _, c := range []struct {
+		size int
+		want []int
+	}{
+		{1, []int{1}},
+		{2, []int{1, 2}},
+		{4, []int{1, 4, 2, 3}},
+		{8, []int{1, 8, 4, 5, 2, 7, 3, 6}},
+	} {
+		if got := seedOrder(c.size); !slices.Equal(got, c.want) {
+			t.Errorf("seedOrder(%d) = %v, want %v", c.size, got, c.want)
+		}
+	}
+}
+
+// seeded names n players after their seed
+func seeded(n int) []string {
+	players := make([]string, n)
+	for i := range players {
+		players[i] = fmt.Sprintf("p%d", i+1)
+	}
+	return players
+}
+
+// seedOf reads the seed back from a player name
+func seedOf(userId string) int {
+	seed, _ := strconv.Atoi(strings.TrimPrefix(userId, "p"))
+	return seed
+}
+
+func TestDrawElimination(t *testing.T) {
+	for _, c := range []struct {
+		format  string
+		players int
+		// matches counts the matches of each bracket
+		matches map[string]int
+		// ready and byes count the matches open and played out right after the draw
+		ready, byes int
+		// placements are the places of the seeds when the better seed wins every match
+		placements []int
+	}{
+		{FormatSingleElimination, 3, map[string]int{BracketWinners: 3}, 1, 1, []int{1, 2, 3}},
+		{FormatSingleElimination, 4, map[string]int{BracketWinners: 3}, 2, 0, []int{1, 2, 3, 3}},
+		{FormatSingleElimination, 5, map[string]int{BracketWinners: 7}, 2, 3, []int{1, 2, 3, 3, 5}},
+		{FormatSingleElimination, 8, map[string]int{BracketWinners: 7}, 4, 0, []int{1, 2, 3, 3, 5, 5, 5, 5}},
+		{FormatDoubleElimination, 4, map[string]int{BracketWinners: 3, BracketLosers: 2, BracketFinal: 2}, 2, 0, []int{1, 2, 3, 4}},
+		{FormatDoubleElimination, 5, map[string]int{BracketWinners: 7, BracketLosers: 6, BracketFinal: 2}, 2, 4, []int{1, 2, 3, 4, 5}},
+		{FormatDoubleElimination, 8, map[string]int{BracketWinners: 7, BracketLosers: 6, BracketFinal: 2}, 4, 0, []int{1, 2, 3, 4, 5, 5, 7, 7}},
+	} {
+		t.Run(fmt.Sprintf("%s/%d", c.format, c.players), func(t *testing.T) {
+			testDB(t)
+			s := NewTournamentService()
+			drawn, err := s.CreateTournament(entity.Tournament{Name: "cup", Format: c.format, Players: seeded(c.players)})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			matches := map[string]int{}
+			var ready, byes int
+			for _, m := range drawn.Matches {
+				matches[m.Bracket]++
+				switch m.Status {
+				case MatchReady:
+					ready++
+				case MatchDone:
+					byes++
+				}
+			}
+			if fmt.Sprint(matches) != fmt.Sprint(c.matches) {
+				t.Errorf("matches: got %v, want %v", matches, c.matches)
+			}
+			if ready != c.ready || byes != c.byes {
+				t.Errorf("got %d ready and %d byes, want %d and %d", ready, byes, c.ready, c.byes)
+			}
+			checkMatchGraph(t, drawn.Tournament.ID, c.format == FormatDoubleElimination)
+
+			finished := playTournament(t, s, drawn.Tournament.ID, func(m entity.TournamentMatch) string {
+				if seedOf(m.PlayerA) < seedOf(m.PlayerB) {
+					return m.PlayerA
+				}
+				return m.PlayerB
+			})
+			expectPlacements(t, finished, c.placements)
+		})
+	}
+}
+
+// checkMatchGraph checks every match past the first round is fed by two others and every player
+// but the champion leaves through a link or an elimination
+func checkMatchGraph(t *testing.T, tournamentId uint, double bool) {
+	t.Helper()
+	var matches []model.TournamentMatch
+	if err := config.DB.Where("tournament_id = ?", tournamentId).Order("id").Find(&matches).Error; err != nil {
+		t.Fatal(err)
+	}
+	feeds := map[uint]int{}
+	var last, losers int
+	for _, m := range matches {
+		if m.NextMatchId != nil {
+			feeds[*m.NextMatchId]++
+		} else {
+			last++
+		}
+		if m.LoserMatchId != nil {
+			feeds[*m.LoserMatchId]++
+			losers++
+		}
+	}
+	for _, m := range matches {
+		want := 2
+		if m.Bracket == BracketWinners && m.Round == 1 {
+			want = 0
+		}
+		if feeds[m.ID] != want {
+			t.Errorf("%s round %d match %d is fed by %d matches, want %d", m.Bracket, m.Round, m.Number, feeds[m.ID], want)
+		}
+	}
+	if last != 1 {
+		t.Errorf("%d matches send their winner nowhere, want 1", last)
+	}
+	// in a double elimination every winners match drops its loser, and so does the first final
+	wantLosers := 0
+	if double {
+		for _, m := range matches {
+			if m.Bracket == BracketWinners {
+				wantLosers++
+			}
+		}
+		wantLosers++
+	}
+	if losers != wantLosers {
+		t.Errorf("%d matches drop their loser, want %d", losers, wantLosers)
+	}
+}
+
+// playTournament reports the winner pick chooses for every ready match until the tournament is over
+func playTournament(t *testing.T, s TournamentService, id uint, pick func(m entity.TournamentMatch) string) entity.TournamentBracket {
+	t.Helper()
+	for {
+		b, err := s.FindTournament(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.Tournament.Status == TournamentFinished {
+			return b
+		}
+		i := slices.IndexFunc(b.Matches, func(m entity.TournamentMatch) bool { return m.Status == MatchReady })
+		if i < 0 {
+			t.Fatal("the tournament is running without a ready match")
+		}
+		if _, err := s.ReportResult(id, b.Matches[i].ID, entity.MatchReport{WinnerId: pick(b.Matches[i])}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectPlacements(t *testing.T, b entity.TournamentBracket, want []int) {
+	t.Helper()
+	got := make([]int, len(b.Players))
+	for _, p := range b.Players {
+		got[p.Seed-1] = p.Placement
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("placements by seed: got %v, want %v", got, want)
+	}
+}
+
+func TestDoubleEliminationNeedsFourPlayers(t *testing.T) {
+	testDB(t)
+	_, err := NewTournamentService().CreateTournament(entity.Tournament{Name: "cup", Format: FormatDoubleElimination, Players: seeded(3)})
+	var invalid *InvalidError
+	if !errors.As(err, &invalid) {
+		t.Errorf("got %v, want an InvalidError", err)
+	}
+}
+
+// TestGrandFinalReset plays a double elimination where the losers bracket player wins the first final
+func TestGrandFinalReset(t *testing.T) {
+	for _, c := range []struct {
+		name string
+		// champion wins the reset
+		champion string
+		want     []int
+	}{
+		{"losers bracket player wins the reset", "p2", []int{2, 1, 3, 4}},
+		{"winners bracket player wins the reset", "p1", []int{1, 2, 3, 4}},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			testDB(t)
+			s := NewTournamentService()
+			drawn, err := s.CreateTournament(entity.Tournament{Name: "cup", Format: FormatDoubleElimination, Players: seeded(4)})
+			if err != nil {
+				t.Fatal(err)
+			}
+			finished := playTournament(t, s, drawn.Tournament.ID, func(m entity.TournamentMatch) string {
+				switch {
+				case m.Bracket == BracketFinal && m.Round == 1:
+					return m.PlayerB
+				case m.Bracket == BracketFinal:
+					return c.champion
+				case seedOf(m.PlayerA) < seedOf(m.PlayerB):
+					return m.PlayerA
+				}
+				return m.PlayerB
+			})
+			expectPlacements(t, finished, c.want)
+			reset := finished.Matches[len(finished.Matches)-1]
+			if reset.Bracket != BracketFinal || reset.Round != 2 || reset.WinnerId != c.champion {
+				t.Errorf("reset: got %+v, want a second final won by %s", reset, c.champion)
+			}
+			for _, p := range finished.Players {
+				if p.Seed <= 2 && p.Losses == 0 {
+					t.Errorf("%s played the reset without a loss", p.UserId)
+				}
+			}
+		})
+	}
+}
+
+func TestGrandFinalNoReset(t *testing.T) {
+	testDB(t)
+	s := NewTournamentService()
+	drawn, err := s.CreateTournament(entity.Tournament{Name: "cup", Format: FormatDoubleElimination, Players: seeded(4)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := playTournament(t, s, drawn.Tournament.ID, func(m entity.TournamentMatch) string {
+		if seedOf(m.PlayerA) < seedOf(m.PlayerB) {
+			return m.PlayerA
+		}
+		return m.PlayerB
+	})
+	reset := finished.Matches[len(finished.Matches)-1]
+	if reset.Status != MatchDone || reset.PlayerA != "" || reset.PlayerB != "" {
+		t.Errorf("reset: got %+v, want it closed without players", reset)
+	}
+}
+
+func TestSwissPairing(t *testing.T) {
+	testDB(t)
+	s := NewTournamentService()
+	drawn, err := s.CreateTournament(entity.Tournament{Name: "swiss", Format: FormatSwiss, Rounds: 4, Players: seeded(5)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	finished := playTournament(t, s, drawn.Tournament.ID, func(m entity.TournamentMatch) string {
+		if seedOf(m.PlayerA) < seedOf(m.PlayerB) {
+			return m.PlayerA
+		}
+		return m.PlayerB
+	})
+
+	rounds := map[int][]string{}
+	met := map[string]bool{}
+	byes := map[string]int{}
+	for _, m := range finished.Matches {
+		if m.PlayerB == "" {
+			rounds[m.Round] = append(rounds[m.Round], "bye "+m.PlayerA)
+			byes[m.PlayerA]++
+			continue
+		}
+		rounds[m.Round] = append(rounds[m.Round], m.PlayerA+"-"+m.PlayerB)
+		pair := m.PlayerA + "-" + m.PlayerB
+		if m.PlayerB < m.PlayerA {
+			pair = m.PlayerB + "-" + m.PlayerA
+		}
+		if met[pair] {
+			t.Errorf("%s met twice", pair)
+		}
+		met[pair] = true
+	}
+	// the first round pairs by seed, the second by points then by the points of the opponents met
+	for round, want := range map[int][]string{
+		1: {"p1-p2", "p3-p4", "bye p5"},
+		2: {"p1-p3", "p5-p2", "bye p4"},
+	} {
+		if !slices.Equal(rounds[round], want) {
+			t.Errorf("round %d: got %v, want %v", round, rounds[round], want)
+		}
+	}
+	if len(rounds) != 4 {
+		t.Errorf("got %d rounds, want 4", len(rounds))
+	}
+	for userId, n := range byes {
+		if n > 1 {
+			t.Errorf("%s got %d byes", userId, n)
+		}
+	}
+}
+
+func TestPairUp(t *testing.T) {
+	met := func(pairs ...string) map[[2]string]bool {
+		played := map[[2]string]bool{}
+		for _, pair := range pairs {
+			a, b, _ := strings.Cut(pair, "-")
+			played[[2]string{a, b}] = true
+			played[[2]string{b, a}] = true
+		}
+		return played
+	}
+	for _, c := range []struct {
+		name    string
+		players []string
+		played  map[[2]string]bool
+		want    string
+	}{
+		{"first opponent", []string{"p1", "p2", "p3", "p4"}, met(), "[[p1 p2] [p3 p4]]"},
+		// pairing p1 with p4 first would leave p2 and p5 to meet again
+		{"looks past a dead end", []string{"p1", "p2", "p4", "p5"}, met("p1-p2", "p1-p3", "p3-p4", "p2-p5"), "[[p1 p5] [p2 p4]]"},
+		{"no pairing without repeats", []string{"p1", "p2"}, met("p1-p2"), "[]"},
+	} {
+		budget := maxPairingSteps
+		if got := fmt.Sprint(pairUp(c.players, c.played, &budget)); got != c.want {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.want)
+		}
+	}
+	if got := fmt.Sprint(pairGreedy([]string{"p1", "p2", "p3", "p4"}, met("p1-p2", "p1-p3", "p1-p4"))); got != "[[p1 p2] [p3 p4]]" {
+		t.Errorf("greedy fallback: got %s", got)
+	}
+}
@@ -0,0 +1,97 @@
+package services
+
+import (
+	"strings"
+	"time"
+	_ "time/tzdata" // board timezones must resolve on hosts without a zoneinfo database
+
+	"github.com/JohnnyOhms/projectx/model"
+)
+
+// Time windows every submission is recorded in
+const (
+	WindowAllTime = "all"
+	WindowDaily   = "daily"
+	WindowWeekly  = "weekly"
+	WindowMonthly = "monthly"
+)
+
+// Windows lists the windows in the order they are applied
+var Windows = []string{WindowAllTime, WindowDaily, WindowWeekly, WindowMonthly}
+
+// allTimeStart is the fixed period start of the all time window
+var allTimeStart = time.Unix(0, 0).UTC()
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// period is one reset cycle of a window, End is zero for the all time window
+type period struct {
+	Window string
+	Start  time.Time
+	End    time.Time
+	// Country narrows the period to the players of one country, their ranks are counted among themselves
+	Country string
+}
+
+// periodAt returns the period of the window that contains t
+func periodAt(board model.Leaderboard, window string, t time.Time) (period, error) {
+	if window == WindowAllTime {
+		return period{Window: window, Start: allTimeStart}, nil
+	}
+
+	loc, err := time.LoadLocation(board.Timezone)
+	if err != nil {
+		return period{}, err
+	}
+	local := t.In(loc)
+	midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+
+	var start, end time.Time
+	switch window {
+	case WindowDaily:
+		start = midnight
+		end = start.AddDate(0, 0, 1)
+	case WindowWeekly:
+		back := (int(midnight.Weekday()) - int(weekdays[board.WeekStart]) + 7) % 7
+		start = midnight.AddDate(0, 0, -back)
+		end = start.AddDate(0, 0, 7)
+	case WindowMonthly:
+		start = time.Date(local.Year(), local.Month(), 1, 0, 0, 0, 0, loc)
+		end = start.AddDate(0, 1, 0)
+	default:
+		return period{}, invalid("window must be all, daily, weekly or monthly")
+	}
+	return period{Window: window, Start: start.UTC(), End: end.UTC()}, nil
+}
+
+// currentPeriods returns the open period of every window at t
+func currentPeriods(board model.Leaderboard, t time.Time) ([]period, error) {
+	periods := make([]period, 0, len(Windows))
+	for _, window := range Windows {
+		p, err := periodAt(board, window, t)
+		if err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, nil
+}
+
+// validateWindowSettings checks the timezone and week start of a board
+func validateWindowSettings(timezone, weekStart string) error {
+	if _, err := time.LoadLocation(timezone); err != nil {
+		return invalid("timezone must be an IANA name such as Europe/Berlin")
+	}
+	if _, ok := weekdays[strings.ToLower(weekStart)]; !ok {
+		return invalid("week_start must be a day of the week")
+	}
+	return nil
+}
@@ -1 +1,44 @@
 package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Score formats understood by FormatScore
+const (
+	FormatInteger      = "integer"
+	FormatMilliseconds = "milliseconds"
+	FormatDecimal      = "decimal"
+)
+
+// FormatScore turns a raw score into the display value for a leaderboard format
+func FormatScore(score float64, format string, precision int) string {
+	switch format {
+	case FormatMilliseconds:
+		return formatMilliseconds(score)
+	case FormatDecimal:
+		return strconv.FormatFloat(score, 'f', precision, 64)
+	default:
+		return strconv.FormatFloat(math.Round(score), 'f', 0, 64)
+	}
+}
+
+// formatMilliseconds renders a duration in ms as h:mm:ss.mmm, dropping the hours when zero
+func formatMilliseconds(score float64) string {
+	sign := ""
+	if score < 0 {
+		sign = "-"
+		score = -score
+	}
+	ms := int64(math.Round(score))
+	hours := ms / 3600000
+	minutes := ms / 60000 % 60
+	seconds := ms / 1000 % 60
+	millis := ms % 1000
+	if hours > 0 {
+		return fmt.Sprintf("%s%d:%02d:%02d.%03d", sign, hours, minutes, seconds, millis)
+	}
+	return fmt.Sprintf("%s%d:%02d.%03d", sign, minutes, seconds, millis)
+}